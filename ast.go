@@ -9,6 +9,23 @@ const (
 	NodeLiteral
 	NodeArray
 	NodeProperty
+	// NodeError marks input the recovering parser skipped. It only appears in partial ASTs returned
+	// by ParseRuleWithRecovery and cannot be evaluated.
+	NodeError
+	// NodeConditional chooses a value: Children holds condition and value pairs, in order, followed
+	// by the value of the else branch when there is one.
+	NodeConditional
+	// NodeParameter is a $name placeholder; Value.StrValue holds the name without the $.
+	NodeParameter
+	// NodeReference is an @name reference to a definition; Value.StrValue holds the name without
+	// the @. Engines inline definitions when they compile a rule.
+	NodeReference
+	// NodeLet binds the value of Left to the name in Value.StrValue while Right is evaluated.
+	NodeLet
+	// NodeVariable reads a name bound by an enclosing NodeLet; Value.StrValue holds the name.
+	NodeVariable
+	// NodeCall calls the built-in function named in Value.StrValue with Children as arguments.
+	NodeCall
 )
 
 type ASTNode struct {
@@ -18,6 +35,10 @@ type ASTNode struct {
 	Right    *ASTNode
 	Value    Value
 	Children []*ASTNode
+	// Start and End are the rune offsets of the node in the parsed rule (End is exclusive).
+	// Both are zero for nodes that were not produced by the parser.
+	Start int
+	End   int
 }
 
 type ValueType uint8
@@ -28,8 +49,28 @@ const (
 	ValueBoolean
 	ValueArray
 	ValueIdentifier
+	ValueDuration
 )
 
+func (t ValueType) String() string {
+	switch t {
+	case ValueString:
+		return "string"
+	case ValueNumber:
+		return "number"
+	case ValueBoolean:
+		return "boolean"
+	case ValueArray:
+		return "array"
+	case ValueIdentifier:
+		return "identifier"
+	case ValueDuration:
+		return "duration"
+	}
+
+	return "unknown"
+}
+
 type Value struct {
 	Type      ValueType
 	StrValue  string
@@ -40,6 +81,8 @@ type Value struct {
 	IntValue int64
 	// IsInt indicates if this numeric value should be treated as an integer
 	IsInt bool
+	// DurValue holds the value of a duration
+	DurValue Duration
 }
 
 func NewBinaryOpNode(op TokenType, left, right *ASTNode) *ASTNode {
@@ -125,6 +168,16 @@ func NewBooleanLiteralNode(value bool) *ASTNode {
 	}
 }
 
+func NewDurationLiteralNode(value Duration) *ASTNode {
+	return &ASTNode{
+		Type: NodeLiteral,
+		Value: Value{
+			Type:     ValueDuration,
+			DurValue: value,
+		},
+	}
+}
+
 func NewArrayLiteralNode(elements []Value) *ASTNode {
 	return &ASTNode{
 		Type: NodeLiteral,
@@ -135,6 +188,85 @@ func NewArrayLiteralNode(elements []Value) *ASTNode {
 	}
 }
 
+func NewErrorNode(start, end int) *ASTNode {
+	return &ASTNode{
+		Type:  NodeError,
+		Start: start,
+		End:   end,
+	}
+}
+
+// NewConditionalNode builds a conditional from condition and value pairs, optionally followed by
+// the value of the else branch.
+func NewConditionalNode(branches ...*ASTNode) *ASTNode {
+	return &ASTNode{
+		Type:     NodeConditional,
+		Children: branches,
+	}
+}
+
+func NewParameterNode(name string) *ASTNode {
+	return &ASTNode{
+		Type: NodeParameter,
+		Value: Value{
+			Type:     ValueIdentifier,
+			StrValue: name,
+		},
+	}
+}
+
+func NewReferenceNode(name string) *ASTNode {
+	return &ASTNode{
+		Type: NodeReference,
+		Value: Value{
+			Type:     ValueIdentifier,
+			StrValue: name,
+		},
+	}
+}
+
+func NewLetNode(name string, value, body *ASTNode) *ASTNode {
+	return &ASTNode{
+		Type: NodeLet,
+		Value: Value{
+			Type:     ValueIdentifier,
+			StrValue: name,
+		},
+		Left:  value,
+		Right: body,
+	}
+}
+
+func NewVariableNode(name string) *ASTNode {
+	return &ASTNode{
+		Type: NodeVariable,
+		Value: Value{
+			Type:     ValueIdentifier,
+			StrValue: name,
+		},
+	}
+}
+
+func NewCallNode(name string, args ...*ASTNode) *ASTNode {
+	return &ASTNode{
+		Type: NodeCall,
+		Value: Value{
+			Type:     ValueIdentifier,
+			StrValue: name,
+		},
+		Children: args,
+	}
+}
+
+// otherwise returns the value of the else branch of a conditional, or nil when it has none.
+func (n *ASTNode) otherwise() *ASTNode {
+	if n.Type != NodeConditional || len(n.Children)%2 == 0 {
+		return nil
+	}
+
+	return n.Children[len(n.Children)-1]
+}
+
 func (n *ASTNode) IsOperator() bool {
 	return n.Type == NodeBinaryOp || n.Type == NodeUnaryOp
 }
@@ -146,3 +278,8 @@ func (n *ASTNode) IsLiteral() bool {
 func (n *ASTNode) IsIdentifier() bool {
 	return n.Type == NodeIdentifier || n.Type == NodeProperty
 }
+
+// HasSpan reports whether the node carries a source span from the parser.
+func (n *ASTNode) HasSpan() bool {
+	return n.End > n.Start
+}
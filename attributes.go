@@ -0,0 +1,167 @@
+package rule
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Attribute describes a context attribute a rule reads and how the rule uses it.
+type Attribute struct {
+	// Path holds the segments of the attribute: user.age is ["user", "age"].
+	Path []string
+	// Operators lists the distinct comparison and presence operators applied to the attribute, as
+	// written (== and != are reported as eq and ne), in order of first use.
+	Operators []TokenType
+	// Types lists the distinct types of the literals the attribute is compared against, in order
+	// of first use.
+	Types []ValueType
+}
+
+// String returns the dotted path of the attribute.
+func (a Attribute) String() string {
+	return strings.Join(a.Path, ".")
+}
+
+// Attributes returns every attribute the rule reads, sorted by path. For rules compiled by an
+// engine the list is computed once at compile time; callers must not modify it.
+func (c *CompiledRule) Attributes() []Attribute {
+	if c.attributes != nil {
+		return c.attributes
+	}
+
+	return collectAttributes(c.AST)
+}
+
+// newCompiledRule wraps an AST, computing the attributes it reads.
+func newCompiledRule(ast *ASTNode, hashValue uint64) *CompiledRule {
+	return &CompiledRule{
+		AST:        ast,
+		Hash:       hashValue,
+		attributes: collectAttributes(ast),
+	}
+}
+
+// attributeSet accumulates attributes by dotted path.
+type attributeSet map[string]*Attribute
+
+func collectAttributes(ast *ASTNode) []Attribute {
+	set := attributeSet{}
+	set.collect(ast)
+
+	return set.sorted()
+}
+
+func (s attributeSet) collect(node *ASTNode) {
+	if node == nil {
+		return
+	}
+
+	switch node.Type {
+	case NodeBinaryOp:
+		if node.Operator != AND && node.Operator != OR && !isArithmeticOperator(node.Operator) {
+			op := canonicalToken(node.Operator)
+			s.use(node.Left, op, node.Right)
+			s.use(node.Right, op, node.Left)
+		}
+
+		s.collect(node.Left)
+		s.collect(node.Right)
+
+	case NodeUnaryOp:
+		if node.Operator == PR {
+			s.use(node.Left, PR, nil)
+		}
+
+		s.collect(node.Left)
+
+	case NodeConditional:
+		for _, child := range node.Children {
+			s.collect(child)
+		}
+
+	case NodeIdentifier, NodeProperty:
+		s.add(node)
+
+	case NodeLet:
+		s.collect(node.Left)
+		s.collect(node.Right)
+
+	case NodeLiteral, NodeArray, NodeParameter, NodeReference, NodeVariable, NodeCall, NodeError:
+	}
+}
+
+// use records that operand, if it is an attribute, is used with op against other.
+func (s attributeSet) use(operand *ASTNode, op TokenType, other *ASTNode) {
+	attribute := s.add(operand)
+	if attribute == nil {
+		return
+	}
+
+	if !slices.Contains(attribute.Operators, op) {
+		attribute.Operators = append(attribute.Operators, op)
+	}
+
+	if other != nil && other.Type == NodeLiteral && !slices.Contains(attribute.Types, other.Value.Type) {
+		attribute.Types = append(attribute.Types, other.Value.Type)
+	}
+}
+
+// add returns the entry for an attribute node, creating it on first use, or nil for other nodes.
+func (s attributeSet) add(node *ASTNode) *Attribute {
+	path, ok := attributePath(node)
+	if !ok {
+		return nil
+	}
+
+	key := strings.Join(path, ".")
+
+	attribute, exists := s[key]
+	if !exists {
+		attribute = &Attribute{Path: path}
+		s[key] = attribute
+	}
+
+	return attribute
+}
+
+// merge adds the uses recorded in attributes, keeping the order of first use.
+func (s attributeSet) merge(attributes []Attribute) {
+	for _, other := range attributes {
+		key := other.String()
+
+		attribute, exists := s[key]
+		if !exists {
+			attribute = &Attribute{Path: other.Path}
+			s[key] = attribute
+		}
+
+		for _, op := range other.Operators {
+			if !slices.Contains(attribute.Operators, op) {
+				attribute.Operators = append(attribute.Operators, op)
+			}
+		}
+
+		for _, valueType := range other.Types {
+			if !slices.Contains(attribute.Types, valueType) {
+				attribute.Types = append(attribute.Types, valueType)
+			}
+		}
+	}
+}
+
+func (s attributeSet) sorted() []Attribute {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	attributes := make([]Attribute, len(keys))
+	for i, key := range keys {
+		attributes[i] = *s[key]
+	}
+
+	return attributes
+}
@@ -0,0 +1,69 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompiledRuleAttributes(t *testing.T) {
+	t.Run("Uses", testAttributesUses)
+	t.Run("Constructed", testAttributesConstructed)
+	t.Run("RuleSet", testRuleSetAttributes)
+}
+
+func testAttributesUses(t *testing.T) {
+	engine := NewEngine()
+
+	compiled, err := engine.CompileRule(
+		`user.age ge 18 and user.age lt 65.5 and (tier == "gold" or tier in ["vip"]) and ` +
+			`not banned and user.email pr and "x" in user.tags and limit gt user.age`)
+	require.NoError(t, err)
+
+	require.Equal(t, []Attribute{
+		{Path: []string{"banned"}},
+		{Path: []string{"limit"}, Operators: []TokenType{GT}},
+		{Path: []string{"tier"}, Operators: []TokenType{EQ, IN}, Types: []ValueType{ValueString, ValueArray}},
+		{Path: []string{"user", "age"}, Operators: []TokenType{GE, LT, GT}, Types: []ValueType{ValueNumber}},
+		{Path: []string{"user", "email"}, Operators: []TokenType{PR}},
+		{Path: []string{"user", "tags"}, Operators: []TokenType{IN}, Types: []ValueType{ValueString}},
+	}, compiled.Attributes())
+
+	require.Equal(t, "user.age", compiled.Attributes()[3].String())
+
+	constant, err := engine.CompileRule(`1 eq 1`)
+	require.NoError(t, err)
+	require.Empty(t, constant.Attributes())
+}
+
+func testAttributesConstructed(t *testing.T) {
+	compiled := &CompiledRule{AST: NewBinaryOpNode(EQUALS, NewIdentifierNode("a"), NewBooleanLiteralNode(true))}
+
+	require.Equal(t, []Attribute{
+		{Path: []string{"a"}, Operators: []TokenType{EQ}, Types: []ValueType{ValueBoolean}},
+	}, compiled.Attributes(), "attributes are computed on demand for rules built by hand")
+
+	residual, err := NewEngine().PartialEvaluate(`a eq 1 and b gt c`, D{"c": 2})
+	require.NoError(t, err)
+	require.Equal(t, []Attribute{
+		{Path: []string{"a"}, Operators: []TokenType{EQ}, Types: []ValueType{ValueNumber}},
+		{Path: []string{"b"}, Operators: []TokenType{GT}, Types: []ValueType{ValueNumber}},
+	}, residual.Attributes(), "residuals only read the unknown attributes")
+}
+
+func testRuleSetAttributes(t *testing.T) {
+	set := NewEngine().NewRuleSet()
+
+	require.NoError(t, set.Add("adult", `user.age ge 18`))
+	require.NoError(t, set.Add("brazil", `user.country eq "BR" and user.age lt 70`))
+	require.NoError(t, set.Add("named", `user.name pr`))
+
+	require.Equal(t, []Attribute{
+		{Path: []string{"user", "age"}, Operators: []TokenType{GE, LT}, Types: []ValueType{ValueNumber}},
+		{Path: []string{"user", "country"}, Operators: []TokenType{EQ}, Types: []ValueType{ValueString}},
+		{Path: []string{"user", "name"}, Operators: []TokenType{PR}},
+	}, set.Attributes())
+
+	set.Remove("named")
+	require.Len(t, set.Attributes(), 2)
+}
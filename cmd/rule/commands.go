@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NSXBet/rule"
+)
+
+func (env *environment) flagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet("rule "+name, flag.ContinueOnError)
+	flags.SetOutput(env.stderr)
+
+	return flags
+}
+
+// ruleSource returns the rule given with -r, or the single positional argument.
+func ruleSource(ruleFlag string, args []string) (string, error) {
+	switch {
+	case ruleFlag != "" && len(args) == 0:
+		return ruleFlag, nil
+	case ruleFlag == "" && len(args) == 1:
+		return args[0], nil
+	default:
+		return "", errors.New("expected exactly one rule, given with -r or as an argument")
+	}
+}
+
+func (env *environment) eval(args []string) int {
+	flags := env.flagSet("eval")
+	ruleFlag := flags.String("r", "", "rule to evaluate")
+	contextPath := flags.String("c", "", `JSON or YAML context file, or "-" for stdin`)
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	source, err := ruleSource(*ruleFlag, flags.Args())
+	if err != nil {
+		return env.fail(err)
+	}
+
+	context, err := env.loadContext(*contextPath)
+	if err != nil {
+		return env.fail(err)
+	}
+
+	result, err := rule.NewEngine().Evaluate(source, context)
+	if err != nil {
+		return env.fail(err)
+	}
+
+	fmt.Fprintln(env.stdout, result)
+
+	if !result {
+		return exitFailure
+	}
+
+	return exitOK
+}
+
+func (env *environment) check(args []string) int {
+	flags := env.flagSet("check")
+	samplePath := flags.String("c", "", "sample JSON or YAML context; attributes missing from it are reported")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	var schema *rule.Schema
+
+	if *samplePath != "" {
+		sample, err := env.loadContext(*samplePath)
+		if err != nil {
+			return env.fail(err)
+		}
+
+		schema = rule.SchemaFromContext(sample)
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{stdinPath}
+	}
+
+	status := exitOK
+
+	for _, path := range paths {
+		data, err := env.readInput(path)
+		if err != nil {
+			return env.fail(err)
+		}
+
+		forEachRule(data, func(line int, source string) {
+			ast, syntaxErrors := rule.ParseRuleWithRecovery(source)
+			if schema != nil && ast != nil {
+				syntaxErrors = append(syntaxErrors, schema.Check(ast)...)
+				sort.SliceStable(syntaxErrors, func(i, j int) bool {
+					return syntaxErrors[i].Start < syntaxErrors[j].Start
+				})
+			}
+
+			for _, syntaxErr := range syntaxErrors {
+				env.reportRuleError(path, line, syntaxErr)
+
+				status = exitFailure
+			}
+		})
+	}
+
+	return status
+}
+
+func (env *environment) format(args []string) int {
+	flags := env.flagSet("fmt")
+	ruleFlag := flags.String("r", "", "format a single rule and print it")
+	write := flags.Bool("w", false, "write the result back to the source file")
+	list := flags.Bool("l", false, "list files whose formatting differs")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *ruleFlag != "" {
+		node, err := rule.ParseRule(*ruleFlag)
+		if err != nil {
+			return env.fail(err)
+		}
+
+		fmt.Fprintln(env.stdout, rule.Format(node))
+
+		return exitOK
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		if *write {
+			return env.fail(errors.New("cannot use -w with standard input"))
+		}
+
+		paths = []string{stdinPath}
+	}
+
+	status := exitOK
+
+	for _, path := range paths {
+		data, err := env.readInput(path)
+		if err != nil {
+			return env.fail(err)
+		}
+
+		formatted, ok := env.formatRules(path, data)
+		if !ok {
+			status = exitFailure
+			continue
+		}
+
+		switch {
+		case *list:
+			if !bytes.Equal(data, formatted) {
+				fmt.Fprintln(env.stdout, path)
+			}
+		case *write:
+			if !bytes.Equal(data, formatted) {
+				if err := os.WriteFile(path, formatted, 0o644); err != nil { //nolint:gosec // rule files are not secret
+					return env.fail(err)
+				}
+			}
+		default:
+			if _, err := env.stdout.Write(formatted); err != nil {
+				return env.fail(err)
+			}
+		}
+	}
+
+	return status
+}
+
+// formatRules rewrites every rule in a rule file canonically, keeping comments and blank lines.
+func (env *environment) formatRules(path string, data []byte) ([]byte, bool) {
+	lines := splitLines(data)
+	ok := true
+
+	forEachRule(data, func(line int, source string) {
+		node, err := rule.ParseRule(source)
+		if err != nil {
+			env.reportRuleError(path, line, err)
+
+			ok = false
+
+			return
+		}
+
+		lines[line-1] = rule.Format(node)
+	})
+
+	var out bytes.Buffer
+
+	for _, line := range lines {
+		out.WriteString(strings.TrimRightFunc(line, isSpace))
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes(), ok
+}
+
+func (env *environment) ast(args []string) int {
+	flags := env.flagSet("ast")
+	ruleFlag := flags.String("r", "", "rule to parse")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	source, err := ruleSource(*ruleFlag, flags.Args())
+	if err != nil {
+		return env.fail(err)
+	}
+
+	node, err := rule.ParseRule(source)
+	if err != nil {
+		return env.fail(err)
+	}
+
+	dumpAST(env.stdout, node, 0)
+
+	return exitOK
+}
+
+// reportRuleError prints an error as file:line:col: message, with a 1-based rune column and any
+// "did you mean" hint appended.
+func (env *environment) reportRuleError(path string, line int, err error) {
+	column := 1
+	message := err.Error()
+
+	var syntaxErr *rule.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		column = syntaxErr.Start + 1
+
+		message = syntaxErr.Err.Error()
+		if hint := syntaxErr.Hint(); hint != "" {
+			message += ", " + hint
+		}
+	}
+
+	name := path
+	if path == stdinPath {
+		name = "<stdin>"
+	}
+
+	fmt.Fprintf(env.stderr, "%s:%d:%d: %s\n", name, line, column, message)
+}
+
+// forEachRule calls fn with the 1-based line number and text of every rule line in data.
+func forEachRule(data []byte, fn func(line int, source string)) {
+	for i, line := range splitLines(data) {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		fn(i+1, line)
+	}
+}
+
+func splitLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	return lines
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t'
+}
+
+// dumpAST writes one line per node, indented by depth, with the node's source span when known.
+func dumpAST(w io.Writer, node *rule.ASTNode, depth int) {
+	if node == nil {
+		return
+	}
+
+	var label string
+
+	switch node.Type {
+	case rule.NodeBinaryOp:
+		label = "BinaryOp " + node.Operator.String()
+	case rule.NodeUnaryOp:
+		label = "UnaryOp " + node.Operator.String()
+	case rule.NodeIdentifier:
+		label = "Identifier " + node.Value.StrValue
+	case rule.NodeProperty:
+		label = "Property " + rule.Format(node)
+	case rule.NodeLiteral, rule.NodeArray:
+		label = "Literal " + node.Value.Type.String() + " " + rule.Format(node)
+	case rule.NodeConditional:
+		label = "Conditional"
+	case rule.NodeParameter:
+		label = "Parameter " + rule.Format(node)
+	case rule.NodeReference:
+		label = "Reference " + rule.Format(node)
+	case rule.NodeLet:
+		label = "Let " + node.Value.StrValue
+	case rule.NodeVariable:
+		label = "Variable " + node.Value.StrValue
+	case rule.NodeCall:
+		label = "Call " + rule.Format(node)
+	case rule.NodeError:
+		label = "Error"
+	}
+
+	if node.HasSpan() {
+		label += " [" + strconv.Itoa(node.Start) + ":" + strconv.Itoa(node.End) + "]"
+	}
+
+	fmt.Fprintf(w, "%s%s\n", strings.Repeat("  ", depth), label)
+
+	dumpAST(w, node.Left, depth+1)
+	dumpAST(w, node.Right, depth+1)
+
+	if node.Type == rule.NodeConditional {
+		for _, child := range node.Children {
+			dumpAST(w, child, depth+1)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NSXBet/rule"
+	"gopkg.in/yaml.v3"
+)
+
+// stdinPath is the path that makes commands read from standard input.
+const stdinPath = "-"
+
+// readInput returns the contents of path, or of stdin when path is "-".
+func (env *environment) readInput(path string) ([]byte, error) {
+	if path == stdinPath {
+		return io.ReadAll(env.stdin)
+	}
+
+	return os.ReadFile(path)
+}
+
+// loadContext reads an evaluation context. Files ending in .yaml or .yml are decoded as YAML,
+// everything else as JSON with a YAML fallback, so contexts piped through stdin may use either.
+func (env *environment) loadContext(path string) (rule.D, error) {
+	if path == "" {
+		return rule.D{}, nil
+	}
+
+	data, err := env.readInput(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var document any
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+
+		if err = decoder.Decode(&document); err != nil && path == stdinPath {
+			document = nil
+			err = yaml.Unmarshal(data, &document)
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("reading context %s: %w", path, err)
+	}
+
+	if document == nil {
+		return rule.D{}, nil
+	}
+
+	context, ok := normalize(document).(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("reading context %s: top-level value must be an object", path)
+	}
+
+	return context, nil
+}
+
+// normalize converts decoded JSON numbers to int64 or float64 so the engine sees the same types
+// it would receive from Go code, preserving integers beyond float64 precision.
+func normalize(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64()
+
+		return f
+	default:
+		return v
+	}
+}
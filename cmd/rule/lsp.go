@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+
+	"github.com/NSXBet/rule"
+	"github.com/NSXBet/rule/lsp"
+)
+
+func (env *environment) lsp(args []string) int {
+	flags := env.flagSet("lsp")
+	contextPath := flags.String("c", "", "sample JSON or YAML context whose attribute paths are offered by completion")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	server := lsp.NewServer()
+
+	if *contextPath != "" {
+		sample, err := env.loadContext(*contextPath)
+		if err != nil {
+			return env.fail(err)
+		}
+
+		server.Attributes = rule.ContextPaths(sample)
+	}
+
+	if err := server.Serve(context.Background(), env.stdin, env.stdout); err != nil {
+		return env.fail(err)
+	}
+
+	return exitOK
+}
@@ -0,0 +1,88 @@
+// Command rule evaluates, checks, formats and inspects rules written in the rule language.
+//
+// Usage:
+//
+//	rule eval -r 'user.age gt 18' -c context.json
+//	rule check rules.txt
+//	rule fmt [-w | -l] [file ...]
+//	rule ast -r 'a eq 1 and b pr'
+//	rule repl -c context.json
+//	rule lsp -c sample-context.json
+//
+// Contexts are JSON or YAML documents read from a file, or from standard input when the path is
+// "-". Rule files hold one rule per line; blank lines and lines starting with # are ignored.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exit codes shared by all subcommands.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitError   = 2
+)
+
+const usage = `Usage: rule <command> [flags]
+
+Commands:
+  eval    evaluate a rule against a JSON or YAML context (exit 0 true, 1 false, 2 error)
+  check   report syntax and validation errors in rule files
+  fmt     rewrite rules in canonical form
+  ast     print the parsed syntax tree of a rule
+  repl    evaluate rules interactively against a context
+  lsp     run the language server over stdin and stdout
+
+Run "rule <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line in args and returns the process exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+
+	env := &environment{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	switch args[0] {
+	case "eval":
+		return env.eval(args[1:])
+	case "check":
+		return env.check(args[1:])
+	case "fmt":
+		return env.format(args[1:])
+	case "ast":
+		return env.ast(args[1:])
+	case "repl":
+		return env.repl(args[1:])
+	case "lsp":
+		return env.lsp(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "rule: unknown command %q\n\n%s", args[0], usage)
+		return exitError
+	}
+}
+
+// environment holds the standard streams used by the subcommands.
+type environment struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// fail reports an error on stderr and returns exitError.
+func (env *environment) fail(err error) int {
+	fmt.Fprintf(env.stderr, "rule: %v\n", err)
+	return exitError
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func execute(stdin string, args ...string) result {
+	var stdout, stderr bytes.Buffer
+
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return result{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestRun(t *testing.T) {
+	t.Run("NoArguments", testNoArguments)
+	t.Run("UnknownCommand", testUnknownCommand)
+	t.Run("Eval", testEval)
+	t.Run("EvalContextFormats", testEvalContextFormats)
+	t.Run("EvalErrors", testEvalErrors)
+	t.Run("Check", testCheck)
+	t.Run("Fmt", testFmt)
+	t.Run("FmtWrite", testFmtWrite)
+	t.Run("AST", testAST)
+	t.Run("REPL", testREPL)
+	t.Run("REPLCompletion", testREPLCompletion)
+	t.Run("LSP", testLSP)
+}
+
+func testNoArguments(t *testing.T) {
+	res := execute("")
+	require.Equal(t, exitError, res.code)
+	require.Contains(t, res.stderr, "Usage: rule")
+}
+
+func testUnknownCommand(t *testing.T) {
+	res := execute("", "bogus")
+	require.Equal(t, exitError, res.code)
+	require.Contains(t, res.stderr, `unknown command "bogus"`)
+}
+
+func testEval(t *testing.T) {
+	context := writeFile(t, "ctx.json", `{"x": 2, "user": {"id": 9007199254740993}}`)
+
+	res := execute("", "eval", "-r", "x gt 1", "-c", context)
+	require.Equal(t, exitOK, res.code)
+	require.Equal(t, "true\n", res.stdout)
+
+	res = execute("", "eval", "-r", "x gt 5", "-c", context)
+	require.Equal(t, exitFailure, res.code)
+	require.Equal(t, "false\n", res.stdout)
+
+	res = execute("", "eval", "-c", context, "user.id eq 9007199254740993")
+	require.Equal(t, exitOK, res.code)
+
+	res = execute("", "eval", "-r", "missing pr")
+	require.Equal(t, exitFailure, res.code)
+}
+
+func testEvalContextFormats(t *testing.T) {
+	yamlContext := writeFile(t, "ctx.yaml", "user:\n  name: Ann\n  tags: [a, b]\n")
+
+	res := execute("", "eval", "-r", `user.name eq "ann" and "b" in user.tags`, "-c", yamlContext)
+	require.Equal(t, exitOK, res.code, res.stderr)
+
+	res = execute(`{"x": 3}`, "eval", "-r", "x eq 3", "-c", "-")
+	require.Equal(t, exitOK, res.code, res.stderr)
+
+	res = execute("x: 3\n", "eval", "-r", "x eq 3", "-c", "-")
+	require.Equal(t, exitOK, res.code, res.stderr)
+}
+
+func testEvalErrors(t *testing.T) {
+	res := execute("", "eval", "-r", "x eq")
+	require.Equal(t, exitError, res.code)
+	require.Contains(t, res.stderr, "unexpected token EOF")
+
+	res = execute("", "eval")
+	require.Equal(t, exitError, res.code)
+	require.Contains(t, res.stderr, "expected exactly one rule")
+
+	res = execute("[1, 2]", "eval", "-r", "x eq 1", "-c", "-")
+	require.Equal(t, exitError, res.code)
+	require.Contains(t, res.stderr, "top-level value must be an object")
+
+	res = execute("", "eval", "-r", "x eq 1", "-c", filepath.Join(t.TempDir(), "missing.json"))
+	require.Equal(t, exitError, res.code)
+}
+
+func testCheck(t *testing.T) {
+	rules := writeFile(t, "rules.txt", "# comment\nx eq 1\n\n  tier in \"gold\"\na b eq 1\nx eq and y gt\n")
+
+	res := execute("", "check", rules)
+	require.Equal(t, exitFailure, res.code)
+	require.Equal(t,
+		rules+":4:3: IN operator requires an array operand\n"+
+			rules+":5:3: Missing operator between operands\n"+
+			rules+":6:6: unexpected token and\n"+
+			rules+":6:14: unexpected token EOF\n",
+		res.stderr)
+
+	res = execute("x eq 1\ny pr\n", "check")
+	require.Equal(t, exitOK, res.code)
+	require.Empty(t, res.stderr)
+
+	res = execute(`name eq "open`, "check", "-")
+	require.Equal(t, exitFailure, res.code)
+	require.Equal(t, "<stdin>:1:9: Unterminated string literal\n", res.stderr)
+
+	res = execute("x eq 1 adn y eq 2\n", "check")
+	require.Equal(t, exitFailure, res.code)
+	require.Equal(t, "<stdin>:1:8: Unexpected tokens after complete expression, did you mean \"and\"?\n", res.stderr)
+
+	sample := writeFile(t, "sample.json", `{"user": {"email": "a@b.c"}, "score": 3}`)
+
+	res = execute("user.emial co \"@\" and scroe gt 1 and score gt 2\n", "check", "-c", sample)
+	require.Equal(t, exitFailure, res.code)
+	require.Equal(t,
+		"<stdin>:1:1: Unknown attribute user.emial, did you mean \"user.email\"?\n"+
+			"<stdin>:1:23: Unknown attribute scroe, did you mean \"score\"?\n",
+		res.stderr)
+}
+
+func testFmt(t *testing.T) {
+	res := execute("", "fmt", "-r", "(a == 1)  and ((b ne 2))")
+	require.Equal(t, exitOK, res.code)
+	require.Equal(t, "a eq 1 and b ne 2\n", res.stdout)
+
+	res = execute("# keep me\nx   eq 1\n\nnot  (a or b)  \n", "fmt")
+	require.Equal(t, exitOK, res.code)
+	require.Equal(t, "# keep me\nx eq 1\n\nnot (a or b)\n", res.stdout)
+
+	res = execute("x eq\n", "fmt")
+	require.Equal(t, exitFailure, res.code)
+	require.Contains(t, res.stderr, "<stdin>:1:5:")
+}
+
+func testFmtWrite(t *testing.T) {
+	clean := writeFile(t, "clean.txt", "x eq 1\n")
+	dirty := writeFile(t, "dirty.txt", "x == 1\n")
+
+	res := execute("", "fmt", "-l", clean, dirty)
+	require.Equal(t, exitOK, res.code)
+	require.Equal(t, dirty+"\n", res.stdout)
+
+	res = execute("", "fmt", "-w", dirty)
+	require.Equal(t, exitOK, res.code)
+
+	content, err := os.ReadFile(dirty)
+	require.NoError(t, err)
+	require.Equal(t, "x eq 1\n", string(content))
+}
+
+func testAST(t *testing.T) {
+	res := execute("", "ast", "-r", `user.age gt 18 and not tags in ["a"]`)
+	require.Equal(t, exitOK, res.code, res.stderr)
+	require.Equal(t, `BinaryOp and [0:36]
+  BinaryOp gt [0:14]
+    Property user.age [0:8]
+    Literal number 18 [12:14]
+  UnaryOp not [19:36]
+    BinaryOp in [23:36]
+      Identifier tags [23:27]
+      Literal array ["a"] [31:36]
+`, res.stdout)
+}
+
+func testREPL(t *testing.T) {
+	context := writeFile(t, "ctx.json", `{"user": {"age": 17}}`)
+	input := strings.Join([]string{
+		`user.age ge 18 and user.name pr`,
+		`:set user.age 30`,
+		`:set user.name Ann`,
+		`user.age ge 18 and user.name eq "ann"`,
+		`user.age * 2`,
+		`:unset user.name`,
+		`user.name pr`,
+		`x eq`,
+		`:bogus`,
+		`:quit`,
+		`ignored eq 1`,
+	}, "\n")
+
+	res := execute(input, "repl", "-c", context)
+	require.Equal(t, exitOK, res.code, res.stderr)
+	require.Equal(t, `rule> false
+  user.age ge 18 and user.name pr => false
+    user.age ge 18 => false
+      user.age => 17
+    user.name pr => (skipped)
+      user.name => (skipped)
+rule> rule> rule> true
+  user.age ge 18 and user.name eq "ann" => true
+    user.age ge 18 => true
+      user.age => 30
+    user.name eq "ann" => true
+      user.name => "Ann"
+rule> 60
+  user.age * 2 => 60
+    user.age => 30
+rule> rule> false
+  user.name pr => false
+    user.name => (missing)
+rule> error: unexpected token EOF at position 4
+rule> error: unknown command :bogus, see :help
+rule> `, res.stdout)
+}
+
+func testREPLCompletion(t *testing.T) {
+	context := writeFile(t, "ctx.json", `{"user": {"age": 17, "address": {"city": "Rio"}}, "active": true}`)
+	input := "user.a\t\n:complete x eq 1 a\n:co\t\nzzz\t\n"
+
+	res := execute(input, "repl", "-c", context)
+	require.Equal(t, exitOK, res.code, res.stderr)
+	require.Equal(t, "rule> user.address  user.address.city  user.age\n"+
+		"rule> active  af  and  aq\n"+
+		"rule> :complete  :context\n"+
+		"rule> (no completions)\n"+
+		"rule> \n", res.stdout)
+
+	res = execute("", "repl", "-c", "-")
+	require.Equal(t, exitError, res.code)
+}
+
+func testLSP(t *testing.T) {
+	frame := func(body string) string {
+		return "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+	}
+
+	context := writeFile(t, "sample.json", `{"user": {"age": 1}}`)
+	input := frame(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`) +
+		frame(`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`) +
+		frame(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	res := execute(input, "lsp", "-c", context)
+	require.Equal(t, exitOK, res.code, res.stderr)
+	require.Contains(t, res.stdout, `"documentFormattingProvider":true`)
+
+	res = execute(frame(`{"jsonrpc":"2.0","method":"exit"}`), "lsp")
+	require.Equal(t, exitError, res.code)
+	require.Contains(t, res.stderr, "exit received before shutdown")
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/NSXBet/rule"
+)
+
+const replPrompt = "rule> "
+
+const replHelp = `Enter a rule to evaluate it against the context and print the value of every sub-expression.
+
+Commands:
+  :set path value     set an attribute; value is JSON, anything else is taken as a string
+  :unset path         remove an attribute
+  :context            print the current context
+  :complete text      list keywords and attributes completing the last word of text
+  :help               show this help
+  :quit               leave the REPL
+
+End a line with a tab to list completions for its last word.
+`
+
+//nolint:gochecknoglobals // Static list of REPL commands used for completion
+var replCommands = []string{":complete", ":context", ":help", ":quit", ":set", ":unset"}
+
+// errQuit ends the REPL loop.
+//
+//nolint:gochecknoglobals // Sentinel error
+var errQuit = errors.New("quit")
+
+func (env *environment) repl(args []string) int {
+	flags := env.flagSet("repl")
+	contextPath := flags.String("c", "", "JSON or YAML context file to load")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *contextPath == stdinPath {
+		return env.fail(errors.New("the REPL reads commands from stdin, load the context from a file"))
+	}
+
+	context, err := env.loadContext(*contextPath)
+	if err != nil {
+		return env.fail(err)
+	}
+
+	session := &replSession{engine: rule.NewEngine(), context: context, out: env.stdout}
+	scanner := bufio.NewScanner(env.stdin)
+
+	for {
+		fmt.Fprint(env.stdout, replPrompt)
+
+		if !scanner.Scan() {
+			fmt.Fprintln(env.stdout)
+			break
+		}
+
+		if err := session.handle(scanner.Text()); errors.Is(err, errQuit) {
+			break
+		} else if err != nil {
+			fmt.Fprintf(env.stdout, "error: %v\n", err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return env.fail(err)
+	}
+
+	return exitOK
+}
+
+// replSession is the state of one interactive session.
+type replSession struct {
+	engine  *rule.Engine
+	context rule.D
+	out     io.Writer
+}
+
+func (s *replSession) handle(line string) error {
+	if strings.HasSuffix(line, "\t") {
+		s.printCompletions(strings.TrimRight(line, "\t"))
+		return nil
+	}
+
+	line = strings.TrimSpace(line)
+
+	if !strings.HasPrefix(line, ":") {
+		if line == "" {
+			return nil
+		}
+
+		return s.evaluate(line)
+	}
+
+	command, argument, _ := strings.Cut(line, " ")
+	argument = strings.TrimSpace(argument)
+
+	switch command {
+	case ":set":
+		path, value, _ := strings.Cut(argument, " ")
+		return s.set(path, strings.TrimSpace(value))
+	case ":unset":
+		return s.unset(argument)
+	case ":context":
+		return s.printContext()
+	case ":complete":
+		s.printCompletions(argument)
+		return nil
+	case ":help":
+		fmt.Fprint(s.out, replHelp)
+		return nil
+	case ":quit", ":q", ":exit":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %s, see :help", command)
+	}
+}
+
+// evaluate runs a rule through Engine.Trace and prints its result followed by the trace. The
+// result is taken from the trace root, so both agree even for rules that read the clock. Rules
+// that compute a value rather than a condition print that value.
+func (s *replSession) evaluate(source string) error {
+	trace, err := s.engine.Trace(source, s.context)
+	if err != nil {
+		return err
+	}
+
+	if result, ok := trace.Value.(bool); ok {
+		fmt.Fprintln(s.out, result)
+	} else {
+		fmt.Fprintln(s.out, describe(trace.Value))
+	}
+
+	printTrace(s.out, trace, 1)
+
+	return nil
+}
+
+// printTrace writes each sub-expression with its value, indented by depth. Literals are omitted
+// since their value is already visible in the source.
+func printTrace(w io.Writer, trace *rule.TraceNode, depth int) {
+	if trace.Node.Type == rule.NodeLiteral {
+		return
+	}
+
+	var value string
+
+	switch {
+	case trace.Skipped:
+		value = "(skipped)"
+	case trace.Missing:
+		value = "(missing)"
+	default:
+		value = describe(trace.Value)
+	}
+
+	fmt.Fprintf(w, "%s%s => %s\n", strings.Repeat("  ", depth), rule.Format(trace.Node), value)
+
+	for _, child := range trace.Children {
+		printTrace(w, child, depth+1)
+	}
+}
+
+func describe(value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(encoded)
+}
+
+// set assigns a dotted path in the context, creating intermediate objects as needed.
+func (s *replSession) set(path, raw string) error {
+	if path == "" || raw == "" {
+		return errors.New("usage: :set path value")
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+
+	segments := strings.Split(path, ".")
+	current := s.context
+
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := current[segment].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			current[segment] = next
+		}
+
+		current = next
+	}
+
+	current[segments[len(segments)-1]] = normalize(value)
+
+	return nil
+}
+
+func (s *replSession) unset(path string) error {
+	if path == "" {
+		return errors.New("usage: :unset path")
+	}
+
+	segments := strings.Split(path, ".")
+	current := s.context
+
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := current[segment].(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		current = next
+	}
+
+	delete(current, segments[len(segments)-1])
+
+	return nil
+}
+
+func (s *replSession) printContext() error {
+	encoded, err := json.MarshalIndent(s.context, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(s.out, string(encoded))
+
+	return nil
+}
+
+func (s *replSession) printCompletions(text string) {
+	completions := s.complete(text)
+	if len(completions) == 0 {
+		fmt.Fprintln(s.out, "(no completions)")
+		return
+	}
+
+	fmt.Fprintln(s.out, strings.Join(completions, "  "))
+}
+
+// complete returns the keywords, attribute paths or REPL commands starting with the last word of text.
+func (s *replSession) complete(text string) []string {
+	word := text[strings.LastIndexFunc(text, isWordBoundary)+1:]
+
+	var candidates []string
+
+	if strings.HasPrefix(word, ":") && word == strings.TrimSpace(text) {
+		candidates = replCommands
+	} else {
+		candidates = append(rule.Keywords(), rule.ContextPaths(s.context)...)
+	}
+
+	var completions []string
+
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) && candidate != word {
+			completions = append(completions, candidate)
+		}
+	}
+
+	sort.Strings(completions)
+
+	return completions
+}
+
+func isWordBoundary(r rune) bool {
+	return strings.ContainsRune(" \t()[],", r)
+}
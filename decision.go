@@ -0,0 +1,141 @@
+package rule
+
+import "fmt"
+
+// HitPolicy selects which matching rules a Decision returns.
+type HitPolicy uint8
+
+const (
+	// HitFirst returns the matching rule with the highest priority.
+	HitFirst HitPolicy = iota
+	// HitAll returns every matching rule, by descending priority.
+	HitAll
+	// HitCollect returns the highest-priority matching rule of each group, by descending priority.
+	HitCollect
+	// HitUnique returns the only matching rule, and fails with ErrAmbiguousDecision when more than
+	// one rule matches.
+	HitUnique
+)
+
+func (p HitPolicy) String() string {
+	switch p {
+	case HitFirst:
+		return "first"
+	case HitAll:
+		return "all"
+	case HitCollect:
+		return "collect"
+	case HitUnique:
+		return "unique"
+	default:
+		return "unknown"
+	}
+}
+
+// Outcome describes what a decision rule produces when it matches.
+type Outcome struct {
+	Name string
+	// Priority orders the rules: higher priorities are tried and returned first.
+	Priority int
+	// Group partitions the rules for HitCollect.
+	Group string
+	// Payload is the value the rule produces, such as a discount.
+	Payload any
+}
+
+// Decision picks the outcomes of matching rules according to a hit policy. Rules with equal
+// priority keep the order in which they were added, so decisions are deterministic. A Decision is
+// not safe for concurrent modification, but once built it may be used from many goroutines.
+type Decision struct {
+	engine *Engine
+	policy HitPolicy
+	rules  []decisionRule
+}
+
+type decisionRule struct {
+	compiled *CompiledRule
+	outcome  Outcome
+}
+
+// NewDecision returns an empty decision whose rules are compiled and evaluated by the engine.
+func (e *Engine) NewDecision(policy HitPolicy) *Decision {
+	return &Decision{engine: e, policy: policy}
+}
+
+// Policy returns the hit policy of the decision.
+func (d *Decision) Policy() HitPolicy {
+	return d.policy
+}
+
+// Len returns the number of rules in the decision.
+func (d *Decision) Len() int {
+	return len(d.rules)
+}
+
+// Add compiles a rule that produces outcome when it matches.
+func (d *Decision) Add(rule string, outcome Outcome) error {
+	compiled, err := d.engine.CompileRule(rule)
+	if err != nil {
+		return err
+	}
+
+	d.AddCompiled(compiled, outcome)
+
+	return nil
+}
+
+// AddCompiled adds a compiled rule that produces outcome when it matches.
+func (d *Decision) AddCompiled(compiled *CompiledRule, outcome Outcome) {
+	position := len(d.rules)
+	for position > 0 && d.rules[position-1].outcome.Priority < outcome.Priority {
+		position--
+	}
+
+	d.rules = append(d.rules, decisionRule{})
+	copy(d.rules[position+1:], d.rules[position:])
+	d.rules[position] = decisionRule{compiled: compiled, outcome: outcome}
+}
+
+// Decide evaluates the rules against the context and returns the outcomes the hit policy selects,
+// or none when no rule matches. Rules that cannot change the result are not evaluated.
+func (d *Decision) Decide(context D) ([]Outcome, error) {
+	var (
+		outcomes []Outcome
+		decided  map[string]bool
+	)
+
+	for _, rule := range d.rules {
+		if d.policy == HitCollect && decided[rule.outcome.Group] {
+			continue
+		}
+
+		matched, err := d.engine.EvaluateCompiled(rule.compiled, context)
+		if err != nil {
+			return nil, err
+		}
+
+		if !matched {
+			continue
+		}
+
+		outcomes = append(outcomes, rule.outcome)
+
+		switch d.policy {
+		case HitFirst:
+			return outcomes, nil
+		case HitUnique:
+			if len(outcomes) > 1 {
+				return nil, fmt.Errorf("%w: %q and %q", ErrAmbiguousDecision, outcomes[0].Name, outcomes[1].Name)
+			}
+		case HitCollect:
+			if decided == nil {
+				decided = make(map[string]bool)
+			}
+
+			decided[rule.outcome.Group] = true
+		case HitAll:
+		}
+	}
+
+	return outcomes, nil
+}
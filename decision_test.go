@@ -0,0 +1,115 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func discountDecision(t *testing.T, policy HitPolicy) *Decision {
+	t.Helper()
+
+	decision := NewEngine().NewDecision(policy)
+
+	for _, rule := range []struct {
+		rule    string
+		outcome Outcome
+	}{
+		{`cart.total gt 100`, Outcome{Name: "big-cart", Priority: 10, Group: "cart", Payload: 0.05}},
+		{`tier eq "vip"`, Outcome{Name: "vip", Priority: 20, Group: "customer", Payload: 0.15}},
+		{`cart.total gt 500`, Outcome{Name: "huge-cart", Priority: 20, Group: "cart", Payload: 0.10}},
+		{`first_order`, Outcome{Name: "welcome", Priority: 5, Group: "customer", Payload: 0.20}},
+		{`tier in ["vip", "gold"]`, Outcome{Name: "loyal", Priority: 20, Group: "customer", Payload: 0.12}},
+	} {
+		require.NoError(t, decision.Add(rule.rule, rule.outcome))
+	}
+
+	return decision
+}
+
+func outcomeNames(outcomes []Outcome) []string {
+	var names []string
+	for _, outcome := range outcomes {
+		names = append(names, outcome.Name)
+	}
+
+	return names
+}
+
+func TestDecision(t *testing.T) {
+	t.Run("First", testDecisionFirst)
+	t.Run("All", testDecisionAll)
+	t.Run("Collect", testDecisionCollect)
+	t.Run("Errors", testDecisionErrors)
+}
+
+func testDecisionFirst(t *testing.T) {
+	decision := discountDecision(t, HitFirst)
+	require.Equal(t, 5, decision.Len())
+	require.Equal(t, HitFirst, decision.Policy())
+
+	tests := []struct {
+		context D
+		names   []string
+	}{
+		// vip, huge-cart and loyal tie at priority 20: the first added wins.
+		{D{"tier": "vip", "cart": D{"total": 600}}, []string{"vip"}},
+		{D{"tier": "gold", "cart": D{"total": 600}}, []string{"huge-cart"}},
+		{D{"tier": "gold"}, []string{"loyal"}},
+		{D{"cart": D{"total": 200}, "first_order": true}, []string{"big-cart"}},
+		{D{"first_order": true}, []string{"welcome"}},
+		{D{}, nil},
+	}
+
+	for _, tt := range tests {
+		outcomes, err := decision.Decide(tt.context)
+		require.NoError(t, err)
+		require.Equal(t, tt.names, outcomeNames(outcomes), "context=%v", tt.context)
+	}
+
+	outcomes, err := decision.Decide(D{"tier": "vip"})
+	require.NoError(t, err)
+	require.Equal(t, Outcome{Name: "vip", Priority: 20, Group: "customer", Payload: 0.15}, outcomes[0])
+}
+
+func testDecisionAll(t *testing.T) {
+	decision := discountDecision(t, HitAll)
+
+	outcomes, err := decision.Decide(D{"tier": "vip", "cart": D{"total": 600}, "first_order": true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"vip", "huge-cart", "loyal", "big-cart", "welcome"}, outcomeNames(outcomes))
+
+	outcomes, err = decision.Decide(D{"cart": D{"total": 150}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"big-cart"}, outcomeNames(outcomes))
+}
+
+func testDecisionCollect(t *testing.T) {
+	decision := discountDecision(t, HitCollect)
+
+	outcomes, err := decision.Decide(D{"tier": "gold", "cart": D{"total": 600}, "first_order": true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"huge-cart", "loyal"}, outcomeNames(outcomes))
+
+	outcomes, err = decision.Decide(D{"cart": D{"total": 150}, "first_order": true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"big-cart", "welcome"}, outcomeNames(outcomes))
+}
+
+func testDecisionErrors(t *testing.T) {
+	engine := NewEngine()
+	decision := engine.NewDecision(HitAll)
+
+	require.Error(t, decision.Add(`x eq`, Outcome{Name: "broken"}))
+	require.Zero(t, decision.Len())
+
+	engine.SetLimits(Limits{MaxSteps: 2})
+	require.NoError(t, decision.Add(`a eq 1 and b eq 2`, Outcome{Name: "budget"}))
+
+	_, err := decision.Decide(D{"a": 1, "b": 2})
+	require.ErrorIs(t, err, ErrStepBudgetExceeded)
+
+	require.Equal(t, "collect", HitCollect.String())
+	require.Equal(t, "unique", HitUnique.String())
+	require.Equal(t, "unknown", HitPolicy(99).String())
+}
@@ -0,0 +1,299 @@
+package rule
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultMaxInlinedNodes is the number of nodes a rule may have once the definitions it references
+// are inlined, unless Limits.MaxNodes is set. A definition that references another twice doubles
+// its size, so a short chain of them can expand to an enormous rule.
+const DefaultMaxInlinedNodes = 100000
+
+// Define registers a named rule that other rules reference as @name, so a predicate repeated
+// across rules is written once: after Define("adult_verified", `user.kyc eq "verified" and
+// user.age ge 18`), the rule `@adult_verified and order.total gt 100` reads both conditions.
+//
+// Definitions are inlined when a rule is compiled, so references cost nothing at evaluation
+// time; a rule that would grow beyond DefaultMaxInlinedNodes nodes, or Limits.MaxNodes, fails
+// with ErrTooManyNodes. A definition may reference definitions registered before it; references
+// to unknown names fail with ErrUnknownDefinition and references that would form a cycle with
+// ErrCyclicDefinition. Redefining a name recompiles the cached rules that depend on it, directly
+// or through other definitions; a dependent that no longer compiles is dropped from the cache so
+// its next evaluation reports the error. Compiled rules held elsewhere, such as in rule sets,
+// keep the definition they were compiled with.
+func (e *Engine) Define(name, rule string) error {
+	if tokens := NewLexer("@" + name).Tokenize(); len(tokens) != 2 || tokens[0].Value != name {
+		return fmt.Errorf("%w: invalid definition name %q", ErrInvalidSyntax, name)
+	}
+
+	limits := e.limits.Load()
+	if limits != nil {
+		if err := limits.checkSource(rule); err != nil {
+			return err
+		}
+	}
+
+	ast, err := parseRule(rule, limits.maxDepth())
+	if err != nil {
+		return err
+	}
+
+	e.defineMu.Lock()
+	defer e.defineMu.Unlock()
+
+	if err := e.checkReferences(name, ast); err != nil {
+		return err
+	}
+
+	_, redefined := e.definitions.Load(name)
+	e.definitions.Store(name, ast)
+
+	if redefined {
+		e.recompileDependents(name)
+	}
+
+	return nil
+}
+
+// checkReferences reports references in ast to unknown definitions, and references through which
+// the named definition would reach itself.
+func (e *Engine) checkReferences(name string, ast *ASTNode) error {
+	visited := make(map[string]bool)
+
+	for _, reference := range references(ast) {
+		referenced := reference.Value.StrValue
+
+		if _, exists := e.definitions.Load(referenced); !exists && referenced != name {
+			return e.unknownDefinition(reference)
+		}
+
+		if cycle := e.cycle(name, referenced, []string{name}, visited); cycle != nil {
+			return spanError(reference, fmt.Errorf("%w: @%s", ErrCyclicDefinition, strings.Join(cycle, " -> @")))
+		}
+	}
+
+	return nil
+}
+
+// cycle returns the chain of references from path to the named definition through referenced,
+// or nil when there is none. The registered definitions never form a cycle on their own. Visited
+// holds the definitions already known not to reach the name, so each is explored once.
+func (e *Engine) cycle(name, referenced string, path []string, visited map[string]bool) []string {
+	path = append(slices.Clone(path), referenced)
+	if referenced == name {
+		return path
+	}
+
+	if visited[referenced] {
+		return nil
+	}
+
+	visited[referenced] = true
+
+	definition, _ := e.definitions.Load(referenced)
+	for _, reference := range references(definition) {
+		if found := e.cycle(name, reference.Value.StrValue, path, visited); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// recompileDependents compiles the cached rules that inlined the named definition again.
+func (e *Engine) recompileDependents(name string) {
+	e.compiledRules.Range(func(rule string, compiled *CompiledRule) bool {
+		if !slices.Contains(compiled.references, name) {
+			return true
+		}
+
+		if recompiled, err := e.compile(rule); err == nil {
+			e.compiledRules.Store(rule, recompiled)
+		} else {
+			e.compiledRules.Delete(rule)
+		}
+
+		return true
+	})
+}
+
+// inline replaces the references in node by the definitions they name, recording every name it
+// inlines. Subtrees without references are shared with node; an inlined definition takes the
+// span of the reference it replaces, and the nodes below it have none.
+func (e *Engine) inline(node *ASTNode, inlined map[string]bool) (*ASTNode, error) {
+	if node == nil {
+		return nil, nil
+	}
+
+	if node.Type == NodeReference {
+		definition, exists := e.definitions.Load(node.Value.StrValue)
+		if !exists {
+			return nil, e.unknownDefinition(node)
+		}
+
+		inlined[node.Value.StrValue] = true
+
+		expanded, err := e.inline(definition, inlined)
+		if err != nil {
+			return nil, err
+		}
+
+		return withSpan(expanded, node.Start, node.End), nil
+	}
+
+	left, err := e.inline(node.Left, inlined)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := e.inline(node.Right, inlined)
+	if err != nil {
+		return nil, err
+	}
+
+	var children []*ASTNode
+
+	for i, child := range node.Children {
+		replaced, err := e.inline(child, inlined)
+		if err != nil {
+			return nil, err
+		}
+
+		if replaced != child && children == nil {
+			children = slices.Clone(node.Children)
+		}
+
+		if children != nil {
+			children[i] = replaced
+		}
+	}
+
+	if left == node.Left && right == node.Right && children == nil {
+		return node, nil
+	}
+
+	copied := *node
+	copied.Left, copied.Right = left, right
+
+	if children != nil {
+		copied.Children = children
+	}
+
+	return &copied, nil
+}
+
+// checkInlinedSize rejects a rule that references definitions and would have more than limit
+// nodes once they are inlined, before inline copies them.
+func (e *Engine) checkInlinedSize(ast *ASTNode, limit int) error {
+	if len(references(ast)) == 0 {
+		return nil
+	}
+
+	if size := e.inlinedSize(ast, make(map[string]int), limit); size > limit {
+		return &LimitError{Err: ErrTooManyNodes, Limit: limit}
+	}
+
+	return nil
+}
+
+// inlinedSize returns the number of nodes in node once its references are inlined, without
+// inlining them. Counting stops just above limit, and sizes memoizes the size of each definition,
+// so definitions that reference others several times are measured in linear time.
+func (e *Engine) inlinedSize(node *ASTNode, sizes map[string]int, limit int) int {
+	if node == nil {
+		return 0
+	}
+
+	if node.Type == NodeReference {
+		name := node.Value.StrValue
+		if size, measured := sizes[name]; measured {
+			return size
+		}
+
+		definition, exists := e.definitions.Load(name)
+		if !exists {
+			return 1 // inline reports the unknown definition
+		}
+
+		sizes[name] = e.inlinedSize(definition, sizes, limit)
+
+		return sizes[name]
+	}
+
+	size := 1 + e.inlinedSize(node.Left, sizes, limit) + e.inlinedSize(node.Right, sizes, limit)
+	for _, child := range node.Children {
+		size = min(size+e.inlinedSize(child, sizes, limit), limit+1)
+	}
+
+	return min(size, limit+1)
+}
+
+func (e *Engine) unknownDefinition(reference *ASTNode) error {
+	var names []string
+
+	e.definitions.Range(func(name string, _ *ASTNode) bool {
+		names = append(names, name)
+		return true
+	})
+
+	return &SyntaxError{
+		Err:         fmt.Errorf("%w @%s", ErrUnknownDefinition, reference.Value.StrValue),
+		Start:       reference.Start,
+		End:         reference.End,
+		Suggestions: suggest(reference.Value.StrValue, names),
+	}
+}
+
+// withSpan returns a copy of node that carries the given span. The nodes below it have no span,
+// since they do not appear in the rule source; otherwise profiles and editors would attribute every
+// one of them to the reference.
+func withSpan(node *ASTNode, start, end int) *ASTNode {
+	if node == nil {
+		return nil
+	}
+
+	copied := withoutSpan(node)
+	copied.Start, copied.End = start, end
+
+	return copied
+}
+
+func withoutSpan(node *ASTNode) *ASTNode {
+	if node == nil {
+		return nil
+	}
+
+	copied := *node
+	copied.Start, copied.End = 0, 0
+	copied.Left = withoutSpan(node.Left)
+	copied.Right = withoutSpan(node.Right)
+
+	if node.Children != nil {
+		copied.Children = make([]*ASTNode, len(node.Children))
+		for i, child := range node.Children {
+			copied.Children[i] = withoutSpan(child)
+		}
+	}
+
+	return &copied
+}
+
+// references returns the reference nodes in node, in source order.
+func references(node *ASTNode) []*ASTNode {
+	if node == nil {
+		return nil
+	}
+
+	if node.Type == NodeReference {
+		return []*ASTNode{node}
+	}
+
+	found := append(references(node.Left), references(node.Right)...)
+	for _, child := range node.Children {
+		found = append(found, references(child)...)
+	}
+
+	return found
+}
@@ -0,0 +1,209 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefine(t *testing.T) {
+	t.Run("Inline", testDefineInline)
+	t.Run("Unknown", testDefineUnknown)
+	t.Run("Cycle", testDefineCycle)
+	t.Run("Redefine", testDefineRedefine)
+	t.Run("InvalidName", testDefineInvalidName)
+	t.Run("Diamond", testDefineDiamond)
+	t.Run("ConcurrentEvaluate", testDefineConcurrentEvaluate)
+}
+
+func testDefineInline(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.Define("adult_verified", `user.kyc eq "verified" and user.age ge 18`))
+	require.NoError(t, engine.Define("total", `order.subtotal + order.shipping`))
+	require.NoError(t, engine.Define("big_adult_order", `@adult_verified and @total gt 100`))
+
+	context := D{
+		"user":  D{"kyc": "verified", "age": 30},
+		"order": D{"subtotal": 90, "shipping": 15},
+	}
+
+	result, err := engine.Evaluate(`@big_adult_order and not (@total gt 500)`, context)
+	require.NoError(t, err)
+	require.True(t, result)
+
+	compiled, err := engine.CompileRule(`@adult_verified or vip`)
+	require.NoError(t, err)
+	require.Equal(t, `user.kyc eq "verified" and user.age ge 18 or vip`, Format(compiled.AST))
+	require.Equal(t, "user.age", compiled.Attributes()[0].String(), "attributes see through references")
+	require.Equal(t, Span{0, len("@adult_verified")}, Span{compiled.AST.Left.Start, compiled.AST.Left.End},
+		"inlined definitions take the span of the reference")
+	require.False(t, compiled.AST.Left.Right.HasSpan(), "nodes inside a definition have no span")
+
+	ast, err := ParseRule(`@adult_verified or vip`)
+	require.NoError(t, err)
+	require.Equal(t, NodeReference, ast.Left.Type)
+	require.Equal(t, `@adult_verified or vip`, Format(ast))
+
+	_, err = NewEvaluator().Evaluate(ast, context)
+	require.ErrorIs(t, err, ErrUnknownDefinition, "only engines inline definitions")
+}
+
+func testDefineUnknown(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.Define("adult_verified", `user.age ge 18`))
+
+	_, err := engine.Evaluate(`x eq 1 and @adult_verifed`, D{})
+	require.ErrorIs(t, err, ErrUnknownDefinition)
+
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	require.Equal(t, 11, syntaxErr.Start)
+	require.Equal(t, []string{"adult_verified"}, syntaxErr.Suggestions)
+
+	require.ErrorIs(t, engine.Define("later", `@not_yet_defined`), ErrUnknownDefinition)
+
+	_, err = engine.Evaluate(`@total * 2 gt 1`, D{})
+	require.ErrorIs(t, err, ErrUnknownDefinition)
+}
+
+func testDefineCycle(t *testing.T) {
+	engine := NewEngine()
+	require.ErrorIs(t, engine.Define("self", `x eq 1 or @self`), ErrCyclicDefinition)
+
+	require.NoError(t, engine.Define("a", `x eq 1`))
+	require.NoError(t, engine.Define("b", `@a or y eq 2`))
+	require.NoError(t, engine.Define("c", `@b and @a`))
+
+	err := engine.Define("a", `z eq 3 and @c`)
+	require.ErrorIs(t, err, ErrCyclicDefinition)
+	require.ErrorContains(t, err, "@a -> @c -> @b -> @a")
+
+	result, err := engine.Evaluate(`@c`, D{"x": 1})
+	require.NoError(t, err)
+	require.True(t, result, "a rejected definition leaves the previous one in place")
+}
+
+func testDefineRedefine(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.Define("limit", `100`))
+	require.NoError(t, engine.Define("over", `amount gt @limit`))
+
+	compiled, err := engine.CompileRule(`@over and country eq "BR"`)
+	require.NoError(t, err)
+
+	unrelated, err := engine.CompileRule(`amount gt 0`)
+	require.NoError(t, err)
+
+	context := D{"amount": 150, "country": "BR"}
+
+	result, err := engine.Evaluate(`@over and country eq "BR"`, context)
+	require.NoError(t, err)
+	require.True(t, result)
+
+	require.NoError(t, engine.Define("limit", `200`))
+
+	result, err = engine.Evaluate(`@over and country eq "BR"`, context)
+	require.NoError(t, err)
+	require.False(t, result, "dependents through other definitions are recompiled")
+
+	recompiled, err := engine.CompileRule(`@over and country eq "BR"`)
+	require.NoError(t, err)
+	require.NotSame(t, compiled, recompiled)
+
+	stillCached, err := engine.CompileRule(`amount gt 0`)
+	require.NoError(t, err)
+	require.Same(t, unrelated, stillCached, "rules without references are left alone")
+
+	_, err = engine.CompileRule(`@limit * 2 gt 1`)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.Define("limit", `"high"`))
+
+	_, err = engine.Evaluate(`@limit * 2 gt 1`, context)
+	require.ErrorIs(t, err, ErrInvalidArithmeticOp, "dependents that no longer compile report the error")
+}
+
+func testDefineInvalidName(t *testing.T) {
+	engine := NewEngine()
+
+	for _, name := range []string{"", "a.b", "1st", "has space", "a-b"} {
+		require.ErrorIs(t, engine.Define(name, `x eq 1`), ErrInvalidSyntax, name)
+	}
+
+	require.Error(t, engine.Define("broken", `x eq`))
+}
+
+func testDefineDiamond(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.Define("d0", `x eq 1`))
+
+	for i := 1; i <= 64; i++ {
+		require.NoError(t, engine.Define(fmt.Sprintf("d%d", i), fmt.Sprintf(`@d%d and @d%d`, i-1, i-1)))
+	}
+
+	err := engine.Define("d0", `@d64`)
+	require.ErrorIs(t, err, ErrCyclicDefinition, "each definition is explored once")
+
+	result, err := engine.Evaluate(`@d10`, D{"x": 1})
+	require.NoError(t, err)
+	require.True(t, result)
+
+	_, err = engine.CompileRule(`@d64 or y eq 2`)
+	require.ErrorIs(t, err, ErrTooManyNodes, "exponential expansions are rejected before inlining")
+
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	require.Equal(t, DefaultMaxInlinedNodes, limitErr.Limit)
+
+	engine.SetLimits(Limits{MaxNodes: 100})
+
+	_, err = engine.CompileRule(`@d4`)
+	require.NoError(t, err)
+
+	_, err = engine.CompileRule(`@d6`)
+	require.ErrorIs(t, err, ErrTooManyNodes)
+}
+
+func testDefineConcurrentEvaluate(t *testing.T) {
+	const evaluators = 4
+
+	engine := NewEngine()
+	require.NoError(t, engine.Define("limit", `100`))
+
+	var stop atomic.Bool
+
+	done := make(chan error, evaluators)
+
+	for range evaluators {
+		go func() {
+			for !stop.Load() {
+				_, err := engine.Evaluate(`amount gt @limit * 1`, D{"amount": 150})
+				if err != nil && !errors.Is(err, ErrInvalidArithmeticOp) {
+					done <- err
+					return
+				}
+			}
+
+			done <- nil
+		}()
+	}
+
+	for i := range 5000 {
+		// A limit that is not a number drops the dependents from the cache
+		limit := `100`
+		if i%2 == 0 {
+			limit = `"high"`
+		}
+
+		require.NoError(t, engine.Define("limit", limit))
+	}
+
+	stop.Store(true)
+
+	for range evaluators {
+		require.NoError(t, <-done)
+	}
+}
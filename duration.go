@@ -0,0 +1,274 @@
+package rule
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a span of calendar time. Months and days are calendar units: adding a month keeps
+// the day of the month, moving to the last day of a shorter month, so one month after January 31
+// is the last day of February. Clock holds the hours, minutes and seconds.
+type Duration struct {
+	Months int
+	Days   int
+	Clock  time.Duration
+}
+
+// durationUnit is a designator of a duration literal and the span it multiplies.
+type durationUnit struct {
+	designator byte
+	months     int
+	days       int
+	clock      time.Duration
+}
+
+//nolint:gochecknoglobals // Static duration unit tables, in the order the components must appear
+var (
+	compactUnits = []durationUnit{
+		{designator: 'w', days: daysPerWeek},
+		{designator: 'd', days: 1},
+		{designator: 'h', clock: time.Hour},
+		{designator: 'm', clock: time.Minute},
+		{designator: 's', clock: time.Second},
+	}
+	isoDateUnits = []durationUnit{
+		{designator: 'Y', months: monthsPerYear},
+		{designator: 'M', months: 1},
+		{designator: 'W', days: daysPerWeek},
+		{designator: 'D', days: 1},
+	}
+	isoTimeUnits = []durationUnit{
+		{designator: 'H', clock: time.Hour},
+		{designator: 'M', clock: time.Minute},
+		{designator: 'S', clock: time.Second},
+	}
+)
+
+const (
+	daysPerWeek   = 7
+	monthsPerYear = 12
+)
+
+// ParseDuration parses a duration literal: either compact units such as 30d, 12h, 1h30m or 1.5s
+// (w, d, h, m and s, largest first), or an ISO 8601 duration such as P1M or P1Y2M10DT2H30M.
+// Compact literals may be negative.
+func ParseDuration(s string) (Duration, error) {
+	var (
+		d  Duration
+		ok bool
+	)
+
+	if strings.HasPrefix(s, "P") {
+		d, ok = parseISODuration(s[1:])
+	} else {
+		d, ok = parseCompactDuration(s)
+	}
+
+	if !ok {
+		return Duration{}, fmt.Errorf("%w: invalid duration %q", ErrInvalidSyntax, s)
+	}
+
+	return d, nil
+}
+
+func parseCompactDuration(s string) (Duration, bool) {
+	negative := strings.HasPrefix(s, "-")
+	if negative {
+		s = s[1:]
+	}
+
+	var d Duration
+
+	rest, count, ok := parseDurationComponents(s, compactUnits, &d)
+	if !ok || count == 0 || rest != "" {
+		return Duration{}, false
+	}
+
+	if negative {
+		d = d.negate()
+	}
+
+	return d, true
+}
+
+func parseISODuration(s string) (Duration, bool) {
+	var d Duration
+
+	rest, count, ok := parseDurationComponents(s, isoDateUnits, &d)
+	if !ok {
+		return Duration{}, false
+	}
+
+	if strings.HasPrefix(rest, "T") {
+		var clockCount int
+
+		rest, clockCount, ok = parseDurationComponents(rest[1:], isoTimeUnits, &d)
+		if !ok || clockCount == 0 {
+			return Duration{}, false
+		}
+
+		count += clockCount
+	}
+
+	return d, count > 0 && rest == ""
+}
+
+// parseDurationComponents adds the leading number and designator pairs of s to d, each designator
+// coming after the previous one in units. Only seconds may have a fraction. It returns the rest of
+// s and the number of components read.
+func parseDurationComponents(s string, units []durationUnit, d *Duration) (string, int, bool) {
+	count := 0
+	next := 0
+
+	for s != "" && isDigit(s[0]) {
+		end := 0
+		for end < len(s) && (isDigit(s[end]) || s[end] == '.') {
+			end++
+		}
+
+		if end == len(s) {
+			return "", 0, false
+		}
+
+		index := next
+		for index < len(units) && units[index].designator != s[end] {
+			index++
+		}
+
+		if index == len(units) {
+			return "", 0, false
+		}
+
+		if !addDurationComponent(s[:end], units[index], d) {
+			return "", 0, false
+		}
+
+		s = s[end+1:]
+		next = index + 1
+		count++
+	}
+
+	return s, count, true
+}
+
+func addDurationComponent(number string, unit durationUnit, d *Duration) bool {
+	if unit.clock == time.Second && strings.Contains(number, ".") {
+		seconds, err := strconv.ParseFloat(number, 64)
+		if err != nil || seconds*float64(time.Second) >= float64(math.MaxInt64-d.Clock) {
+			return false
+		}
+
+		d.Clock += time.Duration(math.Round(seconds * float64(time.Second)))
+
+		return true
+	}
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n > math.MaxInt32 {
+		return false
+	}
+
+	if unit.clock != 0 {
+		// Components are never negative, so only the running sum can overflow
+		if int64(n) > int64(math.MaxInt64-d.Clock)/int64(unit.clock) {
+			return false
+		}
+
+		d.Clock += time.Duration(n) * unit.clock
+	}
+
+	d.Months += n * unit.months
+	d.Days += n * unit.days
+
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (d Duration) negate() Duration {
+	return Duration{Months: -d.Months, Days: -d.Days, Clock: -d.Clock}
+}
+
+// String returns the literal for the duration: the ISO 8601 form when it has months, and compact
+// units otherwise.
+func (d Duration) String() string {
+	if d == (Duration{}) {
+		return "0s"
+	}
+
+	var sb strings.Builder
+
+	if d.Months < 0 || d.Days < 0 || d.Clock < 0 {
+		sb.WriteByte('-')
+
+		d = d.negate()
+	}
+
+	if d.Months == 0 {
+		writeDurationComponent(&sb, int64(d.Days), 'd')
+		writeClock(&sb, d.Clock, 'h', 'm', 's')
+
+		return sb.String()
+	}
+
+	sb.WriteByte('P')
+	writeDurationComponent(&sb, int64(d.Months/monthsPerYear), 'Y')
+	writeDurationComponent(&sb, int64(d.Months%monthsPerYear), 'M')
+	writeDurationComponent(&sb, int64(d.Days), 'D')
+
+	if d.Clock != 0 {
+		sb.WriteByte('T')
+		writeClock(&sb, d.Clock, 'H', 'M', 'S')
+	}
+
+	return sb.String()
+}
+
+func writeClock(sb *strings.Builder, clock time.Duration, hour, minute, second byte) {
+	writeDurationComponent(sb, int64(clock/time.Hour), hour)
+	writeDurationComponent(sb, int64(clock%time.Hour/time.Minute), minute)
+
+	seconds := clock % time.Minute
+	if seconds%time.Second != 0 {
+		sb.WriteString(strconv.FormatFloat(seconds.Seconds(), 'f', -1, 64))
+		sb.WriteByte(second)
+
+		return
+	}
+
+	writeDurationComponent(sb, int64(seconds/time.Second), second)
+}
+
+func writeDurationComponent(sb *strings.Builder, n int64, designator byte) {
+	if n == 0 {
+		return
+	}
+
+	sb.WriteString(strconv.FormatInt(n, 10))
+	sb.WriteByte(designator)
+}
+
+// addTo adds sign times the duration to t: months first, then days, then the clock.
+func (d Duration) addTo(t time.Time, sign int) time.Time {
+	if d.Months != 0 {
+		t = addMonths(t, sign*d.Months)
+	}
+
+	return t.AddDate(0, 0, sign*d.Days).Add(time.Duration(sign) * d.Clock)
+}
+
+// addMonths moves t by whole months, keeping the day of the month when the target month has it
+// and using its last day otherwise.
+func addMonths(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+	first := time.Date(year, month+time.Month(months), 1, hour, minute, second, t.Nanosecond(), t.Location())
+	last := first.AddDate(0, 1, -1).Day()
+
+	return first.AddDate(0, 0, min(day, last)-1)
+}
@@ -0,0 +1,212 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuration(t *testing.T) {
+	t.Run("Parse", testParseDuration)
+	t.Run("ParseInvalid", testParseDurationInvalid)
+	t.Run("String", testDurationString)
+	t.Run("CalendarMonths", testDurationCalendarMonths)
+	t.Run("DateArithmetic", testDurationDateArithmetic)
+	t.Run("RelativeToNow", testDurationRelativeToNow)
+	t.Run("Validation", testDurationValidation)
+}
+
+func testParseDuration(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected Duration
+	}{
+		{"30d", Duration{Days: 30}},
+		{"12h", Duration{Clock: 12 * time.Hour}},
+		{"15m", Duration{Clock: 15 * time.Minute}},
+		{"2w", Duration{Days: 14}},
+		{"1d12h30m", Duration{Days: 1, Clock: 12*time.Hour + 30*time.Minute}},
+		{"1.5s", Duration{Clock: 1500 * time.Millisecond}},
+		{"-7d", Duration{Days: -7}},
+		{"P1M", Duration{Months: 1}},
+		{"P1Y2M10DT2H30M", Duration{Months: 14, Days: 10, Clock: 2*time.Hour + 30*time.Minute}},
+		{"PT15M", Duration{Clock: 15 * time.Minute}},
+		{"P2W", Duration{Days: 14}},
+		{"2562047h47m16s", Duration{Clock: 2562047*time.Hour + 47*time.Minute + 16*time.Second}},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseDuration(tt.literal)
+		require.NoError(t, err, tt.literal)
+		require.Equal(t, tt.expected, d, tt.literal)
+	}
+}
+
+func testParseDurationInvalid(t *testing.T) {
+	invalid := []string{"", "d", "30", "30x", "30m1h", "1d1d", "1.5h", "P", "PT", "P1H", "PT1D", "p1m", "P1m",
+		"2562047h59m", "2562047h47m16.9s", "PT2562047H47M17S", "9223372036.9s"}
+
+	for _, literal := range invalid {
+		_, err := ParseDuration(literal)
+		require.ErrorIs(t, err, ErrInvalidSyntax, literal)
+	}
+}
+
+func testDurationString(t *testing.T) {
+	tests := []struct {
+		duration Duration
+		expected string
+	}{
+		{Duration{}, "0s"},
+		{Duration{Days: 30}, "30d"},
+		{Duration{Clock: 90 * time.Minute}, "1h30m"},
+		{Duration{Days: -7}, "-7d"},
+		{Duration{Clock: 1500 * time.Millisecond}, "1.5s"},
+		{Duration{Months: 14, Days: 1, Clock: time.Hour}, "P1Y2M1DT1H"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, tt.duration.String())
+
+		parsed, err := ParseDuration(tt.expected)
+		require.NoError(t, err)
+		require.Equal(t, tt.duration, parsed)
+	}
+}
+
+func testDurationCalendarMonths(t *testing.T) {
+	tests := []struct {
+		from     string
+		months   int
+		expected string
+	}{
+		{"2024-01-31T10:00:00Z", 1, "2024-02-29T10:00:00Z"},
+		{"2023-01-31T10:00:00Z", 1, "2023-02-28T10:00:00Z"},
+		{"2024-03-31T10:00:00Z", -1, "2024-02-29T10:00:00Z"},
+		{"2024-12-31T10:00:00Z", 2, "2025-02-28T10:00:00Z"},
+		{"2024-02-29T10:00:00Z", 12, "2025-02-28T10:00:00Z"},
+		{"2024-01-15T10:00:00Z", 1, "2024-02-15T10:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		from, err := time.Parse(time.RFC3339, tt.from)
+		require.NoError(t, err)
+
+		shifted := Duration{Months: tt.months}.addTo(from, 1)
+		require.Equal(t, tt.expected, shifted.Format(time.RFC3339), "%s + %d months", tt.from, tt.months)
+	}
+}
+
+func testDurationDateArithmetic(t *testing.T) {
+	engine := NewEngine()
+	context := D{
+		"created_at": "2024-01-31T10:00:00Z",
+		"expires_at": time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC),
+		"renewed":    int64(1706695200), // 2024-01-31T10:00:00Z
+		"window":     72 * time.Hour,
+	}
+
+	tests := []struct {
+		rule     string
+		expected bool
+	}{
+		{`expires_at dq created_at + P1M`, true},
+		{`expires_at be created_at + 30d`, true},
+		{`expires_at af created_at + 28d`, true},
+		{`created_at dq expires_at - P1M`, false},
+		{`expires_at - P1M dq "2024-01-29T10:00:00Z"`, true},
+		{`expires_at dq 29d + created_at`, true},
+		{`renewed + P1M dq expires_at`, true},
+		{`created_at + window dq "2024-02-03T10:00:00Z"`, true},
+		{`created_at + 12h + 30m dq "2024-01-31T22:30:00Z"`, true},
+		{`missing + 1d dq expires_at`, false},
+		{`window eq 3d`, false},
+		{`window eq 72h`, true},
+		{`window in [1h, 72h]`, true},
+	}
+
+	for _, tt := range tests {
+		result, err := engine.Evaluate(tt.rule, context)
+		require.NoError(t, err, tt.rule)
+		require.Equal(t, tt.expected, result, tt.rule)
+	}
+
+	value, err := engine.EvaluateValue(`created_at + P1M1DT2H`, context)
+	require.NoError(t, err)
+	require.Equal(t, Value{Type: ValueString, StrValue: "2024-03-01T12:00:00Z"}, value)
+
+	value, err = engine.EvaluateValue(`created_at + PT1H30M1.5S`, context)
+	require.NoError(t, err)
+	require.Equal(t, Value{Type: ValueString, StrValue: "2024-01-31T11:30:01.5Z"}, value,
+		"ISO 8601 literals may have fractional seconds, as ParseDuration accepts")
+
+	value, err = engine.EvaluateValue(`90m`, nil)
+	require.NoError(t, err)
+	require.Equal(t, Value{Type: ValueDuration, DurValue: Duration{Clock: 90 * time.Minute}}, value)
+}
+
+func testDurationRelativeToNow(t *testing.T) {
+	engine := NewEngine()
+	now := time.Now().UTC()
+	context := D{
+		"last_login": now.Add(-72 * time.Hour),
+		"signed_up":  now.AddDate(0, -2, 0).Format(time.RFC3339),
+	}
+
+	tests := []struct {
+		rule     string
+		expected bool
+	}{
+		{`last_login af now() - 7d`, true},
+		{`last_login af now() - 2d`, false},
+		{`last_login be now()`, true},
+		{`last_login dl 7d`, true},
+		{`last_login dl 1d12h`, false},
+		{`last_login dg 1d12h`, true},
+		{`signed_up dl P1M`, false},
+		{`signed_up dg P1M`, true},
+		{`signed_up dl P1M1D`, false},
+		{`signed_up dl P3M`, true},
+	}
+
+	for _, tt := range tests {
+		result, err := engine.Evaluate(tt.rule, context)
+		require.NoError(t, err, tt.rule)
+		require.Equal(t, tt.expected, result, tt.rule)
+	}
+}
+
+func testDurationValidation(t *testing.T) {
+	tests := []struct {
+		rule string
+		err  error
+	}{
+		{`7d + 7d dq x`, ErrInvalidArithmeticOp},
+		{`7d - x dq y`, ErrInvalidArithmeticOp},
+		{`x + 7d * 2 dq y`, ErrInvalidArithmeticOp},
+		{`now() + 1 dq y`, ErrInvalidArithmeticOp},
+		{`true + 1d dq y`, ErrInvalidArithmeticOp},
+		{`x in now()`, ErrInvalidInOperand},
+		{`7d co "d"`, ErrInvalidStringOp},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseRule(tt.rule)
+		require.ErrorIs(t, err, tt.err, tt.rule)
+	}
+
+	_, err := ParseRule(`x af nwo()`)
+
+	var syntaxErr *SyntaxError
+	require.ErrorAs(t, err, &syntaxErr)
+	require.Equal(t, []string{"now"}, syntaxErr.Suggestions)
+
+	_, err = ParseRule(`x af now(1)`)
+	require.Error(t, err)
+
+	for _, rule := range []string{`x af now() - 7d`, `"2024-01-31T10:00:00Z" + P1M eq x`, `x.P1D eq 1`} {
+		_, err := ParseRule(rule)
+		require.NoError(t, err, rule)
+	}
+}
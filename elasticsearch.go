@@ -0,0 +1,354 @@
+package rule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ElasticsearchTranslator converts a parsed rule into an Elasticsearch bool query.
+//
+// String equality and pattern operators use case-insensitive term and wildcard queries, and
+// negative operators require the field to exist because comparisons against missing attributes
+// are always false in the engine. The dl/dg operators become range queries using date math
+// relative to "now", so they stay correct when the query is cached.
+type ElasticsearchTranslator struct {
+	// Fields maps attribute paths such as "user.age" to index fields. Unmapped attributes use their dotted path.
+	Fields map[string]string
+}
+
+func NewElasticsearchTranslator() *ElasticsearchTranslator {
+	return &ElasticsearchTranslator{Fields: make(map[string]string)}
+}
+
+// Translate returns the Elasticsearch query document for the rule.
+func (t *ElasticsearchTranslator) Translate(node *ASTNode) (D, error) {
+	if node == nil {
+		return nil, ErrInvalidNode
+	}
+
+	return t.translateNode(node)
+}
+
+// TranslateRule parses the rule and translates it into an Elasticsearch query document.
+func (t *ElasticsearchTranslator) TranslateRule(rule string) (D, error) {
+	ast, err := ParseRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Translate(ast)
+}
+
+func (t *ElasticsearchTranslator) translateNode(node *ASTNode) (D, error) {
+	switch node.Type {
+	case NodeBinaryOp:
+		if node.Operator == AND || node.Operator == OR {
+			return t.translateLogical(node)
+		}
+
+		return t.translateComparison(node)
+	case NodeUnaryOp:
+		return t.translateUnary(node)
+	case NodeLiteral:
+		if node.Value.Type != ValueBoolean {
+			return nil, unsupportedTranslation("non-boolean literal used as a condition")
+		}
+
+		return esConstant(node.Value.BoolValue), nil
+	case NodeIdentifier, NodeProperty:
+		path, _ := attributePath(node)
+		return esTerm(t.field(path), true), nil
+	case NodeArray:
+		return nil, unsupportedTranslation("array used as a condition")
+	case NodeConditional:
+		return nil, unsupportedTranslation("conditional expression")
+	case NodeParameter:
+		return nil, unsupportedTranslation("parameter")
+	case NodeReference:
+		return nil, unsupportedTranslation("reference to a definition")
+	case NodeLet, NodeVariable:
+		return nil, unsupportedTranslation("let expression")
+	case NodeCall:
+		return nil, unsupportedTranslation("function " + node.Value.StrValue)
+	case NodeError:
+		return nil, ErrInvalidSyntax
+	default:
+		return nil, ErrInvalidNode
+	}
+}
+
+func (t *ElasticsearchTranslator) translateLogical(node *ASTNode) (D, error) {
+	operands := flattenLogical(node, node.Operator, nil)
+	clauses := make([]any, 0, len(operands))
+
+	for _, operand := range operands {
+		clause, err := t.translateNode(operand)
+		if err != nil {
+			return nil, err
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	if node.Operator == OR {
+		return esShould(clauses), nil
+	}
+
+	return D{"bool": D{"filter": clauses}}, nil
+}
+
+func (t *ElasticsearchTranslator) translateUnary(node *ASTNode) (D, error) {
+	switch node.Operator { //nolint:exhaustive // only NOT and PR are unary operators
+	case NOT:
+		operand, err := t.translateNode(node.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		return D{"bool": D{"must_not": []any{operand}}}, nil
+	case PR:
+		path, ok := attributePath(node.Left)
+		if !ok {
+			return nil, unsupportedTranslation("presence check on an expression")
+		}
+
+		return esExists(t.field(path)), nil
+	default:
+		return nil, unsupportedTranslation("unary operator " + node.Operator.String())
+	}
+}
+
+func (t *ElasticsearchTranslator) translateComparison(node *ASTNode) (D, error) {
+	cmp, err := newComparison(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmp.constant() {
+		value, foldErr := foldConstant(node)
+		if foldErr != nil {
+			return nil, foldErr
+		}
+
+		return esConstant(value), nil
+	}
+
+	if cmp.other != nil && cmp.field != nil {
+		return nil, unsupportedTranslation("comparing two attributes requires a script query")
+	}
+
+	switch cmp.op { //nolint:exhaustive // logical operators are handled by translateLogical
+	case EQ, EQUALS, NE, NOT_EQUALS, DQ, DN:
+		return t.translateEquality(&cmp)
+	case LT, GT, LE, GE, BE, BQ, AF, AQ:
+		return t.translateRange(&cmp)
+	case CO, SW, EW:
+		return t.translateWildcard(&cmp)
+	case IN, NOT_IN:
+		return t.translateMembership(&cmp)
+	case DL, DG:
+		return t.translateDays(&cmp)
+	default:
+		return nil, unsupportedTranslation("binary operator " + cmp.op.String())
+	}
+}
+
+func (t *ElasticsearchTranslator) translateEquality(cmp *comparison) (D, error) {
+	field := t.field(cmp.field)
+
+	var query D
+
+	if isDateTimeOperator(cmp.op) {
+		timestamp, ok := literalDateTime(cmp.right)
+		if !ok {
+			return nil, unsupportedTranslation(cmp.op.String() + " with a literal that is not a datetime")
+		}
+
+		value := timestamp.Format(time.RFC3339Nano)
+		query = D{"range": D{field: D{"gte": value, "lte": value}}}
+	} else {
+		query = esTerm(field, literalToAny(cmp.right))
+	}
+
+	if cmp.op == NE || cmp.op == NOT_EQUALS || cmp.op == DN {
+		return esExistsButNot(field, query), nil
+	}
+
+	return query, nil
+}
+
+func (t *ElasticsearchTranslator) translateRange(cmp *comparison) (D, error) {
+	value := literalToAny(cmp.right)
+
+	if isDateTimeOperator(cmp.op) {
+		timestamp, ok := literalDateTime(cmp.right)
+		if !ok {
+			return nil, unsupportedTranslation(cmp.op.String() + " with a literal that is not a datetime")
+		}
+
+		value = timestamp.Format(time.RFC3339Nano)
+	}
+
+	return D{"range": D{t.field(cmp.field): D{esRangeKey(cmp.op): value}}}, nil
+}
+
+func (t *ElasticsearchTranslator) translateWildcard(cmp *comparison) (D, error) {
+	if cmp.field == nil || cmp.right == nil || cmp.right.Type != ValueString {
+		return nil, unsupportedTranslation(
+			cmp.op.String() + " requires an attribute on the left and a string literal on the right",
+		)
+	}
+
+	pattern := escapeWildcard(cmp.right.StrValue)
+
+	switch cmp.op { //nolint:exhaustive // only called for pattern operators
+	case CO:
+		pattern = "*" + pattern + "*"
+	case SW:
+		pattern += "*"
+	case EW:
+		pattern = "*" + pattern
+	default:
+	}
+
+	return D{"wildcard": D{t.field(cmp.field): D{"value": pattern, "case_insensitive": true}}}, nil
+}
+
+func (t *ElasticsearchTranslator) translateMembership(cmp *comparison) (D, error) {
+	// Elasticsearch flattens arrays, so a term query on an array field matches any element
+	if cmp.field == nil && cmp.other != nil {
+		field := t.field(cmp.other)
+		query := esTerm(field, literalToAny(cmp.left))
+
+		if cmp.op == NOT_IN {
+			return esExistsButNot(field, query), nil
+		}
+
+		return query, nil
+	}
+
+	if cmp.field == nil || cmp.right == nil || cmp.right.Type != ValueArray {
+		return nil, unsupportedTranslation(cmp.op.String() + " requires an array literal or an array attribute")
+	}
+
+	field := t.field(cmp.field)
+	query := esMembership(field, cmp.right.ArrValue)
+
+	if cmp.op == NOT_IN {
+		return esExistsButNot(field, query), nil
+	}
+
+	return query, nil
+}
+
+func (t *ElasticsearchTranslator) translateDays(cmp *comparison) (D, error) {
+	if cmp.field == nil || cmp.right == nil {
+		return nil, unsupportedTranslation(
+			cmp.op.String() + " requires an attribute on the left and a numeric literal on the right",
+		)
+	}
+
+	days, ok := literalDays(cmp.right)
+	if !ok {
+		return nil, unsupportedTranslation(cmp.op.String() + " with a non-numeric threshold")
+	}
+
+	// Date math only accepts integers, so express the threshold in seconds
+	seconds := int64(days * hoursPerDay * secondsPerHour)
+	threshold := "now-" + strconv.FormatInt(seconds, 10) + "s"
+
+	key := "gt"
+	if cmp.op == DG {
+		key = "lt"
+	}
+
+	return D{"range": D{t.field(cmp.field): D{key: threshold}}}, nil
+}
+
+func (t *ElasticsearchTranslator) field(path []string) string {
+	key := strings.Join(path, ".")
+	if field, mapped := t.Fields[key]; mapped {
+		return field
+	}
+
+	return key
+}
+
+// esMembership matches string elements with case-insensitive term queries and every other element
+// with a single terms query.
+func esMembership(field string, elements []Value) D {
+	var (
+		clauses []any
+		others  = make([]any, 0, len(elements))
+	)
+
+	for i := range elements {
+		if elements[i].Type == ValueString {
+			clauses = append(clauses, esTerm(field, elements[i].StrValue))
+		} else {
+			others = append(others, literalToAny(&elements[i]))
+		}
+	}
+
+	if len(others) > 0 || len(clauses) == 0 {
+		clauses = append(clauses, D{"terms": D{field: others}})
+	}
+
+	if len(clauses) == 1 {
+		query, _ := clauses[0].(D)
+		return query
+	}
+
+	return esShould(clauses)
+}
+
+func esTerm(field string, value any) D {
+	condition := D{"value": value}
+	if _, isString := value.(string); isString {
+		condition["case_insensitive"] = true
+	}
+
+	return D{"term": D{field: condition}}
+}
+
+func esExists(field string) D {
+	return D{"exists": D{"field": field}}
+}
+
+func esExistsButNot(field string, query D) D {
+	return D{"bool": D{"filter": []any{esExists(field)}, "must_not": []any{query}}}
+}
+
+func esShould(clauses []any) D {
+	return D{"bool": D{"should": clauses, "minimum_should_match": 1}}
+}
+
+func esConstant(value bool) D {
+	if value {
+		return D{"match_all": D{}}
+	}
+
+	return D{"match_none": D{}}
+}
+
+func esRangeKey(op TokenType) string {
+	switch op { //nolint:exhaustive // only called for range operators
+	case LT, BE:
+		return "lt"
+	case GT, AF:
+		return "gt"
+	case LE, BQ:
+		return "lte"
+	case GE, AQ:
+		return "gte"
+	default:
+		return ""
+	}
+}
+
+// escapeWildcard escapes the wildcard query metacharacters so the literal is matched verbatim.
+func escapeWildcard(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`)
+	return replacer.Replace(s)
+}
@@ -1,6 +1,10 @@
 package rule
 
 import (
+	"context"
+	"sync"
+	"sync/atomic"
+
 	"github.com/puzpuzpuz/xsync/v4"
 )
 
@@ -11,17 +15,34 @@ type D = map[string]any
 type CompiledRule struct {
 	AST  *ASTNode
 	Hash uint64
+
+	// source is the rule text the engine compiled; it is empty for rules built otherwise.
+	source string
+	// attributes is computed at compile time, see Attributes.
+	attributes []Attribute
+	// references lists the definitions inlined into the rule, directly or through other
+	// definitions, so the engine knows which rules to recompile when one of them changes.
+	references []string
 }
 
 type Engine struct {
 	compiledRules *xsync.Map[string, *CompiledRule]
 	evaluator     *Evaluator
+	schema        atomic.Pointer[Schema]
+	limits        atomic.Pointer[Limits]
+	optimizer     atomic.Pointer[Optimizer]
+	profiler      atomic.Pointer[Profiler]
+	definitions   *xsync.Map[string, *ASTNode]
+	// defineMu serializes Define, so cycle checks always see every other definition, and keeps
+	// rules from being compiled and cached while a definition changes.
+	defineMu sync.RWMutex
 }
 
 func NewEngine() *Engine {
 	return &Engine{
 		compiledRules: xsync.NewMap[string, *CompiledRule](),
 		evaluator:     NewEvaluator(),
+		definitions:   xsync.NewMap[string, *ASTNode](),
 	}
 }
 
@@ -30,33 +51,45 @@ func (e *Engine) AddQuery(rule string) error {
 		return nil // Already compiled
 	}
 
-	ast, err := ParseRule(rule)
+	_, err := e.compileAndCache(rule)
+
+	return err
+}
+
+func (e *Engine) Evaluate(rule string, context D) (bool, error) {
+	compiled, err := e.CompileRule(rule)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	compiled := &CompiledRule{
-		AST:  ast,
-		Hash: hash(rule),
-	}
+	return e.EvaluateCompiled(compiled, context)
+}
 
-	e.compiledRules.Store(rule, compiled)
+// EvaluateContext evaluates a rule like Evaluate, but stops with ctx.Err() once ctx is cancelled
+// and enforces the step budget of the engine limits.
+func (e *Engine) EvaluateContext(ctx context.Context, rule string, data D) (bool, error) {
+	compiled, err := e.CompileRule(rule)
+	if err != nil {
+		return false, err
+	}
 
-	return nil
+	return e.EvaluateCompiledContext(ctx, compiled, data)
 }
 
-func (e *Engine) Evaluate(rule string, context D) (bool, error) {
-	compiled, exists := e.compiledRules.Load(rule)
-	if !exists {
-		// Compile just-in-time
-		if err := e.AddQuery(rule); err != nil {
-			return false, err
-		}
+// EvaluateCompiledContext is the EvaluateContext counterpart of EvaluateCompiled.
+func (e *Engine) EvaluateCompiledContext(ctx context.Context, compiled *CompiledRule, data D) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 
-		compiled, _ = e.compiledRules.Load(rule)
+	var maxSteps int
+	if limits := e.limits.Load(); limits != nil {
+		maxSteps = limits.MaxSteps
 	}
 
-	return e.evaluator.Evaluate(compiled.AST, context)
+	evaluator := Evaluator{meter: &meter{ctx: ctx, maxSteps: maxSteps}, profile: e.profile(compiled)}
+
+	return evaluator.Evaluate(compiled.AST, data)
 }
 
 func (e *Engine) CompileRule(rule string) (*CompiledRule, error) {
@@ -64,23 +97,133 @@ func (e *Engine) CompileRule(rule string) (*CompiledRule, error) {
 		return compiled, nil
 	}
 
-	ast, err := ParseRule(rule)
+	return e.compileAndCache(rule)
+}
+
+// compileAndCache compiles a rule and stores it in the cache. It holds defineMu for reading, so a
+// concurrent Define either sees the stored rule when it recompiles dependents, or happens before
+// the rule reads the definitions.
+func (e *Engine) compileAndCache(rule string) (*CompiledRule, error) {
+	e.defineMu.RLock()
+	defer e.defineMu.RUnlock()
+
+	compiled, err := e.compile(rule)
 	if err != nil {
 		return nil, err
 	}
 
-	compiled := &CompiledRule{
-		AST:  ast,
-		Hash: hash(rule),
+	e.compiledRules.Store(rule, compiled)
+
+	return compiled, nil
+}
+
+// compile parses a rule, inlines the definitions it references, checks it against the limits and the
+// schema and optimizes it, if they are set.
+func (e *Engine) compile(rule string) (*CompiledRule, error) {
+	limits := e.limits.Load()
+	if limits != nil {
+		if err := limits.checkSource(rule); err != nil {
+			return nil, err
+		}
 	}
 
-	e.compiledRules.Store(rule, compiled)
+	ast, err := parseRule(rule, limits.maxDepth())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.checkInlinedSize(ast, limits.maxInlinedNodes()); err != nil {
+		return nil, err
+	}
+
+	references := make(map[string]bool)
+
+	if ast, err = e.inline(ast, references); err != nil {
+		return nil, err
+	}
+
+	if len(references) > 0 {
+		// Inlined definitions are only known to be valid on their own
+		if err := ValidateAST(ast); err != nil {
+			return nil, err
+		}
+	}
+
+	if limits != nil {
+		if err := limits.checkAST(ast); err != nil {
+			return nil, err
+		}
+	}
+
+	if schema := e.schema.Load(); schema != nil {
+		if errs := schema.Check(ast); len(errs) > 0 {
+			return nil, errs[0]
+		}
+	}
+
+	compiled := newCompiledRule(ast, hash(rule))
+	compiled.source = rule
+
+	for name := range references {
+		compiled.references = append(compiled.references, name)
+	}
+
+	if optimizer := e.optimizer.Load(); optimizer != nil {
+		compiled.AST = optimizer.optimize(ast)
+	}
 
 	return compiled, nil
 }
 
-func (e *Engine) EvaluateCompiled(compiled *CompiledRule, context D) (bool, error) {
-	return e.evaluator.Evaluate(compiled.AST, context)
+// SetSchema restricts the attributes rules may reference. Compiling a rule that uses an attribute
+// outside the schema fails with ErrUnknownAttribute and suggests the closest known paths. Passing
+// nil removes the restriction. The rule cache is cleared so cached rules are checked again.
+func (e *Engine) SetSchema(schema *Schema) {
+	e.schema.Store(schema)
+	e.compiledRules.Clear()
+}
+
+// SetLimits bounds the size of the rules the engine compiles and the work each evaluation may do.
+// The rule cache is cleared so cached rules are checked against the new limits.
+func (e *Engine) SetLimits(limits Limits) {
+	e.limits.Store(&limits)
+	e.compiledRules.Clear()
+}
+
+func (e *Engine) EvaluateCompiled(compiled *CompiledRule, data D) (bool, error) {
+	if limits := e.limits.Load(); limits != nil && limits.MaxSteps > 0 {
+		return e.EvaluateCompiledContext(context.Background(), compiled, data)
+	}
+
+	if profile := e.profile(compiled); profile != nil {
+		evaluator := Evaluator{profile: profile}
+		return evaluator.Evaluate(compiled.AST, data)
+	}
+
+	return e.evaluator.Evaluate(compiled.AST, data)
+}
+
+// EvaluateValue evaluates a rule like Evaluate but returns its typed result, so rules can compute
+// values such as prices and limits: `order.subtotal * 0.9` yields a number. See
+// Evaluator.EvaluateValue.
+func (e *Engine) EvaluateValue(rule string, data D) (Value, error) {
+	compiled, err := e.CompileRule(rule)
+	if err != nil {
+		return Value{}, err
+	}
+
+	return e.EvaluateCompiledValue(compiled, data)
+}
+
+// EvaluateCompiledValue is the EvaluateValue counterpart of EvaluateCompiled.
+func (e *Engine) EvaluateCompiledValue(compiled *CompiledRule, data D) (Value, error) {
+	evaluator := Evaluator{profile: e.profile(compiled)}
+
+	if limits := e.limits.Load(); limits != nil && limits.MaxSteps > 0 {
+		evaluator.meter = &meter{ctx: context.Background(), maxSteps: limits.MaxSteps}
+	}
+
+	return evaluator.EvaluateValue(compiled.AST, data)
 }
 
 func (e *Engine) ClearCache() {
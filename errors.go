@@ -1,5 +1,11 @@
 package rule
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type EngineError struct {
 	Code    string
 	Message string
@@ -9,6 +15,43 @@ func (e *EngineError) Error() string {
 	return e.Message
 }
 
+// SyntaxError reports a parse or validation error together with the rune offsets of the offending
+// source, so tools can point at the exact location. It unwraps to the underlying error.
+type SyntaxError struct {
+	Err   error
+	Start int
+	End   int
+	// Suggestions holds the closest keywords or attribute paths to a misspelled word, best first.
+	Suggestions []string
+}
+
+func (e *SyntaxError) Error() string {
+	message := fmt.Sprintf("%s at position %d", e.Err.Error(), e.Start)
+	if hint := e.Hint(); hint != "" {
+		message += ", " + hint
+	}
+
+	return message
+}
+
+// Hint renders the suggestions as a "did you mean" question, or returns an empty string.
+func (e *SyntaxError) Hint() string {
+	if len(e.Suggestions) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, len(e.Suggestions))
+	for i, suggestion := range e.Suggestions {
+		quoted[i] = strconv.Quote(suggestion)
+	}
+
+	return "did you mean " + strings.Join(quoted, " or ") + "?"
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
 var (
 	ErrInvalidNode       = &EngineError{"INVALID_NODE", "Invalid AST node type"}
 	ErrInvalidLiteral    = &EngineError{"INVALID_LITERAL", "Invalid literal value"}
@@ -38,6 +81,15 @@ var (
 		"INVALID_STRING_OP",
 		"String operators (co/sw/ew) can only be used with string operands",
 	}
+	ErrInvalidArithmeticOp = &EngineError{
+		"INVALID_ARITHMETIC_OP",
+		"Arithmetic operators (+ - * / %) can only be used with numeric operands or to shift a datetime by a duration",
+	}
+	ErrIncompatibleTypes    = &EngineError{"INCOMPATIBLE_TYPES", "Operands have incompatible types"}
+	ErrIncompatibleBranches = &EngineError{
+		"INCOMPATIBLE_BRANCHES",
+		"Conditional branches have incompatible types",
+	}
 	ErrInvalidPresenceOp = &EngineError{
 		"INVALID_PRESENCE_OP",
 		"Presence operator (pr) can only be used with identifiers or properties",
@@ -46,4 +98,42 @@ var (
 	ErrEmptyParentheses = &EngineError{"EMPTY_PARENTHESES", "Empty parentheses are not allowed"}
 	ErrUnbalancedParens = &EngineError{"UNBALANCED_PARENTHESES", "Unbalanced parentheses"}
 	ErrTrailingTokens   = &EngineError{"TRAILING_TOKENS", "Unexpected tokens after complete expression"}
+
+	// ErrUnknownAttribute indicates an attribute that is not part of the configured schema.
+	ErrUnknownAttribute = &EngineError{"UNKNOWN_ATTRIBUTE", "Unknown attribute"}
+
+	// ErrRuleTooLong indicates a rule source longer than Limits.MaxRuleLength.
+	ErrRuleTooLong = &EngineError{"RULE_TOO_LONG", "Rule exceeds the maximum length"}
+	// ErrMaxDepthExceeded indicates a rule nested deeper than Limits.MaxDepth.
+	ErrMaxDepthExceeded = &EngineError{"MAX_DEPTH_EXCEEDED", "Rule exceeds the maximum nesting depth"}
+	// ErrTooManyNodes indicates a rule with more AST nodes than Limits.MaxNodes.
+	ErrTooManyNodes = &EngineError{"TOO_MANY_NODES", "Rule exceeds the maximum number of nodes"}
+	// ErrArrayTooLarge indicates an array literal with more elements than Limits.MaxArraySize.
+	ErrArrayTooLarge = &EngineError{"ARRAY_TOO_LARGE", "Array literal exceeds the maximum size"}
+	// ErrStepBudgetExceeded indicates an evaluation that visited more nodes than Limits.MaxSteps.
+	ErrStepBudgetExceeded = &EngineError{"STEP_BUDGET_EXCEEDED", "Evaluation exceeds the step budget"}
+
+	// ErrNoValue indicates an expression without a value, such as a missing attribute or a division by zero.
+	ErrNoValue = &EngineError{"NO_VALUE", "Expression has no value"}
+	// ErrUnboundParameter indicates a $name placeholder that was given no value.
+	ErrUnboundParameter = &EngineError{"UNBOUND_PARAMETER", "No value bound to parameter"}
+	// ErrUnknownDefinition indicates an @name reference to a rule that was never defined.
+	ErrUnknownDefinition = &EngineError{"UNKNOWN_DEFINITION", "Unknown definition"}
+	// ErrCyclicDefinition indicates definitions that reference each other in a cycle.
+	ErrCyclicDefinition = &EngineError{"CYCLIC_DEFINITION", "Definitions refer to each other in a cycle"}
+	// ErrUnusedVariable indicates a let variable that is never read.
+	ErrUnusedVariable = &EngineError{"UNUSED_VARIABLE", "Variable is never used"}
+	// ErrShadowedVariable indicates a let variable that hides a variable of an enclosing let.
+	ErrShadowedVariable = &EngineError{"SHADOWED_VARIABLE", "Variable shadows an enclosing variable"}
+
+	// ErrInvalidTable indicates a malformed decision table.
+	ErrInvalidTable = &EngineError{"INVALID_TABLE", "Invalid decision table"}
+	// ErrAmbiguousDecision indicates that more than one rule matched a decision with HitUnique.
+	ErrAmbiguousDecision = &EngineError{"AMBIGUOUS_DECISION", "More than one rule matches a unique decision"}
+
+	// ErrUnsupportedTranslation indicates a rule construct that cannot be pushed down to another query language.
+	ErrUnsupportedTranslation = &EngineError{
+		"UNSUPPORTED_TRANSLATION",
+		"Rule construct cannot be translated",
+	}
 )
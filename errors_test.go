@@ -1,6 +1,7 @@
 package rule
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -61,3 +62,38 @@ func TestEngineError(t *testing.T) {
 		t.Error("Custom error Error() method incorrect")
 	}
 }
+
+// Test SyntaxError positions for lexer, parser and validator failures.
+func TestSyntaxErrorPositions(t *testing.T) {
+	tests := []struct {
+		rule  string
+		err   error
+		start int
+		end   int
+	}{
+		{`name eq "open`, ErrUnterminatedString, 8, 13},
+		{`a b eq 1`, ErrMissingOperator, 2, 3},
+		{`a eq 1 )`, ErrTrailingTokens, 7, 8},
+		{`a eq ()`, ErrEmptyParentheses, 6, 7},
+		{`x eq 1 and tier in "gold"`, ErrInvalidInOperand, 11, 25},
+		{`x eq 1 or 5 pr`, ErrInvalidPresenceOp, 10, 14},
+	}
+
+	for _, test := range tests {
+		_, err := ParseRule(test.rule)
+
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("%q: expected SyntaxError, got %v", test.rule, err)
+		}
+
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: expected %v, got %v", test.rule, test.err, err)
+		}
+
+		if syntaxErr.Start != test.start || syntaxErr.End != test.end {
+			t.Errorf("%q: expected span %d-%d, got %d-%d",
+				test.rule, test.start, test.end, syntaxErr.Start, syntaxErr.End)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package rule
 
 import (
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -20,10 +22,31 @@ type EvalResult struct {
 	IntValue int64
 	// IsInt indicates if this numeric value should be treated as an integer
 	IsInt bool
+	// Dur holds the value of a duration
+	Dur Duration
 }
 
 // Evaluator is an optimized evaluator that avoids allocations during evaluation.
-type Evaluator struct{}
+type Evaluator struct {
+	// meter is only set on the per-call evaluators created for budgeted or cancellable
+	// evaluations; the shared evaluator has none and pays nothing for it.
+	meter *meter
+	// resolution is set when attributes come from a Resolver instead of the context map.
+	resolution *resolution
+	// profile is set when the engine has a Profiler; it records the outcome of every boolean
+	// sub-expression.
+	profile *ruleProfile
+	// params holds the values bound to $name placeholders by EvaluateWith.
+	params D
+	// bindings holds the variables bound by the let expressions being evaluated, innermost last.
+	bindings []binding
+}
+
+// binding is the value of a let variable, computed once per evaluation.
+type binding struct {
+	name  string
+	value EvalResult
+}
 
 func NewEvaluator() *Evaluator {
 	return &Evaluator{}
@@ -37,12 +60,45 @@ func (e *Evaluator) Evaluate(node *ASTNode, context D) (bool, error) {
 		return false, err
 	}
 
+	if e.profile != nil {
+		e.profile.record(node, e.toBool(&result))
+	}
+
 	return e.toBool(&result), nil
 }
 
+// EvaluateValue evaluates an expression and returns its typed result instead of its truth value:
+// the value of an attribute or literal, the number an arithmetic expression computes or the
+// boolean a condition yields. Attributes holding other Go values are converted the way
+// comparisons see them, so times become strings. Expressions without a value, such as missing
+// attributes or divisions by zero, fail with ErrNoValue.
+func (e *Evaluator) EvaluateValue(node *ASTNode, context D) (Value, error) {
+	var result EvalResult
+
+	if err := e.evaluateNode(node, context, &result); err != nil {
+		return Value{}, err
+	}
+
+	if e.profile != nil {
+		e.profile.record(node, e.toBool(&result))
+	}
+
+	if !result.IsValid {
+		return Value{}, ErrNoValue
+	}
+
+	return e.toValue(&result), nil
+}
+
 func (e *Evaluator) evaluateNode(node *ASTNode, context D, result *EvalResult) error {
 	result.IsValid = false
 
+	if e.meter != nil {
+		if err := e.meter.step(); err != nil {
+			return err
+		}
+	}
+
 	switch node.Type {
 	case NodeLiteral:
 		return e.evaluateLiteral(node, result)
@@ -59,9 +115,31 @@ func (e *Evaluator) evaluateNode(node *ASTNode, context D, result *EvalResult) e
 	case NodeBinaryOp:
 		return e.evaluateBinaryOp(node, context, result)
 
+	case NodeConditional:
+		return e.evaluateConditional(node, context, result)
+
+	case NodeParameter:
+		return e.evaluateParameter(node, result)
+
+	case NodeReference:
+		// Engines inline definitions when they compile a rule
+		return fmt.Errorf("%w @%s", ErrUnknownDefinition, node.Value.StrValue)
+
+	case NodeLet:
+		return e.evaluateLet(node, context, result)
+
+	case NodeVariable:
+		return e.evaluateVariable(node, result)
+
+	case NodeCall:
+		return e.evaluateCall(node, result)
+
 	case NodeArray:
 		return ErrInvalidNode // Arrays are not directly evaluatable
 
+	case NodeError:
+		return ErrInvalidSyntax // Partial ASTs cannot be evaluated
+
 	default:
 		return ErrInvalidNode
 	}
@@ -82,6 +160,8 @@ func (e *Evaluator) evaluateLiteral(node *ASTNode, result *EvalResult) error {
 		result.Bool = node.Value.BoolValue
 	case ValueArray:
 		result.Arr = node.Value.ArrValue
+	case ValueDuration:
+		result.Dur = node.Value.DurValue
 	case ValueIdentifier:
 		result.IsValid = false
 		return ErrInvalidLiteral // Identifiers should not be in literals
@@ -94,6 +174,10 @@ func (e *Evaluator) evaluateLiteral(node *ASTNode, result *EvalResult) error {
 }
 
 func (e *Evaluator) evaluateIdentifier(node *ASTNode, context D, result *EvalResult) error {
+	if e.resolution != nil {
+		return e.evaluateResolved(node, result)
+	}
+
 	value, exists := context[node.Value.StrValue]
 	if !exists {
 		// For missing attributes, return a special "missing" result
@@ -109,7 +193,60 @@ func (e *Evaluator) evaluateIdentifier(node *ASTNode, context D, result *EvalRes
 	return nil
 }
 
+func (e *Evaluator) evaluateParameter(node *ASTNode, result *EvalResult) error {
+	value, bound := e.params[node.Value.StrValue]
+	if !bound {
+		return fmt.Errorf("%w $%s", ErrUnboundParameter, node.Value.StrValue)
+	}
+
+	result.IsValid = true
+	e.setResultFromAny(result, value)
+
+	return nil
+}
+
+// evaluateLet evaluates the bound value once and the body with the variable in scope. The scope
+// lives on a copy of the evaluator, so evaluators shared between goroutines are never modified.
+func (e *Evaluator) evaluateLet(node *ASTNode, context D, result *EvalResult) error {
+	scoped, err := e.bind(node, context)
+	if err != nil {
+		return err
+	}
+
+	return scoped.evaluateNode(node.Right, context, result)
+}
+
+// bind returns a copy of the evaluator in which the variable of a let node is bound to its value.
+func (e *Evaluator) bind(node *ASTNode, context D) (*Evaluator, error) {
+	var value EvalResult
+
+	if err := e.evaluateNode(node.Left, context, &value); err != nil {
+		return nil, err
+	}
+
+	scoped := *e
+	// The full slice expression makes append copy, so sibling scopes never share bindings.
+	scoped.bindings = append(e.bindings[:len(e.bindings):len(e.bindings)], binding{node.Value.StrValue, value})
+
+	return &scoped, nil
+}
+
+func (e *Evaluator) evaluateVariable(node *ASTNode, result *EvalResult) error {
+	for i := len(e.bindings) - 1; i >= 0; i-- {
+		if e.bindings[i].name == node.Value.StrValue {
+			*result = e.bindings[i].value
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: variable %s is not bound", ErrInvalidNode, node.Value.StrValue)
+}
+
 func (e *Evaluator) evaluateProperty(node *ASTNode, context D, result *EvalResult) error {
+	if e.resolution != nil {
+		return e.evaluateResolved(node, result)
+	}
+
 	current := context
 
 	for _, child := range node.Children {
@@ -152,6 +289,22 @@ func (e *Evaluator) evaluateProperty(node *ASTNode, context D, result *EvalResul
 	return nil
 }
 
+// evaluateResolved reads an identifier or property from the resolver.
+func (e *Evaluator) evaluateResolved(node *ASTNode, result *EvalResult) error {
+	value, exists := e.resolution.resolve(node)
+	if !exists {
+		result.IsValid = false
+		result.Type = ValueString // Default type for missing
+
+		return nil
+	}
+
+	result.IsValid = true
+	e.setResultFromAny(result, value)
+
+	return nil
+}
+
 func (e *Evaluator) evaluateUnaryOp(node *ASTNode, context D, result *EvalResult) error {
 	switch node.Operator {
 	case NOT:
@@ -193,7 +346,23 @@ func (e *Evaluator) evaluateUnaryOp(node *ASTNode, context D, result *EvalResult
 		AND,
 		OR,
 		EQUALS,
-		NOT_EQUALS:
+		NOT_EQUALS,
+		PLUS,
+		MINUS,
+		MULTIPLY,
+		DIVIDE,
+		MODULO,
+		IF,
+		THEN,
+		ELSE,
+		CASE,
+		WHEN,
+		END,
+		PARAMETER,
+		REFERENCE,
+		LET,
+		ASSIGN,
+		DURATION:
 		return ErrInvalidOperator // These are not unary operators
 	default:
 		return ErrInvalidOperator
@@ -208,6 +377,8 @@ func (e *Evaluator) evaluateBinaryOp(node *ASTNode, context D, result *EvalResul
 		return e.evaluateLogicalOr(node, context, result)
 	case EQ, NE, LT, GT, LE, GE, CO, SW, EW, IN, NOT_IN, EQUALS, NOT_EQUALS, DQ, DN, BE, BQ, AF, AQ, DL, DG:
 		return e.evaluateComparisonOperator(node, context, result)
+	case PLUS, MINUS, MULTIPLY, DIVIDE, MODULO:
+		return e.evaluateArithmeticOperator(node, context, result)
 	case EOF,
 		IDENTIFIER,
 		STRING,
@@ -220,7 +391,18 @@ func (e *Evaluator) evaluateBinaryOp(node *ASTNode, context D, result *EvalResul
 		DOT,
 		COMMA,
 		PR,
-		NOT:
+		NOT,
+		IF,
+		THEN,
+		ELSE,
+		CASE,
+		WHEN,
+		END,
+		PARAMETER,
+		REFERENCE,
+		LET,
+		ASSIGN,
+		DURATION:
 		result.IsValid = false
 		return ErrInvalidOperator // These are not binary operators
 	default:
@@ -229,6 +411,33 @@ func (e *Evaluator) evaluateBinaryOp(node *ASTNode, context D, result *EvalResul
 	}
 }
 
+// evaluateConditional evaluates the value of the first branch whose condition holds, or else the
+// value of the else branch. Values of the other branches are never evaluated. When no branch is
+// taken the conditional has no value.
+func (e *Evaluator) evaluateConditional(node *ASTNode, context D, result *EvalResult) error {
+	branches := node.Children
+
+	for len(branches) >= 2 {
+		var condition EvalResult
+
+		if err := e.evaluateNode(branches[0], context, &condition); err != nil {
+			return err
+		}
+
+		if e.toBool(&condition) {
+			return e.evaluateNode(branches[1], context, result)
+		}
+
+		branches = branches[2:]
+	}
+
+	if len(branches) == 1 {
+		return e.evaluateNode(branches[0], context, result)
+	}
+
+	return nil
+}
+
 // evaluateNotOperator handles the NOT unary operator.
 func (e *Evaluator) evaluateNotOperator(node *ASTNode, context D, result *EvalResult) error {
 	var operandResult EvalResult
@@ -238,6 +447,10 @@ func (e *Evaluator) evaluateNotOperator(node *ASTNode, context D, result *EvalRe
 		return err
 	}
 
+	if e.profile != nil {
+		e.profile.record(node.Left, e.toBool(&operandResult))
+	}
+
 	result.Type = ValueBoolean
 	result.Bool = !e.toBool(&operandResult)
 	result.IsValid = true
@@ -247,12 +460,20 @@ func (e *Evaluator) evaluateNotOperator(node *ASTNode, context D, result *EvalRe
 
 // evaluatePresenceOperator handles the PR (presence) unary operator.
 func (e *Evaluator) evaluatePresenceOperator(node *ASTNode, context D, result *EvalResult) error {
+	if e.resolution != nil && node.Left.IsIdentifier() {
+		_, exists := e.resolution.resolve(node.Left)
+		e.setPresenceResult(result, exists)
+
+		return nil
+	}
+
 	switch node.Left.Type {
 	case NodeIdentifier:
 		return e.checkIdentifierPresence(node, context, result)
 	case NodeProperty:
 		return e.checkPropertyPresence(node, context, result)
-	case NodeBinaryOp, NodeUnaryOp, NodeLiteral, NodeArray:
+	case NodeBinaryOp, NodeUnaryOp, NodeLiteral, NodeArray, NodeError, NodeConditional, NodeParameter, NodeReference,
+		NodeLet, NodeVariable, NodeCall:
 		return ErrInvalidOperator // Invalid node types for PR operator
 	default:
 		return ErrInvalidOperator
@@ -314,6 +535,10 @@ func (e *Evaluator) evaluateLogicalAnd(node *ASTNode, context D, result *EvalRes
 		return err
 	}
 
+	if e.profile != nil {
+		e.profile.record(node.Left, e.toBool(&leftResult))
+	}
+
 	if !e.toBool(&leftResult) {
 		result.Type = ValueBoolean
 		result.Bool = false
@@ -329,6 +554,10 @@ func (e *Evaluator) evaluateLogicalAnd(node *ASTNode, context D, result *EvalRes
 		return err
 	}
 
+	if e.profile != nil {
+		e.profile.record(node.Right, e.toBool(&rightResult))
+	}
+
 	result.Type = ValueBoolean
 	result.Bool = e.toBool(&rightResult)
 	result.IsValid = true
@@ -345,6 +574,10 @@ func (e *Evaluator) evaluateLogicalOr(node *ASTNode, context D, result *EvalResu
 		return err
 	}
 
+	if e.profile != nil {
+		e.profile.record(node.Left, e.toBool(&leftResult))
+	}
+
 	if e.toBool(&leftResult) {
 		result.Type = ValueBoolean
 		result.Bool = true
@@ -360,6 +593,10 @@ func (e *Evaluator) evaluateLogicalOr(node *ASTNode, context D, result *EvalResu
 		return err
 	}
 
+	if e.profile != nil {
+		e.profile.record(node.Right, e.toBool(&rightResult))
+	}
+
 	result.Type = ValueBoolean
 	result.Bool = e.toBool(&rightResult)
 	result.IsValid = true
@@ -476,9 +713,87 @@ func (e *Evaluator) performComparison(
 		PR,
 		AND,
 		OR,
-		NOT:
+		NOT,
+		PLUS,
+		MINUS,
+		MULTIPLY,
+		DIVIDE,
+		MODULO,
+		IF,
+		THEN,
+		ELSE,
+		CASE,
+		WHEN,
+		END,
+		PARAMETER,
+		REFERENCE,
+		LET,
+		ASSIGN,
+		DURATION:
+		result.IsValid = false
+		return ErrInvalidOperator
+	default:
 		result.IsValid = false
 		return ErrInvalidOperator
+	}
+
+	return nil
+}
+
+// evaluateArithmeticOperator handles the arithmetic operators. Integers stay exact while the result
+// fits in an int64; otherwise the operation is carried out on float64. Operations on missing or
+// non-numeric operands and divisions by zero have no value, so comparisons against them are false.
+func (e *Evaluator) evaluateArithmeticOperator(node *ASTNode, context D, result *EvalResult) error {
+	var leftResult, rightResult EvalResult
+
+	err := e.evaluateNode(node.Left, context, &leftResult)
+	if err != nil {
+		return err
+	}
+
+	err = e.evaluateNode(node.Right, context, &rightResult)
+	if err != nil {
+		return err
+	}
+
+	if leftResult.Type == ValueDuration || rightResult.Type == ValueDuration {
+		e.shiftDateTime(node.Operator, &leftResult, &rightResult, result)
+		return nil
+	}
+
+	result.Type = ValueNumber
+	result.IsValid = leftResult.IsValid && rightResult.IsValid &&
+		leftResult.Type == ValueNumber && rightResult.Type == ValueNumber
+
+	if !result.IsValid {
+		return nil
+	}
+
+	if leftResult.IsInt && rightResult.IsInt {
+		if value, ok := integerArithmetic(node.Operator, leftResult.IntValue, rightResult.IntValue); ok {
+			result.Num = float64(value)
+			result.IntValue = value
+			result.IsInt = true
+
+			return nil
+		}
+	}
+
+	left, right := leftResult.Num, rightResult.Num
+
+	switch node.Operator { //nolint:exhaustive // only called for arithmetic operators
+	case PLUS:
+		result.Num = left + right
+	case MINUS:
+		result.Num = left - right
+	case MULTIPLY:
+		result.Num = left * right
+	case DIVIDE:
+		result.Num = left / right
+		result.IsValid = right != 0
+	case MODULO:
+		result.Num = math.Mod(left, right)
+		result.IsValid = right != 0
 	default:
 		result.IsValid = false
 		return ErrInvalidOperator
@@ -487,6 +802,42 @@ func (e *Evaluator) performComparison(
 	return nil
 }
 
+// integerArithmetic applies an arithmetic operator to two integers, failing when the result is
+// not an integer or overflows an int64.
+func integerArithmetic(op TokenType, left, right int64) (int64, bool) {
+	switch op { //nolint:exhaustive // only called for arithmetic operators
+	case PLUS:
+		sum := left + right
+		return sum, (sum > left) == (right > 0)
+	case MINUS:
+		difference := left - right
+		return difference, (difference < left) == (right > 0)
+	case MULTIPLY:
+		if left == 0 || right == 0 {
+			return 0, true
+		}
+
+		product := left * right
+
+		return product, product/right == left && !(left == -1 && right == math.MinInt64) &&
+			!(right == -1 && left == math.MinInt64)
+	case DIVIDE:
+		if right == 0 || left%right != 0 || (left == math.MinInt64 && right == -1) {
+			return 0, false
+		}
+
+		return left / right, true
+	case MODULO:
+		if right == 0 {
+			return 0, false
+		}
+
+		return left % right, true
+	default:
+		return 0, false
+	}
+}
+
 func (e *Evaluator) setResultFromAny(result *EvalResult, value any) {
 	result.OriginalValue = value
 
@@ -508,6 +859,12 @@ func (e *Evaluator) setResultFromAny(result *EvalResult, value any) {
 		// The original time.Time is preserved in OriginalValue for datetime operators
 		result.Type = ValueString
 		result.Str = v.String()
+	case Duration:
+		result.Type = ValueDuration
+		result.Dur = v
+	case time.Duration:
+		result.Type = ValueDuration
+		result.Dur = Duration{Clock: v}
 	case []any:
 		// Handle []any slices by storing original value
 		result.Type = ValueArray
@@ -600,6 +957,8 @@ func (e *Evaluator) compareEqual(left, right *EvalResult) bool {
 			return left.Num == right.Num
 		case ValueString:
 			return e.equalIgnoreCase(left.Str, right.Str)
+		case ValueDuration:
+			return left.Dur == right.Dur
 		case ValueArray, ValueIdentifier:
 			return false // Arrays and identifiers cannot be compared
 		}
@@ -638,6 +997,8 @@ func (e *Evaluator) compareEqualStrict(left, right *EvalResult) bool {
 		return left.Num == right.Num
 	case ValueString:
 		return e.equalIgnoreCase(left.Str, right.Str)
+	case ValueDuration:
+		return left.Dur == right.Dur
 	case ValueArray, ValueIdentifier:
 		return false // Arrays and identifiers cannot be compared in strict mode
 	}
@@ -752,12 +1113,49 @@ func (e *Evaluator) setResultFromValue(result *EvalResult, value *Value) {
 		result.Bool = value.BoolValue
 	case ValueArray:
 		result.Arr = value.ArrValue
+	case ValueDuration:
+		result.Dur = value.DurValue
 	case ValueIdentifier:
 		// Identifiers should not be converted to results
 		result.IsValid = false
 	}
 }
 
+// toValue converts a valid evaluation result into a Value, converting the elements of arrays
+// read from the context.
+func (e *Evaluator) toValue(result *EvalResult) Value {
+	switch result.Type {
+	case ValueBoolean:
+		return Value{Type: ValueBoolean, BoolValue: result.Bool}
+	case ValueNumber:
+		return Value{Type: ValueNumber, NumValue: result.Num, IntValue: result.IntValue, IsInt: result.IsInt}
+	case ValueString:
+		return Value{Type: ValueString, StrValue: result.Str}
+	case ValueArray:
+		items, ok := result.OriginalValue.([]any)
+		if !ok {
+			return Value{Type: ValueArray, ArrValue: result.Arr}
+		}
+
+		elements := make([]Value, len(items))
+
+		for i, item := range items {
+			var itemResult EvalResult
+
+			e.setResultFromAny(&itemResult, item)
+			elements[i] = e.toValue(&itemResult)
+		}
+
+		return Value{Type: ValueArray, ArrValue: elements}
+	case ValueDuration:
+		return Value{Type: ValueDuration, DurValue: result.Dur}
+	case ValueIdentifier:
+		return Value{}
+	}
+
+	return Value{}
+}
+
 func (e *Evaluator) toBool(result *EvalResult) bool {
 	if !result.IsValid {
 		return false
@@ -772,6 +1170,8 @@ func (e *Evaluator) toBool(result *EvalResult) bool {
 		return result.Str != ""
 	case ValueArray:
 		return len(result.Arr) > 0
+	case ValueDuration:
+		return result.Dur != Duration{}
 	case ValueIdentifier:
 		return false // Identifiers are not boolean
 	default:
@@ -797,6 +1197,8 @@ func (e *Evaluator) resultToString(result *EvalResult) string {
 		return "false"
 	case ValueArray:
 		return "" // Arrays cannot be converted to string
+	case ValueDuration:
+		return result.Dur.String()
 	case ValueIdentifier:
 		return "" // Identifiers cannot be converted to string
 	default:
@@ -847,7 +1249,7 @@ func (e *Evaluator) parseDateTime(result *EvalResult) (time.Time, bool) {
 		}
 		// Unix timestamp as float (truncate to seconds)
 		return time.Unix(int64(result.Num), 0).UTC(), true
-	case ValueBoolean, ValueArray, ValueIdentifier:
+	case ValueBoolean, ValueArray, ValueIdentifier, ValueDuration:
 		return time.Time{}, false // These types cannot be parsed as datetime
 	default:
 		return time.Time{}, false
@@ -872,6 +1274,70 @@ func (e *Evaluator) compareDateTimes(
 	return op(leftTime, rightTime)
 }
 
+// shiftDateTime adds a duration to or subtracts it from a datetime read like the operands of the
+// datetime operators. Any other combination of operands has no value.
+func (e *Evaluator) shiftDateTime(operator TokenType, left, right *EvalResult, result *EvalResult) {
+	result.IsValid = false
+
+	if !left.IsValid || !right.IsValid {
+		return
+	}
+
+	dateTime, duration, sign := left, right, 1
+
+	switch {
+	case operator == MINUS && right.Type == ValueDuration:
+		sign = -1
+	case operator == PLUS && right.Type == ValueDuration:
+	case operator == PLUS && left.Type == ValueDuration:
+		dateTime, duration = right, left
+	default:
+		return
+	}
+
+	t, ok := e.parseDateTime(dateTime)
+	if !ok {
+		return
+	}
+
+	e.setDateTimeResult(result, duration.Dur.addTo(t, sign))
+}
+
+// setDateTimeResult stores a computed datetime like a time.Time read from the context, with the
+// RFC 3339 form as its string value so it can be returned and parsed again.
+func (e *Evaluator) setDateTimeResult(result *EvalResult, t time.Time) {
+	result.Type = ValueString
+	result.Str = t.Format(time.RFC3339Nano)
+	result.OriginalValue = t
+	result.IsValid = true
+}
+
+// functionNow names the now() function, which returns the current time.
+const functionNow = "now"
+
+//nolint:gochecknoglobals // Static table of the built-in functions
+var functions = []string{functionNow}
+
+func (e *Evaluator) evaluateCall(node *ASTNode, result *EvalResult) error {
+	switch node.Value.StrValue {
+	case functionNow:
+		e.setDateTimeResult(result, time.Now().UTC())
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown function %s", ErrInvalidNode, node.Value.StrValue)
+	}
+}
+
+// compareWithCutoff compares a datetime with the current time minus a duration threshold.
+func (e *Evaluator) compareWithCutoff(left, right *EvalResult, op func(time.Time, time.Time) bool) bool {
+	fieldTime, ok := e.parseDateTime(left)
+	if !ok {
+		return false
+	}
+
+	return op(fieldTime, right.Dur.addTo(time.Now().UTC(), -1))
+}
+
 const (
 	hoursPerDay    = 24.0
 	secondsPerHour = 3600.0
@@ -888,7 +1354,7 @@ func (e *Evaluator) parseDaysThreshold(right *EvalResult) (float64, bool) {
 		}
 
 		return 0, false
-	case ValueBoolean, ValueArray, ValueIdentifier:
+	case ValueBoolean, ValueArray, ValueIdentifier, ValueDuration:
 		return 0, false
 	default:
 		return 0, false
@@ -908,7 +1374,7 @@ func (e *Evaluator) extractUnixTimestamp(left *EvalResult) (int64, bool) {
 		return e.parseStringTimestamp(left.Str)
 	case ValueNumber:
 		return e.parseNumberTimestamp(left)
-	case ValueBoolean, ValueArray, ValueIdentifier:
+	case ValueBoolean, ValueArray, ValueIdentifier, ValueDuration:
 		return 0, false
 	default:
 		return 0, false
@@ -947,6 +1413,10 @@ func (e *Evaluator) calculateDaysDiff(nowUnix, fieldUnix int64) float64 {
 
 // compareDateTimeWithNow compares a datetime value with the current time to check if the difference is less than N days.
 func (e *Evaluator) compareDateTimeWithNow(left, right *EvalResult) bool {
+	if right.Type == ValueDuration {
+		return e.compareWithCutoff(left, right, time.Time.After)
+	}
+
 	daysThreshold, ok := e.parseDaysThreshold(right)
 	if !ok {
 		return false
@@ -965,6 +1435,10 @@ func (e *Evaluator) compareDateTimeWithNow(left, right *EvalResult) bool {
 
 // compareDateTimeWithNowGreater compares a datetime value with the current time to check if the difference is greater than N days.
 func (e *Evaluator) compareDateTimeWithNowGreater(left, right *EvalResult) bool {
+	if right.Type == ValueDuration {
+		return e.compareWithCutoff(left, right, time.Time.Before)
+	}
+
 	daysThreshold, ok := e.parseDaysThreshold(right)
 	if !ok {
 		return false
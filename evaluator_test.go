@@ -1,7 +1,10 @@
 package rule
 
 import (
+	"math"
 	"testing"
+
+	"github.com/stretchr/testify/require"
 )
 
 // Test evaluator with basic operations.
@@ -476,3 +479,189 @@ func TestEvaluatorComplexNested(t *testing.T) {
 		t.Error("Expected true for complex nested expression")
 	}
 }
+
+func TestEvaluatorArithmetic(t *testing.T) {
+	context := D{
+		"price":    19.9,
+		"quantity": 3,
+		"big":      int64(math.MaxInt64),
+		"discount": 0.1,
+		"name":     "x",
+		"zero":     0,
+	}
+
+	tests := []struct {
+		rule     string
+		expected bool
+	}{
+		{`price * quantity gt 59`, true},
+		{`price * quantity * (1 - discount) lt 54`, true},
+		{`quantity + 2 * 3 eq 9`, true},
+		{`(quantity + 2) * 3 eq 15`, true},
+		{`quantity - 1 - 1 eq 1`, true},
+		{`quantity / 2 eq 1.5`, true},
+		{`quantity % 2 eq 1`, true},
+		{`-7 % 3 eq -1`, true},
+		{`big - 1 eq 9223372036854775806`, true},
+		{`big + 1 gt big`, false},
+		{`quantity / zero gt 0 or quantity / zero le 0`, false},
+		{`quantity % zero eq 0`, false},
+		{`missing + 1 ge 0`, false},
+		{`name + 1 eq 1`, false},
+	}
+
+	engine := NewEngine()
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			result, err := engine.Evaluate(tt.rule, context)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestEvaluateValue(t *testing.T) {
+	context := D{
+		"user":  D{"score": 42, "name": "Ann", "tags": []any{"a", 1}},
+		"order": D{"subtotal": 90.5, "shipping": 9.5, "items": 4},
+	}
+
+	tests := []struct {
+		rule     string
+		expected Value
+	}{
+		{`user.score`, Value{Type: ValueNumber, NumValue: 42, IntValue: 42, IsInt: true}},
+		{`user.score * 2`, Value{Type: ValueNumber, NumValue: 84}},
+		{`order.items * order.items`, Value{Type: ValueNumber, NumValue: 16, IntValue: 16, IsInt: true}},
+		{`order.subtotal + order.shipping`, Value{Type: ValueNumber, NumValue: 100}},
+		{`user.name`, Value{Type: ValueString, StrValue: "Ann"}},
+		{`user.score gt 40`, Value{Type: ValueBoolean, BoolValue: true}},
+		{`[1, "b"]`, Value{Type: ValueArray, ArrValue: []Value{
+			{Type: ValueNumber, NumValue: 1},
+			{Type: ValueString, StrValue: "b"},
+		}}},
+		{`user.tags`, Value{Type: ValueArray, ArrValue: []Value{
+			{Type: ValueString, StrValue: "a"},
+			{Type: ValueNumber, NumValue: 1, IntValue: 1, IsInt: true},
+		}}},
+	}
+
+	engine := NewEngine()
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			value, err := engine.EvaluateValue(tt.rule, context)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, value)
+		})
+	}
+
+	for _, rule := range []string{`user.missing`, `order.items / 0`, `user.name * 2`} {
+		_, err := engine.EvaluateValue(rule, context)
+		require.ErrorIs(t, err, ErrNoValue, rule)
+	}
+
+	_, err := engine.EvaluateValue(`user.score +`, context)
+	require.Error(t, err)
+
+	engine.SetLimits(Limits{MaxSteps: 2})
+
+	_, err = engine.EvaluateValue(`user.score * 2`, context)
+	require.ErrorIs(t, err, ErrStepBudgetExceeded)
+}
+
+func TestEvaluatorConditional(t *testing.T) {
+	context := D{
+		"user":  D{"vip": true, "score": 85},
+		"order": D{"total": 120, "shipping": 15},
+	}
+
+	tests := []struct {
+		rule     string
+		expected bool
+	}{
+		{`(if user.vip then 0 else order.shipping) eq 0`, true},
+		{`(if user.missing then 0 else order.shipping) eq 15`, true},
+		{`order.total + (if user.vip then 0 else order.shipping) lt 125`, true},
+		{`case when user.score ge 90 then "A" when user.score ge 80 then "B" else "C" end eq "b"`, true},
+		{`case when user.score ge 90 then "A" end eq "A"`, false},
+		{`if user.vip then user.score gt 80 else false`, true},
+		{`if user.score then order.total else 0`, true},
+		{`if not user.vip then order.total / 0 gt 1 else true`, true},
+		{`case when false then true end`, false},
+	}
+
+	engine := NewEngine()
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			result, err := engine.Evaluate(tt.rule, context)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+
+	value, err := engine.EvaluateValue(`if user.vip then order.total * 0.9 else order.total`, context)
+	require.NoError(t, err)
+	require.Equal(t, Value{Type: ValueNumber, NumValue: 108}, value)
+
+	_, err = engine.EvaluateValue(`case when user.score gt 90 then 1 end`, context)
+	require.ErrorIs(t, err, ErrNoValue)
+
+	// The untaken branch is never evaluated, so it does not count against the step budget.
+	engine.SetLimits(Limits{MaxSteps: 10})
+
+	_, err = engine.Evaluate(`if user.vip then true else `+
+		`order.total * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 gt 0`, context)
+	require.NoError(t, err)
+
+	_, err = engine.Evaluate(`if not user.vip then true else `+
+		`order.total * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 * 2 gt 0`, context)
+	require.ErrorIs(t, err, ErrStepBudgetExceeded)
+}
+
+func TestEvaluatorLet(t *testing.T) {
+	context := D{
+		"order": D{"subtotal": 120, "shipping": 15, "items": []any{1, 2}},
+		"total": 1,
+	}
+
+	tests := []struct {
+		rule     string
+		expected bool
+	}{
+		{`let total = order.subtotal + order.shipping in total gt 100 and total lt 500`, true},
+		{`let total = order.subtotal in total eq 120`, true},
+		{`(let total = order.subtotal in total eq 120) and total eq 1`, true},
+		{`let a = order.subtotal, b = a + order.shipping in b - a eq 15`, true},
+		{`let big = order.subtotal gt 100 in big and not order.missing`, true},
+		{`let n = (2 in order.items) in n`, true},
+		{`let discount = if order.subtotal gt 100 then 10 else 0 in discount eq 10`, true},
+		{`let missing = order.missing in missing eq 1`, false},
+	}
+
+	engine := NewEngine()
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			result, err := engine.Evaluate(tt.rule, context)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+
+	_, err := ParseRule(`let t = order in t.subtotal gt 1`)
+	require.ErrorContains(t, err, "variable t has no properties")
+
+	// The bound value is evaluated once, however often the body refers to it.
+	engine.SetLimits(Limits{MaxSteps: 30})
+
+	_, err = engine.Evaluate(`let t = order.subtotal * 2 * 2 * 2 * 2 * 2 * 2 in `+
+		`t gt 1 and t gt 2 and t gt 3`, context)
+	require.NoError(t, err)
+
+	_, err = engine.Evaluate(`order.subtotal * 2 * 2 * 2 * 2 * 2 * 2 gt 1 and `+
+		`order.subtotal * 2 * 2 * 2 * 2 * 2 * 2 gt 2`, context)
+	require.ErrorIs(t, err, ErrStepBudgetExceeded)
+}
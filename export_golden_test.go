@@ -0,0 +1,225 @@
+package rule
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:gochecknoglobals // Test flag for regenerating golden files
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// exportOperatorRules holds one sample rule per operator in tokenStringMap.
+//
+//nolint:gochecknoglobals // Static test fixture
+var exportOperatorRules = map[TokenType]string{
+	EQ:         `user.country eq "BR" and user.age eq 30`,
+	NE:         `user.country ne "BR" or user.age ne 30`,
+	LT:         `order.amount lt 100`,
+	GT:         `100 gt order.amount`,
+	LE:         `order.amount le 99.5`,
+	GE:         `order.amount ge 10`,
+	CO:         `user.email co "a*b?"`,
+	SW:         `user.name sw "Jo"`,
+	EW:         `user.email ew ".com"`,
+	IN:         `user.tier in ["gold", "VIP", 1, true] and "admin" in user.roles`,
+	NOT_IN:     `user.tier not in ["gold", "vip"]`,
+	PR:         `user.email pr`,
+	DQ:         `event.start dq "2024-07-10T10:00:00Z"`,
+	DN:         `event.start dn 1720605600`,
+	BE:         `event.start be "2024-07-10T10:00:00Z"`,
+	BQ:         `"2024-07-10T10:00:00Z" bq event.start`,
+	AF:         `event.start af "2024-07-10T10:00:00Z"`,
+	AQ:         `event.start aq "2024-07-10T10:00:00+02:00"`,
+	DL:         `user.last_login dl 7`,
+	DG:         `user.last_login dg 0.5`,
+	AND:        `a eq 1 and b eq 2 and c eq 3`,
+	OR:         `a eq 1 or (b eq 2 and c eq 3)`,
+	NOT:        `not (user.banned eq true)`,
+	EQUALS:     `user.age == limits.min`,
+	NOT_EQUALS: `user.age != 18`,
+	PLUS:       `order.subtotal + order.shipping gt 100`,
+	MINUS:      `user.limit - user.spent ge 10`,
+	MULTIPLY:   `order.amount * 0.9 lt 50`,
+	DIVIDE:     `order.total / order.items gt 50`,
+	MODULO:     `user.id % 10 lt 3`,
+	IF:         `(if user.vip then 0 else order.shipping) lt 10`,
+	CASE:       `case when user.age ge 18 then "adult" else "minor" end eq user.group`,
+	LET:        `let total = order.subtotal + order.shipping in total gt 100 and total lt 500`,
+}
+
+// exportStructuralTokens are the entries of tokenStringMap that are not operators.
+//
+//nolint:gochecknoglobals // Static test fixture
+var exportStructuralTokens = map[TokenType]bool{
+	EOF:         true,
+	IDENTIFIER:  true,
+	STRING:      true,
+	NUMBER:      true,
+	BOOLEAN:     true,
+	ARRAY_START: true,
+	ARRAY_END:   true,
+	PAREN_OPEN:  true,
+	PAREN_CLOSE: true,
+	DOT:         true,
+	COMMA:       true,
+	THEN:        true,
+	ELSE:        true,
+	WHEN:        true,
+	END:         true,
+	PARAMETER:   true,
+	REFERENCE:   true,
+	ASSIGN:      true,
+	DURATION:    true,
+}
+
+func TestExportGolden(t *testing.T) {
+	now := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+
+	mongo := NewMongoTranslator()
+	mongo.Now = func() time.Time { return now }
+
+	elastic := NewElasticsearchTranslator()
+
+	exporters := map[string]func(string) (D, error){
+		"mongo":         mongo.TranslateRule,
+		"elasticsearch": elastic.TranslateRule,
+	}
+
+	for token := range tokenStringMap {
+		if exportStructuralTokens[token] {
+			continue
+		}
+
+		rule, ok := exportOperatorRules[token]
+		require.True(t, ok, "missing export sample rule for operator %s", token)
+
+		for exporter, translate := range exporters {
+			t.Run(exporter+"/"+goldenName(token), func(t *testing.T) {
+				document, err := translate(rule)
+
+				var output any = document
+				if err != nil {
+					output = D{"error": err.Error()}
+				}
+
+				assertGolden(t, filepath.Join("testdata", "export", exporter, goldenName(token)+".json"), rule, output)
+			})
+		}
+	}
+}
+
+func TestExportUnsupported(t *testing.T) {
+	tests := []string{
+		`name co other`,
+		`x dl y`,
+		`created_at af "yesterday"`,
+		`"text"`,
+	}
+
+	for _, rule := range tests {
+		t.Run(rule, func(t *testing.T) {
+			_, err := NewMongoTranslator().TranslateRule(rule)
+			require.ErrorIs(t, err, ErrUnsupportedTranslation)
+
+			_, err = NewElasticsearchTranslator().TranslateRule(rule)
+			require.ErrorIs(t, err, ErrUnsupportedTranslation)
+		})
+	}
+
+	_, err := NewElasticsearchTranslator().TranslateRule(`a lt b`)
+	require.ErrorIs(t, err, ErrUnsupportedTranslation)
+}
+
+func TestExportFieldMapping(t *testing.T) {
+	mongo := NewMongoTranslator()
+	mongo.Fields["user.age"] = "profile.age_years"
+
+	document, err := mongo.TranslateRule(`user.age ge 18`)
+	require.NoError(t, err)
+	require.Equal(t, D{"profile.age_years": D{"$gte": float64(18)}}, document)
+
+	elastic := NewElasticsearchTranslator()
+	elastic.Fields["user.age"] = "age"
+
+	document, err = elastic.TranslateRule(`user.age ge 18`)
+	require.NoError(t, err)
+	require.Equal(t, D{"range": D{"age": D{"gte": float64(18)}}}, document)
+}
+
+func TestExportFieldComparison(t *testing.T) {
+	fold := func(field string) D {
+		return D{"$cond": []any{
+			D{"$eq": []any{D{"$type": "$" + field}, "string"}},
+			D{"$toLower": "$" + field},
+			"$" + field,
+		}}
+	}
+
+	document, err := NewMongoTranslator().TranslateRule(`a ne b`)
+	require.NoError(t, err)
+	require.Equal(t, D{
+		"a":     D{"$exists": true},
+		"b":     D{"$exists": true},
+		"$expr": D{"$ne": []any{fold("a"), fold("b")}},
+	}, document, "string equality between fields is case-insensitive")
+
+	document, err = NewMongoTranslator().TranslateRule(`a lt b`)
+	require.NoError(t, err)
+	require.Equal(t, D{"$lt": []any{"$a", "$b"}}, document["$expr"], "ordering stays case-sensitive")
+}
+
+func TestExportConstantFolding(t *testing.T) {
+	document, err := NewMongoTranslator().TranslateRule(`"a" eq "A"`)
+	require.NoError(t, err)
+	require.Equal(t, D{"$expr": true}, document)
+
+	document, err = NewElasticsearchTranslator().TranslateRule(`1 gt 2`)
+	require.NoError(t, err)
+	require.Equal(t, D{"match_none": D{}}, document)
+}
+
+func goldenName(token TokenType) string {
+	switch token { //nolint:exhaustive // only symbolic operators need a file-friendly name
+	case EQUALS:
+		return "equals"
+	case NOT_EQUALS:
+		return "not_equals"
+	case PLUS:
+		return "plus"
+	case MINUS:
+		return "minus"
+	case MULTIPLY:
+		return "multiply"
+	case DIVIDE:
+		return "divide"
+	case MODULO:
+		return "modulo"
+	default:
+		return strings.ReplaceAll(token.String(), " ", "_")
+	}
+}
+
+func assertGolden(t *testing.T, path, rule string, output any) {
+	t.Helper()
+
+	actual, err := json.MarshalIndent(D{"rule": rule, "output": output}, "", "  ")
+	require.NoError(t, err)
+
+	actual = append(actual, '\n')
+
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, actual, 0o600))
+	}
+
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err, "golden file missing, run go test -run %s -update", t.Name())
+	require.JSONEq(t, string(expected), string(actual))
+}
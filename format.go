@@ -0,0 +1,296 @@
+package rule
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Binding strength of each expression level, mirroring the parser's precedence climbing.
+const (
+	precedenceConditional = iota + 1
+	precedenceOr
+	precedenceAnd
+	precedenceNot
+	precedenceComparison
+	precedenceAdditive
+	precedenceMultiplicative
+	precedencePrimary
+)
+
+// Format renders an AST back to canonical rule source. Operators are written in their keyword
+// form (== and != become eq and ne), operands are separated by single spaces and parentheses are
+// only emitted where precedence requires them. Parsing the result yields an equivalent AST.
+func Format(node *ASTNode) string {
+	var sb strings.Builder
+
+	writeNode(&sb, node)
+
+	return sb.String()
+}
+
+func writeNode(sb *strings.Builder, node *ASTNode) {
+	if node == nil {
+		return
+	}
+
+	switch node.Type {
+	case NodeBinaryOp:
+		precedence := nodePrecedence(node)
+		operandPrecedence := precedence
+
+		if precedence == precedenceComparison {
+			operandPrecedence = precedenceAdditive
+		}
+
+		writeOperand(sb, node.Left, operandPrecedence, false)
+		sb.WriteByte(' ')
+		sb.WriteString(canonicalOperator(node.Operator))
+		sb.WriteByte(' ')
+		writeOperand(sb, node.Right, operandPrecedence, precedence != precedenceComparison)
+
+	case NodeUnaryOp:
+		if node.Operator == PR {
+			writeOperand(sb, node.Left, precedencePrimary, false)
+			sb.WriteString(" pr")
+
+			return
+		}
+
+		sb.WriteString("not ")
+		writeOperand(sb, node.Left, precedenceNot, false)
+
+	case NodeIdentifier:
+		sb.WriteString(node.Value.StrValue)
+
+	case NodeProperty:
+		for i, child := range node.Children {
+			if i > 0 {
+				sb.WriteByte('.')
+			}
+
+			sb.WriteString(child.Value.StrValue)
+		}
+
+	case NodeLiteral, NodeArray:
+		writeValue(sb, node.Value)
+
+	case NodeConditional:
+		writeConditional(sb, node)
+
+	case NodeParameter:
+		sb.WriteByte('$')
+		sb.WriteString(node.Value.StrValue)
+
+	case NodeReference:
+		sb.WriteByte('@')
+		sb.WriteString(node.Value.StrValue)
+
+	case NodeLet:
+		writeLet(sb, node)
+
+	case NodeVariable:
+		sb.WriteString(node.Value.StrValue)
+
+	case NodeCall:
+		sb.WriteString(node.Value.StrValue)
+		sb.WriteString("()")
+
+	case NodeError:
+		// Skipped input has no canonical form.
+	}
+}
+
+// writeLet writes nested lets as one `let a = x, b = y in body`. Values that contain a membership
+// test or another let are parenthesized, as a bare in would end the value.
+func writeLet(sb *strings.Builder, node *ASTNode) {
+	sb.WriteString("let ")
+
+	for {
+		sb.WriteString(node.Value.StrValue)
+		sb.WriteString(" = ")
+
+		ambiguous := findNode(node.Left, func(n *ASTNode) bool {
+			return n.Type == NodeLet || (n.Type == NodeBinaryOp && n.Operator == IN)
+		})
+		if ambiguous != nil {
+			sb.WriteByte('(')
+			writeNode(sb, node.Left)
+			sb.WriteByte(')')
+		} else {
+			writeNode(sb, node.Left)
+		}
+
+		if node.Right == nil || node.Right.Type != NodeLet {
+			break
+		}
+
+		sb.WriteString(", ")
+
+		node = node.Right
+	}
+
+	sb.WriteString(" in ")
+	writeNode(sb, node.Right)
+}
+
+// writeConditional writes a single branch with an else as `if ... then ... else ...` and anything
+// else as `case when ... then ... [else ...] end`.
+func writeConditional(sb *strings.Builder, node *ASTNode) {
+	otherwise := node.otherwise()
+
+	if otherwise != nil && len(node.Children) == 3 { //nolint:mnd // condition, value and else value
+		sb.WriteString("if ")
+		writeNode(sb, node.Children[0])
+		sb.WriteString(" then ")
+		writeNode(sb, node.Children[1])
+		sb.WriteString(" else ")
+		writeNode(sb, otherwise)
+
+		return
+	}
+
+	sb.WriteString("case")
+
+	for i := 0; i+1 < len(node.Children); i += 2 {
+		sb.WriteString(" when ")
+		writeNode(sb, node.Children[i])
+		sb.WriteString(" then ")
+		writeNode(sb, node.Children[i+1])
+	}
+
+	if otherwise != nil {
+		sb.WriteString(" else ")
+		writeNode(sb, otherwise)
+	}
+
+	sb.WriteString(" end")
+}
+
+// writeOperand writes a child expression, parenthesizing it when it binds looser than minimum, or
+// equally loose when strict is set (the right operand of a left-associative operator).
+func writeOperand(sb *strings.Builder, node *ASTNode, minimum int, strict bool) {
+	precedence := nodePrecedence(node)
+	if precedence > minimum || (precedence == minimum && !strict) {
+		writeNode(sb, node)
+		return
+	}
+
+	sb.WriteByte('(')
+	writeNode(sb, node)
+	sb.WriteByte(')')
+}
+
+func nodePrecedence(node *ASTNode) int {
+	if node == nil {
+		return precedencePrimary
+	}
+
+	switch node.Type {
+	case NodeBinaryOp:
+		switch node.Operator { //nolint:exhaustive // every other binary operator is a comparison
+		case OR:
+			return precedenceOr
+		case AND:
+			return precedenceAnd
+		case PLUS, MINUS:
+			return precedenceAdditive
+		case MULTIPLY, DIVIDE, MODULO:
+			return precedenceMultiplicative
+		default:
+			return precedenceComparison
+		}
+	case NodeUnaryOp:
+		if node.Operator == NOT {
+			return precedenceNot
+		}
+
+		return precedenceComparison
+	case NodeConditional:
+		// The else value of the if form extends as far as possible, so it is parenthesized as an
+		// operand; the case form is closed by end.
+		if node.otherwise() != nil && len(node.Children) == 3 { //nolint:mnd // condition, value and else value
+			return precedenceConditional
+		}
+
+		return precedencePrimary
+	case NodeLet:
+		// The body extends as far as possible, like the else value of an if.
+		return precedenceConditional
+	case NodeIdentifier, NodeLiteral, NodeArray, NodeProperty, NodeParameter, NodeReference, NodeVariable, NodeCall,
+		NodeError:
+		return precedencePrimary
+	}
+
+	return precedencePrimary
+}
+
+func canonicalOperator(op TokenType) string {
+	return canonicalToken(op).String()
+}
+
+// canonicalToken maps the symbolic aliases == and != to their keyword operators.
+func canonicalToken(op TokenType) TokenType {
+	switch op { //nolint:exhaustive // only the symbolic aliases are rewritten
+	case EQUALS:
+		return EQ
+	case NOT_EQUALS:
+		return NE
+	default:
+		return op
+	}
+}
+
+func writeValue(sb *strings.Builder, value Value) {
+	switch value.Type {
+	case ValueString:
+		writeQuoted(sb, value.StrValue)
+	case ValueNumber:
+		if value.IsInt {
+			sb.WriteString(strconv.FormatInt(value.IntValue, 10))
+		} else {
+			sb.WriteString(strconv.FormatFloat(value.NumValue, 'f', -1, 64))
+		}
+	case ValueBoolean:
+		sb.WriteString(strconv.FormatBool(value.BoolValue))
+	case ValueArray:
+		sb.WriteByte('[')
+
+		for i, element := range value.ArrValue {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+
+			writeValue(sb, element)
+		}
+
+		sb.WriteByte(']')
+	case ValueDuration:
+		sb.WriteString(value.DurValue.String())
+	case ValueIdentifier:
+		sb.WriteString(value.StrValue)
+	}
+}
+
+// writeQuoted writes a string literal using the escape sequences understood by the lexer.
+func writeQuoted(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+
+	sb.WriteByte('"')
+}
@@ -0,0 +1,97 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected string
+	}{
+		{`x   eq    1`, `x eq 1`},
+		{`(a eq 1) and ((b eq 2))`, `a eq 1 and b eq 2`},
+		{`a eq 1 or b eq 2 and c eq 3`, `a eq 1 or b eq 2 and c eq 3`},
+		{`(a eq 1 or b eq 2) and c eq 3`, `(a eq 1 or b eq 2) and c eq 3`},
+		{`a eq 1 and (b eq 2 and c eq 3)`, `a eq 1 and (b eq 2 and c eq 3)`},
+		{`not (a eq 1 or b eq 2)`, `not (a eq 1 or b eq 2)`},
+		{`not not (x.y pr)`, `not not x.y pr`},
+		{`tier in ["gold","vip" , 1, true, -2.5]`, `tier in ["gold", "vip", 1, true, -2.5]`},
+		{`id not in [9007199254740993]`, `id not in [9007199254740993]`},
+		{`name eq "say \"hi\"\n\\"`, `name eq "say \"hi\"\n\\"`},
+		{`flag`, `flag`},
+		{`last_login dl 0.5`, `last_login dl 0.5`},
+		{`a+b*c gt 10`, `a + b * c gt 10`},
+		{`(a + b) * (c - d) % 3`, `(a + b) * (c - d) % 3`},
+		{`a - (b - c) - d`, `a - (b - c) - d`},
+		{`(a * b) / c eq -2`, `a * b / c eq -2`},
+		{`x * -1 lt y -1`, `x * -1 lt y - 1`},
+		{`(a gt 1) eq (b lt 2)`, `(a gt 1) eq (b lt 2)`},
+		{`if a then 1 else if b then 2 else 3`, `if a then 1 else if b then 2 else 3`},
+		{`(if a then 1 else 2) * 3 gt x`, `(if a then 1 else 2) * 3 gt x`},
+		{`x gt (if a then 1 else 2)`, `x gt (if a then 1 else 2)`},
+		{`case when a then 1 else 2 end`, `if a then 1 else 2`},
+		{`case when a gt 1 then "x" when b then "y" end eq c`, `case when a gt 1 then "x" when b then "y" end eq c`},
+		{`not case when a then b end`, `not case when a then b end`},
+		{`event.end af event.start`, `event.end af event.start`},
+		{`let t = a + b in t gt 1`, `let t = a + b in t gt 1`},
+		{`let a = x in let b = a * 2 in b gt a`, `let a = x, b = a * 2 in b gt a`},
+		{`let t = (x in [1, 2]) in t eq true`, `let t = (x in [1, 2]) in t eq true`},
+		{`(let t = a in t + 1) gt 2`, `(let t = a in t + 1) gt 2`},
+		{`order.let eq 1`, `order.let eq 1`},
+		{`last_login af now() - 7d`, `last_login af now() - 7d`},
+		{`expires_at be created_at + P14M`, `expires_at be created_at + P1Y2M`},
+		{`x eq 90m and y in [1d, -2w]`, `x eq 1h30m and y in [1d, -14d]`},
+		{`x - -1d`, `x - -1d`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			ast, err := ParseRule(tt.rule)
+			require.NoError(t, err)
+
+			formatted := Format(ast)
+			require.Equal(t, tt.expected, formatted)
+
+			reparsed, err := ParseRule(formatted)
+			require.NoError(t, err)
+			require.Equal(t, clearSpans(ast), clearSpans(reparsed))
+			require.Equal(t, formatted, Format(reparsed))
+		})
+	}
+}
+
+func TestFormatCanonicalOperators(t *testing.T) {
+	ast, err := ParseRule(`a == "b" and c != 2`)
+	require.NoError(t, err)
+	require.Equal(t, `a eq "b" and c ne 2`, Format(ast))
+}
+
+func TestFormatConstructedAST(t *testing.T) {
+	ast := NewUnaryOpNode(NOT, NewBinaryOpNode(
+		AND,
+		NewBinaryOpNode(GT, NewIdentifierNode("a"), NewNumberLiteralNode(1)),
+		NewBinaryOpNode(OR, NewIdentifierNode("b"), NewIdentifierNode("c")),
+	))
+
+	require.Equal(t, `not (a gt 1 and (b or c))`, Format(ast))
+}
+
+// clearSpans zeroes the source positions of an AST so trees from different sources can be compared.
+func clearSpans(node *ASTNode) *ASTNode {
+	if node == nil {
+		return nil
+	}
+
+	node.Start, node.End = 0, 0
+	clearSpans(node.Left)
+	clearSpans(node.Right)
+
+	for _, child := range node.Children {
+		clearSpans(child)
+	}
+
+	return node
+}
@@ -1,6 +1,7 @@
 package rule
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -462,3 +463,82 @@ func FuzzMixedTypeComparisons(f *testing.F) {
 		}
 	})
 }
+
+// FuzzParseRuleWithRecovery checks that the recovering parser terminates without panicking and
+// agrees with ParseRule: valid rules produce no diagnostics, invalid rules at least one.
+func FuzzParseRuleWithRecovery(f *testing.F) {
+	f.Add("age gt 18 and name eq \"John\"")
+	f.Add("a eq and b gt or c in \"x\"")
+	f.Add("(x eq 1 y) and (z pr or ) and w eq 3")
+	f.Add("((a eq 1) or")
+	f.Add(")) and (( or")
+	f.Add("a eq () or name co 5")
+	f.Add("name eq \"open")
+	f.Add("not not not")
+	f.Add("[1, 2")
+
+	f.Fuzz(func(t *testing.T, rule string) {
+		ast, diagnostics := ParseRuleWithRecovery(rule)
+
+		_, err := ParseRule(rule)
+		if err == nil && len(diagnostics) > 0 {
+			t.Errorf("Valid rule %q produced diagnostics: %v", rule, diagnostics[0])
+		}
+
+		if err != nil && len(diagnostics) == 0 {
+			t.Errorf("Invalid rule %q produced no diagnostics, ParseRule failed with %v", rule, err)
+		}
+
+		if err == nil && ast == nil {
+			t.Errorf("Valid rule %q produced no AST", rule)
+		}
+	})
+}
+
+// FuzzPathologicalNesting builds deeply nested rules from a nesting depth and a pattern of not
+// operators and parentheses, checking that parsing never exhausts the stack and that rules nested
+// beyond DefaultMaxDepth are rejected with ErrMaxDepthExceeded.
+func FuzzPathologicalNesting(f *testing.F) {
+	f.Add(uint16(10), byte(0), "a eq 1", true)
+	f.Add(uint16(DefaultMaxDepth), byte(1), "a pr", true)
+	f.Add(uint16(DefaultMaxDepth+1), byte(0), "a eq 1", true)
+	f.Add(uint16(50000), byte(2), "a eq 1", false)
+	f.Add(uint16(65535), byte(3), "", true)
+
+	openers := []string{"(", "not ", "not (", "( not "}
+
+	f.Fuzz(func(t *testing.T, depth uint16, pattern byte, leaf string, balanced bool) {
+		var sb strings.Builder
+
+		levels, groups := 0, 0
+
+		for i := range int(depth) {
+			opener := openers[(int(pattern)+i*int(pattern>>2))%len(openers)]
+			sb.WriteString(opener)
+
+			levels += strings.Count(opener, "(") + strings.Count(opener, "not")
+			groups += strings.Count(opener, "(")
+		}
+
+		sb.WriteString(leaf)
+
+		if balanced {
+			sb.WriteString(strings.Repeat(")", groups))
+		}
+
+		rule := sb.String()
+
+		_, err := ParseRule(rule)
+		if levels > DefaultMaxDepth && !errors.Is(err, ErrMaxDepthExceeded) {
+			t.Errorf("Rule nested %d levels deep was not rejected for its depth: %v", levels, err)
+		}
+
+		_, diagnostics := ParseRuleWithRecovery(rule)
+		if err != nil && len(diagnostics) == 0 {
+			t.Errorf("Invalid rule nested %d levels deep produced no diagnostics, ParseRule failed with %v",
+				levels, err)
+		}
+
+		_, _ = NewEngine().Evaluate(rule, D{"a": 1})
+	})
+}
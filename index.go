@@ -0,0 +1,259 @@
+package rule
+
+import (
+	"slices"
+	"strings"
+)
+
+// maxIndexClauses bounds the clauses distributing or over and may produce while extracting
+// constraints, so rules with long or-of-and chains stay cheap to index.
+const maxIndexClauses = 64
+
+// Index finds the rules that match a context among many stored rules without evaluating all of
+// them. From each rule it extracts a clause of equality and membership tests on attributes that
+// must hold whenever the rule does: for `country eq "BR" and (tier eq "gold" or tier eq "vip")`
+// either country is "BR" or tier is "gold" or "vip". The rule is stored under each of those
+// values, and a lookup only evaluates the rules stored under the values the context actually has,
+// plus the rules no clause could be extracted from. Equality follows the evaluator, so strings
+// match case-insensitively and numbers match across integer and float types.
+//
+// Results are identical to evaluating every rule, except that rules pruned by the index are not
+// evaluated and so cannot report evaluation errors. An Index is immutable and safe for concurrent
+// use.
+type Index struct {
+	rules []*CompiledRule
+	// attributes holds one node per indexed attribute path, used to read it from the context.
+	attributes []*ASTNode
+	// postings maps, per attribute, a value to the rules stored under it, in ascending order.
+	postings []map[indexKey][]int
+	// unindexed lists the rules without a clause, in ascending order.
+	unindexed []int
+	evaluator *Evaluator
+}
+
+// indexKey identifies a value up to the equality of the evaluator.
+type indexKey struct {
+	valueType ValueType
+	str       string
+	num       float64
+	boolean   bool
+	duration  Duration
+}
+
+// indexTerm is an attribute that equals one of a set of values.
+type indexTerm struct {
+	attribute *ASTNode
+	path      string
+	keys      []indexKey
+}
+
+// indexClause is a disjunction of terms.
+type indexClause []indexTerm
+
+// NewIndex builds an index over the rules. Rules are identified by their position.
+func NewIndex(rules ...*CompiledRule) *Index {
+	index := &Index{rules: slices.Clone(rules), evaluator: NewEvaluator()}
+	attributes := make(map[string]int)
+
+	for id, compiled := range rules {
+		clause, found := bestClause(indexClauses(compiled.AST))
+		if !found {
+			index.unindexed = append(index.unindexed, id)
+			continue
+		}
+
+		for _, term := range clause {
+			position, exists := attributes[term.path]
+			if !exists {
+				position = len(index.attributes)
+				attributes[term.path] = position
+				index.attributes = append(index.attributes, term.attribute)
+				index.postings = append(index.postings, make(map[indexKey][]int))
+			}
+
+			for _, key := range term.keys {
+				postings := index.postings[position][key]
+				if len(postings) == 0 || postings[len(postings)-1] != id {
+					index.postings[position][key] = append(postings, id)
+				}
+			}
+		}
+	}
+
+	return index
+}
+
+// Len returns the number of rules in the index.
+func (x *Index) Len() int {
+	return len(x.rules)
+}
+
+// Evaluate returns the positions of the rules that match the context, in ascending order.
+func (x *Index) Evaluate(context D) ([]int, error) {
+	var matches []int
+
+	for _, id := range x.candidates(context) {
+		matched, err := x.evaluator.Evaluate(x.rules[id].AST, context)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			matches = append(matches, id)
+		}
+	}
+
+	return matches, nil
+}
+
+// candidates returns, in ascending order, the rules that may match the context.
+func (x *Index) candidates(context D) []int {
+	candidates := slices.Clone(x.unindexed)
+
+	for position, attribute := range x.attributes {
+		var value EvalResult
+		if err := x.evaluator.evaluateNode(attribute, context, &value); err != nil || !value.IsValid {
+			continue
+		}
+
+		var buffer [2]indexKey
+
+		for _, key := range resultKeys(&value, buffer[:0]) {
+			candidates = append(candidates, x.postings[position][key]...)
+		}
+	}
+
+	slices.Sort(candidates)
+
+	return slices.Compact(candidates)
+}
+
+// indexClauses converts a node into a conjunction of clauses it implies. Parts that are not
+// equality or membership tests imply nothing and contribute no clauses.
+func indexClauses(node *ASTNode) []indexClause {
+	switch {
+	case node.Type == NodeBinaryOp && node.Operator == AND:
+		return append(indexClauses(node.Left), indexClauses(node.Right)...)
+
+	case node.Type == NodeBinaryOp && node.Operator == OR:
+		left, right := indexClauses(node.Left), indexClauses(node.Right)
+		if len(left) == 0 || len(right) == 0 || len(left)*len(right) > maxIndexClauses {
+			return nil
+		}
+
+		clauses := make([]indexClause, 0, len(left)*len(right))
+
+		for _, l := range left {
+			for _, r := range right {
+				clauses = append(clauses, append(slices.Clone(l), r...))
+			}
+		}
+
+		return clauses
+
+	case node.Type == NodeUnaryOp && node.Operator == NOT:
+		// not (not x) is x; other negations are opaque: not (x ne 1) is also true when x is missing.
+		if inner := node.Left; inner.Type == NodeUnaryOp && inner.Operator == NOT {
+			return indexClauses(inner.Left)
+		}
+
+		return nil
+	}
+
+	if term, ok := indexTermOf(node); ok {
+		return []indexClause{{term}}
+	}
+
+	return nil
+}
+
+// indexTermOf recognises attribute eq literal, literal eq attribute and attribute in [literals].
+func indexTermOf(node *ASTNode) (indexTerm, bool) {
+	if node.Type != NodeBinaryOp {
+		return indexTerm{}, false
+	}
+
+	attribute, literal := node.Left, node.Right
+
+	switch node.Operator { //nolint:exhaustive // only equality and membership are indexable
+	case EQ, EQUALS:
+		if literal.IsIdentifier() {
+			attribute, literal = literal, attribute
+		}
+	case IN:
+		if literal.Type != NodeLiteral || literal.Value.Type != ValueArray {
+			return indexTerm{}, false
+		}
+	default:
+		return indexTerm{}, false
+	}
+
+	path, ok := attributePath(attribute)
+	if !ok || literal.Type != NodeLiteral {
+		return indexTerm{}, false
+	}
+
+	values := []Value{literal.Value}
+	if node.Operator == IN {
+		values = literal.Value.ArrValue
+	}
+
+	term := indexTerm{attribute: attribute, path: strings.Join(path, ".")}
+
+	var evaluator Evaluator
+
+	for i := range values {
+		var result EvalResult
+
+		evaluator.setResultFromValue(&result, &values[i])
+		term.keys = resultKeys(&result, term.keys)
+	}
+
+	return term, true
+}
+
+// bestClause picks the clause with the fewest values, which prunes the most.
+func bestClause(clauses []indexClause) (indexClause, bool) {
+	var (
+		best      indexClause
+		bestWidth = -1
+	)
+
+	for _, clause := range clauses {
+		width := 0
+		for _, term := range clause {
+			width += len(term.keys)
+		}
+
+		if bestWidth < 0 || width < bestWidth {
+			best, bestWidth = clause, width
+		}
+	}
+
+	return best, bestWidth >= 0
+}
+
+// resultKeys appends the keys of a value. Values that are equal for eq and in share a key; arrays
+// equal nothing and have none. Integers compare as int64 and other numbers as float64, so an
+// integer is keyed by both when they differ, as for unsigned values beyond the int64 range.
+func resultKeys(result *EvalResult, keys []indexKey) []indexKey {
+	switch result.Type {
+	case ValueString:
+		return append(keys, indexKey{valueType: ValueString, str: asciiLower(result.Str)})
+	case ValueNumber:
+		keys = append(keys, indexKey{valueType: ValueNumber, num: result.Num})
+		if result.IsInt && float64(result.IntValue) != result.Num {
+			keys = append(keys, indexKey{valueType: ValueNumber, num: float64(result.IntValue)})
+		}
+
+		return keys
+	case ValueBoolean:
+		return append(keys, indexKey{valueType: ValueBoolean, boolean: result.Bool})
+	case ValueDuration:
+		return append(keys, indexKey{valueType: ValueDuration, duration: result.Dur})
+	case ValueArray, ValueIdentifier:
+		return keys
+	}
+
+	return keys
+}
@@ -0,0 +1,198 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// indexRules generates count targeting rules mixing indexable and unindexable shapes.
+func indexRules(count int) []string {
+	countries := []string{"BR", "US", "PT", "AR", "MX", "CL", "CO", "PE"}
+	rules := make([]string, count)
+
+	for i := range rules {
+		country := countries[i%len(countries)]
+		segment := i % 97
+
+		switch i % 6 {
+		case 0:
+			rules[i] = fmt.Sprintf(`country eq %q and segment eq %d`, country, segment)
+		case 1:
+			rules[i] = fmt.Sprintf(`segment in [%d, %d] and (tier eq "gold" or tier eq "vip")`, segment, segment+1)
+		case 2:
+			rules[i] = fmt.Sprintf(`(country eq %q and age ge 18) or (user.id == %d and vip)`, country, i)
+		case 3:
+			rules[i] = fmt.Sprintf(`not (country eq %q) and segment eq %d`, country, segment)
+		case 4:
+			rules[i] = fmt.Sprintf(`age gt %d or email ew ".com"`, i%80)
+		default:
+			rules[i] = fmt.Sprintf(`%d == segment and not (not (tier in ["gold", "silver"]))`, segment)
+		}
+	}
+
+	return rules
+}
+
+func indexContexts() []D {
+	return []D{
+		{"country": "BR", "segment": 0, "tier": "gold", "age": 30},
+		{"country": "br", "segment": 8.0, "tier": "VIP", "age": 10},
+		{"country": "US", "segment": uint8(49), "user": D{"id": 2, "x": 1}, "vip": true},
+		{"country": "PT", "segment": int64(5), "tier": "silver", "email": "a@b.com"},
+		{"country": []any{"BR"}, "segment": "0", "tier": true},
+		{"user": D{"id": 14.0}, "vip": 1, "country": "CL"},
+		{},
+	}
+}
+
+func bruteForce(t *testing.T, rules []*CompiledRule, context D) []int {
+	t.Helper()
+
+	var matches []int
+
+	for i, compiled := range rules {
+		matched, err := NewEvaluator().Evaluate(compiled.AST, context)
+		require.NoError(t, err)
+
+		if matched {
+			matches = append(matches, i)
+		}
+	}
+
+	return matches
+}
+
+func TestIndex(t *testing.T) {
+	t.Run("MatchesBruteForce", testIndexMatchesBruteForce)
+	t.Run("Clauses", testIndexClauses)
+	t.Run("Equality", testIndexEquality)
+	t.Run("Prunes", testIndexPrunes)
+}
+
+func testIndexMatchesBruteForce(t *testing.T) {
+	compiled := compileNetworkRules(t, NewEngine(), indexRules(600))
+	index := NewIndex(compiled...)
+
+	require.Equal(t, len(compiled), index.Len())
+
+	for _, context := range indexContexts() {
+		matches, err := index.Evaluate(context)
+		require.NoError(t, err)
+		require.Equal(t, bruteForce(t, compiled, context), matches, "context=%v", context)
+	}
+}
+
+func testIndexClauses(t *testing.T) {
+	tests := []struct {
+		rule   string
+		clause string
+	}{
+		{`country eq "BR"`, `country:1`},
+		{`"BR" == country and age gt 18`, `country:1`},
+		{`tier in ["gold", "vip"] and country eq "BR"`, `country:1`},
+		{`(a eq 1 and b eq 2) or (c eq 3 and d in [4, 5])`, `a:1 c:1`},
+		{`(a eq 1 or b eq 2) and c in [1, 2, 3]`, `a:1 b:1`},
+		{`not (not (a eq 1))`, `a:1`},
+		{`x in []`, `x:0`},
+		{`not (a eq 1)`, ``},
+		{`a eq 1 or b gt 2`, ``},
+		{`a ne 1`, ``},
+		{`a eq b`, ``},
+		{`"x" in tags`, ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			ast, err := ParseRule(tt.rule)
+			require.NoError(t, err)
+
+			clause, found := bestClause(indexClauses(ast))
+			require.Equal(t, tt.clause != "", found)
+
+			var terms []string
+			for _, term := range clause {
+				terms = append(terms, fmt.Sprintf("%s:%d", term.path, len(term.keys)))
+			}
+
+			require.Equal(t, tt.clause, strings.Join(terms, " "))
+		})
+	}
+}
+
+func testIndexEquality(t *testing.T) {
+	rules := []string{
+		`name eq "Ann"`,
+		`n eq 1`,
+		`n in [2.5, 3]`,
+		`big eq 9007199254740991`,
+		`flag eq true`,
+		`flag in ["true"]`,
+		`n == 1.0`,
+	}
+
+	compiled := compileNetworkRules(t, NewEngine(), rules)
+	index := NewIndex(compiled...)
+
+	for _, context := range []D{
+		{"name": "aNN"},
+		{"n": 1},
+		{"n": 1.0},
+		{"n": uint(3)},
+		{"n": float32(2.5)},
+		{"big": uint64(1 << 63)},
+		{"big": int64(9007199254740991)},
+		{"flag": true},
+		{"flag": "TRUE"},
+		{"name": []any{"Ann"}, "n": "1"},
+	} {
+		matches, err := index.Evaluate(context)
+		require.NoError(t, err)
+		require.Equal(t, bruteForce(t, compiled, context), matches, "context=%v", context)
+	}
+}
+
+func testIndexPrunes(t *testing.T) {
+	compiled := compileNetworkRules(t, NewEngine(), indexRules(6000))
+	index := NewIndex(compiled...)
+
+	require.Len(t, index.unindexed, 1000, "only the ge/ew rules cannot be indexed")
+
+	candidates := index.candidates(D{"country": "BR", "segment": 3, "tier": "gold"})
+	require.Less(t, len(candidates), len(index.unindexed)+600, "most indexed rules are pruned")
+	require.Subset(t, candidates, bruteForce(t, compiled, D{"country": "BR", "segment": 3, "tier": "gold"}))
+}
+
+func BenchmarkIndex(b *testing.B) {
+	engine := NewEngine()
+	compiled := compileNetworkRules(b, engine, indexRules(100000))
+	context := indexContexts()[0]
+
+	b.Run("Index/100000", func(b *testing.B) {
+		index := NewIndex(compiled...)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for range b.N {
+			if _, err := index.Evaluate(context); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("BruteForce/100000", func(b *testing.B) {
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for range b.N {
+			for _, rule := range compiled {
+				if _, err := engine.EvaluateCompiled(rule, context); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
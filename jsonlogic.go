@@ -0,0 +1,527 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JSONLogic operator names shared by the importer and the exporter.
+const (
+	jsonLogicVar     = "var"
+	jsonLogicNot     = "!"
+	jsonLogicIn      = "in"
+	jsonLogicMissing = "missing"
+	jsonLogicCat     = "cat"
+	jsonLogicIf      = "if"
+)
+
+// ToJSONLogic converts a parsed rule into a JSONLogic document built from maps, slices and scalars,
+// ready to be encoded with encoding/json.
+//
+// Substring checks (co) are exported as {"in": [needle, {"cat": [haystack]}]} so that they can be told
+// apart from array membership when the document is imported again. Operators without a JSONLogic
+// equivalent (sw, ew and the datetime operators) return an error wrapping ErrUnsupportedTranslation.
+//
+// JSONLogic has no case-insensitive comparison, so a JSONLogic evaluator compares the strings of
+// eq, ne and co case-sensitively where the engine does not. It also treats missing variables as
+// null, so ne and not in are true for a missing attribute where the engine is false. Rules that
+// compare strings or read optional attributes can therefore give different results there.
+func ToJSONLogic(node *ASTNode) (any, error) {
+	if node == nil {
+		return nil, ErrInvalidNode
+	}
+
+	switch node.Type {
+	case NodeLiteral:
+		if err := checkLiteral(&node.Value); err != nil {
+			return nil, err
+		}
+
+		return literalToAny(&node.Value), nil
+	case NodeIdentifier, NodeProperty:
+		path, _ := attributePath(node)
+		return D{jsonLogicVar: strings.Join(path, ".")}, nil
+	case NodeUnaryOp:
+		return unaryToJSONLogic(node)
+	case NodeBinaryOp:
+		return binaryToJSONLogic(node)
+	case NodeConditional:
+		args := make([]any, len(node.Children))
+
+		for i, child := range node.Children {
+			arg, err := ToJSONLogic(child)
+			if err != nil {
+				return nil, err
+			}
+
+			args[i] = arg
+		}
+
+		return D{jsonLogicIf: args}, nil
+	case NodeParameter:
+		return nil, unsupportedTranslation("parameter $" + node.Value.StrValue)
+	case NodeReference:
+		return nil, unsupportedTranslation("reference @" + node.Value.StrValue)
+	case NodeLet, NodeVariable:
+		return nil, unsupportedTranslation("let expression")
+	case NodeCall:
+		return nil, unsupportedTranslation("function " + node.Value.StrValue)
+	case NodeArray:
+		return nil, unsupportedTranslation("array node")
+	case NodeError:
+		return nil, ErrInvalidSyntax
+	default:
+		return nil, ErrInvalidNode
+	}
+}
+
+func unaryToJSONLogic(node *ASTNode) (any, error) {
+	switch node.Operator { //nolint:exhaustive // only NOT and PR are unary operators
+	case NOT:
+		operand, err := ToJSONLogic(node.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		return D{jsonLogicNot: []any{operand}}, nil
+	case PR:
+		path, ok := attributePath(node.Left)
+		if !ok {
+			return nil, unsupportedTranslation("presence check on an expression")
+		}
+
+		return D{jsonLogicNot: []any{D{jsonLogicMissing: []any{strings.Join(path, ".")}}}}, nil
+	default:
+		return nil, unsupportedTranslation("unary operator " + node.Operator.String())
+	}
+}
+
+func binaryToJSONLogic(node *ASTNode) (any, error) {
+	if node.Operator == AND || node.Operator == OR {
+		operands := flattenLogical(node, node.Operator, nil)
+		args := make([]any, 0, len(operands))
+
+		for _, operand := range operands {
+			arg, err := ToJSONLogic(operand)
+			if err != nil {
+				return nil, err
+			}
+
+			args = append(args, arg)
+		}
+
+		return D{node.Operator.String(): args}, nil
+	}
+
+	left, err := ToJSONLogic(node.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := ToJSONLogic(node.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node.Operator { //nolint:exhaustive // logical operators are handled above
+	case IN:
+		return D{jsonLogicIn: []any{left, right}}, nil
+	case NOT_IN:
+		return D{jsonLogicNot: []any{D{jsonLogicIn: []any{left, right}}}}, nil
+	case CO:
+		return D{jsonLogicIn: []any{right, D{jsonLogicCat: []any{left}}}}, nil
+	case PLUS, MINUS, MULTIPLY, DIVIDE, MODULO:
+		return D{node.Operator.String(): []any{left, right}}, nil
+	default:
+		symbol, ok := jsonLogicComparison(node.Operator)
+		if !ok {
+			return nil, unsupportedTranslation("operator " + node.Operator.String() + " has no JSONLogic equivalent")
+		}
+
+		return D{symbol: []any{left, right}}, nil
+	}
+}
+
+// ParseJSONLogic decodes a JSONLogic document and converts it into a validated AST.
+func ParseJSONLogic(data []byte) (*ASTNode, error) {
+	// Decode numbers lazily so that large integers keep their precision
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var logic any
+	if err := decoder.Decode(&logic); err != nil {
+		return nil, err
+	}
+
+	return FromJSONLogic(logic)
+}
+
+// FromJSONLogic converts a decoded JSONLogic document into a validated AST.
+//
+// {"in": [needle, haystack]} is imported as array membership unless the haystack is wrapped in
+// {"cat": [...]}, which is imported as a substring check (co). Operators that the engine cannot
+// express, such as "var" with a default value and arithmetic on more than two operands,
+// return an error wrapping ErrUnsupportedTranslation. The strict "===" and "!==" are imported as
+// eq and ne, which compare strings case-insensitively and numbers regardless of their JSON type.
+func FromJSONLogic(logic any) (*ASTNode, error) {
+	ast, err := jsonLogicNode(logic)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateAST(ast); err != nil {
+		return nil, err
+	}
+
+	return ast, nil
+}
+
+func jsonLogicNode(logic any) (*ASTNode, error) {
+	switch value := logic.(type) {
+	case map[string]any:
+		return jsonLogicOperation(value)
+	case []any:
+		elements := make([]Value, len(value))
+
+		for i, item := range value {
+			element, err := jsonLogicLiteral(item)
+			if err != nil {
+				return nil, err
+			}
+
+			elements[i] = element.Value
+		}
+
+		return NewArrayLiteralNode(elements), nil
+	default:
+		return jsonLogicLiteral(value)
+	}
+}
+
+func jsonLogicLiteral(value any) (*ASTNode, error) {
+	switch v := value.(type) {
+	case string:
+		return NewStringLiteralNode(v), nil
+	case bool:
+		return NewBooleanLiteralNode(v), nil
+	case float64:
+		return NewNumberLiteralNode(v), nil
+	case int:
+		return NewNumberLiteralNode(float64(v)), nil
+	case int64:
+		if v > maxSafeInteger || v < minSafeInteger {
+			return NewLargeIntegerLiteralNode(v), nil
+		}
+
+		return NewNumberLiteralNode(float64(v)), nil
+	case json.Number:
+		return jsonNumberLiteral(v)
+	default:
+		return nil, unsupportedTranslation(fmt.Sprintf("JSONLogic literal of type %T", value))
+	}
+}
+
+func jsonNumberLiteral(number json.Number) (*ASTNode, error) {
+	if intVal, err := number.Int64(); err == nil {
+		return jsonLogicLiteral(intVal)
+	}
+
+	floatVal, err := number.Float64()
+	if err != nil {
+		return nil, unsupportedTranslation("JSONLogic number " + number.String())
+	}
+
+	return NewNumberLiteralNode(floatVal), nil
+}
+
+func jsonLogicOperation(operation map[string]any) (*ASTNode, error) {
+	if len(operation) != 1 {
+		keys := make([]string, 0, len(operation))
+		for key := range operation {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		return nil, unsupportedTranslation("JSONLogic operation must have exactly one operator, got " +
+			strings.Join(keys, ", "))
+	}
+
+	for operator, rawArgs := range operation {
+		args := jsonLogicArgs(rawArgs)
+
+		switch operator {
+		case jsonLogicVar:
+			return jsonLogicVariable(args)
+		case "and", "or":
+			return jsonLogicLogical(operator, args)
+		case jsonLogicNot:
+			return jsonLogicNegation(args)
+		case "!!":
+			return jsonLogicDoubleNegation(args)
+		case jsonLogicMissing:
+			return jsonLogicMissingCheck(args, true)
+		case jsonLogicIn:
+			return jsonLogicMembership(args)
+		case jsonLogicIf:
+			return jsonLogicConditional(args)
+		default:
+			return jsonLogicComparisonNode(operator, args)
+		}
+	}
+
+	return nil, ErrInvalidNode
+}
+
+// jsonLogicArgs normalises the unary shorthand {"op": x} to {"op": [x]}.
+func jsonLogicArgs(raw any) []any {
+	if args, ok := raw.([]any); ok {
+		return args
+	}
+
+	return []any{raw}
+}
+
+func jsonLogicVariable(args []any) (*ASTNode, error) {
+	if len(args) != 1 {
+		return nil, unsupportedTranslation(`"var" with a default value`)
+	}
+
+	path, ok := args[0].(string)
+	if !ok || path == "" {
+		return nil, unsupportedTranslation(`"var" requires a non-empty string path`)
+	}
+
+	segments := strings.Split(path, ".")
+	if len(segments) == 1 {
+		return NewIdentifierNode(path), nil
+	}
+
+	return NewPropertyNode(segments), nil
+}
+
+func jsonLogicLogical(operator string, args []any) (*ASTNode, error) {
+	if len(args) == 0 {
+		return nil, unsupportedTranslation(`"` + operator + `" without operands`)
+	}
+
+	op := AND
+	if operator == "or" {
+		op = OR
+	}
+
+	var result *ASTNode
+
+	for _, arg := range args {
+		operand, err := jsonLogicNode(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if result == nil {
+			result = operand
+		} else {
+			result = NewBinaryOpNode(op, result, operand)
+		}
+	}
+
+	return result, nil
+}
+
+func jsonLogicConditional(args []any) (*ASTNode, error) {
+	if len(args) < 2 { //nolint:mnd // a condition and its value
+		return nil, unsupportedTranslation(`"if" requires a condition and a value`)
+	}
+
+	branches := make([]*ASTNode, len(args))
+
+	for i, arg := range args {
+		branch, err := jsonLogicNode(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		branches[i] = branch
+	}
+
+	return NewConditionalNode(branches...), nil
+}
+
+func jsonLogicNegation(args []any) (*ASTNode, error) {
+	if len(args) != 1 {
+		return nil, unsupportedTranslation(`"!" requires exactly one operand`)
+	}
+
+	if inner, ok := args[0].(map[string]any); ok && len(inner) == 1 {
+		if missing, isMissing := inner[jsonLogicMissing]; isMissing {
+			return jsonLogicMissingCheck(jsonLogicArgs(missing), false)
+		}
+
+		if membership, isIn := inner[jsonLogicIn]; isIn {
+			node, err := jsonLogicMembership(jsonLogicArgs(membership))
+			if err != nil {
+				return nil, err
+			}
+
+			if node.Operator == IN {
+				node.Operator = NOT_IN
+				return node, nil
+			}
+
+			return NewUnaryOpNode(NOT, node), nil
+		}
+	}
+
+	operand, err := jsonLogicNode(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUnaryOpNode(NOT, operand), nil
+}
+
+func jsonLogicDoubleNegation(args []any) (*ASTNode, error) {
+	if len(args) != 1 {
+		return nil, unsupportedTranslation(`"!!" requires exactly one operand`)
+	}
+
+	operand, err := jsonLogicNode(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUnaryOpNode(NOT, NewUnaryOpNode(NOT, operand)), nil
+}
+
+// jsonLogicMissingCheck converts "missing", which is truthy when any listed path is absent.
+// The negated form becomes a conjunction of presence checks.
+func jsonLogicMissingCheck(args []any, missing bool) (*ASTNode, error) {
+	if len(args) == 0 {
+		return nil, unsupportedTranslation(`"missing" without paths`)
+	}
+
+	var present *ASTNode
+
+	for _, arg := range args {
+		variable, err := jsonLogicVariable([]any{arg})
+		if err != nil {
+			return nil, err
+		}
+
+		check := NewUnaryOpNode(PR, variable)
+		if present == nil {
+			present = check
+		} else {
+			present = NewBinaryOpNode(AND, present, check)
+		}
+	}
+
+	if missing {
+		return NewUnaryOpNode(NOT, present), nil
+	}
+
+	return present, nil
+}
+
+func jsonLogicMembership(args []any) (*ASTNode, error) {
+	if len(args) != 2 { //nolint:mnd // needle and haystack
+		return nil, unsupportedTranslation(`"in" requires exactly two operands`)
+	}
+
+	// {"in": [needle, {"cat": [haystack]}]} is the exported form of a substring check
+	if wrapper, ok := args[1].(map[string]any); ok && len(wrapper) == 1 {
+		if cat, isCat := wrapper[jsonLogicCat]; isCat {
+			catArgs := jsonLogicArgs(cat)
+			if len(catArgs) != 1 {
+				return nil, unsupportedTranslation(`"cat" with more than one operand`)
+			}
+
+			return jsonLogicBinary(CO, catArgs[0], args[0])
+		}
+	}
+
+	return jsonLogicBinary(IN, args[0], args[1])
+}
+
+func jsonLogicComparisonNode(operator string, args []any) (*ASTNode, error) {
+	op, ok := jsonLogicOperators[operator]
+	if !ok {
+		return nil, unsupportedTranslation(`JSONLogic operator "` + operator + `"`)
+	}
+
+	// "<" and "<=" accept three operands as a between check
+	if len(args) == 3 && (op == LT || op == LE) { //nolint:mnd // lower bound, value, upper bound
+		lower, err := jsonLogicBinary(op, args[0], args[1])
+		if err != nil {
+			return nil, err
+		}
+
+		upper, err := jsonLogicBinary(op, args[1], args[2])
+		if err != nil {
+			return nil, err
+		}
+
+		return NewBinaryOpNode(AND, lower, upper), nil
+	}
+
+	if len(args) != 2 { //nolint:mnd // binary comparison or arithmetic
+		return nil, unsupportedTranslation(`"` + operator + `" requires exactly two operands`)
+	}
+
+	return jsonLogicBinary(op, args[0], args[1])
+}
+
+func jsonLogicBinary(op TokenType, left, right any) (*ASTNode, error) {
+	leftNode, err := jsonLogicNode(left)
+	if err != nil {
+		return nil, err
+	}
+
+	rightNode, err := jsonLogicNode(right)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBinaryOpNode(op, leftNode, rightNode), nil
+}
+
+// jsonLogicOperators maps JSONLogic comparison and arithmetic operators to engine operators.
+//
+//nolint:gochecknoglobals // Static operator table
+var jsonLogicOperators = map[string]TokenType{
+	"==":  EQ,
+	"===": EQ,
+	"!=":  NE,
+	"!==": NE,
+	"<":   LT,
+	">":   GT,
+	"<=":  LE,
+	">=":  GE,
+	"+":   PLUS,
+	"-":   MINUS,
+	"*":   MULTIPLY,
+	"/":   DIVIDE,
+	"%":   MODULO,
+}
+
+func jsonLogicComparison(op TokenType) (string, bool) {
+	switch op { //nolint:exhaustive // only comparison operators have a JSONLogic symbol
+	case EQ, EQUALS:
+		return "==", true
+	case NE, NOT_EQUALS:
+		return "!=", true
+	case LT:
+		return "<", true
+	case GT:
+		return ">", true
+	case LE:
+		return "<=", true
+	case GE:
+		return ">=", true
+	default:
+		return "", false
+	}
+}
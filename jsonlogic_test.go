@@ -0,0 +1,156 @@
+package rule
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToJSONLogic(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected string
+	}{
+		{`age gt 18`, `{">": [{"var": "age"}, 18]}`},
+		{`user.country eq "BR"`, `{"==": [{"var": "user.country"}, "BR"]}`},
+		{
+			`a == 1 and b != 2 and c le 3`,
+			`{"and": [{"==": [{"var": "a"}, 1]}, {"!=": [{"var": "b"}, 2]}, {"<=": [{"var": "c"}, 3]}]}`,
+		},
+		{`a lt 1 or not (b ge 2)`, `{"or": [{"<": [{"var": "a"}, 1]}, {"!": [{">=": [{"var": "b"}, 2]}]}]}`},
+		{`tier in ["gold", "vip"]`, `{"in": [{"var": "tier"}, ["gold", "vip"]]}`},
+		{`tier not in [1, 2]`, `{"!": [{"in": [{"var": "tier"}, [1, 2]]}]}`},
+		{`name co "Jo"`, `{"in": ["Jo", {"cat": [{"var": "name"}]}]}`},
+		{`user.email pr`, `{"!": [{"missing": ["user.email"]}]}`},
+		{`flag`, `{"var": "flag"}`},
+		{
+			`(if vip then 10 else 0) gt limit`,
+			`{">": [{"if": [{"var": "vip"}, 10, 0]}, {"var": "limit"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			ast, err := ParseRule(tt.rule)
+			require.NoError(t, err)
+
+			logic, err := ToJSONLogic(ast)
+			require.NoError(t, err)
+
+			encoded, err := json.Marshal(logic)
+			require.NoError(t, err)
+			require.JSONEq(t, tt.expected, string(encoded))
+		})
+	}
+}
+
+func TestToJSONLogicUnsupported(t *testing.T) {
+	tests := []string{
+		`name sw "a"`,
+		`name ew "a"`,
+		`created_at af "2024-01-01T00:00:00Z"`,
+		`last_login dl 7`,
+	}
+
+	for _, rule := range tests {
+		t.Run(rule, func(t *testing.T) {
+			ast, err := ParseRule(rule)
+			require.NoError(t, err)
+
+			_, err = ToJSONLogic(ast)
+			require.ErrorIs(t, err, ErrUnsupportedTranslation)
+		})
+	}
+}
+
+func TestFromJSONLogic(t *testing.T) {
+	tests := []struct {
+		logic    string
+		context  D
+		expected bool
+	}{
+		{`{"===": [{"var": "a"}, 1]}`, D{"a": 1}, true},
+		{`{"!==": [{"var": "a"}, 1]}`, D{"a": 1}, false},
+		{`{"<": [1, {"var": "x"}, 10]}`, D{"x": 5}, true},
+		{`{"<=": [1, {"var": "x"}, 10]}`, D{"x": 11}, false},
+		{`{"and": [{">": [{"var": "a"}, 1]}, {"<": [{"var": "b"}, 5]}, true]}`, D{"a": 2, "b": 3}, true},
+		{`{"or": [false, {"==": [{"var": "user.name"}, "ann"]}]}`, D{"user": D{"name": "Ann"}}, true},
+		{`{"!": {"var": "flag"}}`, D{"flag": false}, true},
+		{`{"!!": [{"var": "name"}]}`, D{"name": "x"}, true},
+		{`{"missing": ["a", "b"]}`, D{"a": 1}, true},
+		{`{"!": {"missing": ["a", "b"]}}`, D{"a": 1, "b": 2}, true},
+		{`{"in": [{"var": "tier"}, ["gold", "vip"]]}`, D{"tier": "VIP"}, true},
+		{`{"in": ["admin", {"var": "roles"}]}`, D{"roles": []any{"admin"}}, true},
+		{`{"!": {"in": [{"var": "tier"}, ["gold"]]}}`, D{"tier": "silver"}, true},
+		{`{"in": ["Jo", {"cat": [{"var": "name"}]}]}`, D{"name": "John"}, true},
+		{`{"if": [{"var": "a"}, {"var": "b"}, {"var": "c"}, false, true]}`, D{"c": true}, false},
+		{`{"if": [{"var": "a"}, {"var": "b"}, true]}`, D{"a": true, "b": false}, false},
+	}
+
+	engine := NewEngine()
+
+	for _, tt := range tests {
+		t.Run(tt.logic, func(t *testing.T) {
+			ast, err := ParseJSONLogic([]byte(tt.logic))
+			require.NoError(t, err)
+
+			result, err := engine.EvaluateCompiled(&CompiledRule{AST: ast}, tt.context)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestFromJSONLogicUnsupported(t *testing.T) {
+	tests := []string{
+		`{"+": [1, 2, 3]}`,
+		`{"if": [true]}`,
+		`{"var": ["a", 0]}`,
+		`{"var": ""}`,
+		`{"==": [1]}`,
+		`{"and": []}`,
+		`{"==": [1, 2], "!=": [1, 2]}`,
+		`{"==": [{"var": "a"}, null]}`,
+		`{"in": [{"var": "a"}, [{"var": "b"}]]}`,
+	}
+
+	for _, logic := range tests {
+		t.Run(logic, func(t *testing.T) {
+			_, err := ParseJSONLogic([]byte(logic))
+			require.ErrorIs(t, err, ErrUnsupportedTranslation)
+		})
+	}
+
+	_, err := ParseJSONLogic([]byte(`{"in": [{"var": "a"}, "text"]}`))
+	require.ErrorIs(t, err, ErrInvalidInOperand)
+}
+
+func TestJSONLogicRoundTrip(t *testing.T) {
+	rules := []string{
+		`age gt 18 and user.country eq "BR"`,
+		`a lt 1 or b le 2 or not (c ge 3)`,
+		`tier in ["gold", "vip"] and tier not in ["banned"]`,
+		`"admin" in roles`,
+		`name co "Jo" and email pr`,
+		`score ne 9007199254740993`,
+		`price * (1 - discount) % 7 lt limit / 2 + 1`,
+	}
+
+	for _, rule := range rules {
+		t.Run(rule, func(t *testing.T) {
+			ast, err := ParseRule(rule)
+			require.NoError(t, err)
+
+			logic, err := ToJSONLogic(ast)
+			require.NoError(t, err)
+
+			encoded, err := json.Marshal(logic)
+			require.NoError(t, err)
+
+			imported, err := ParseJSONLogic(encoded)
+			require.NoError(t, err)
+			require.Equal(t, clearSpans(ast), imported)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package rule
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"unicode"
@@ -56,8 +57,20 @@ func (l *Lexer) Tokenize() []Token {
 			l.handleEqualsToken(start)
 		case '!':
 			l.handleNotEqualsToken(start)
+		case '+':
+			l.handleSingleCharToken(PLUS, start)
+		case '*':
+			l.handleSingleCharToken(MULTIPLY, start)
+		case '/':
+			l.handleSingleCharToken(DIVIDE, start)
+		case '%':
+			l.handleSingleCharToken(MODULO, start)
 		case '-':
 			l.handleMinusToken(start)
+		case '$':
+			l.handleParameterToken(start)
+		case '@':
+			l.handleReferenceToken(start)
 		default:
 			l.handleDefaultToken(start)
 		}
@@ -68,7 +81,8 @@ func (l *Lexer) Tokenize() []Token {
 		}
 	}
 
-	l.tokens = append(l.tokens, Token{Type: EOF, Start: l.position, End: l.position})
+	end := min(l.position, len(l.runes))
+	l.tokens = append(l.tokens, Token{Type: EOF, Start: end, End: end})
 
 	return l.tokens
 }
@@ -89,7 +103,7 @@ func (l *Lexer) handleSingleCharToken(tokenType TokenType, start int) {
 func (l *Lexer) handleStringToken(start int) {
 	value, err := l.readString()
 	if err != nil {
-		l.errors = append(l.errors, err)
+		l.errors = append(l.errors, &SyntaxError{Err: err, Start: start, End: len(l.runes)})
 		return
 	}
 
@@ -107,7 +121,7 @@ func (l *Lexer) handleEqualsToken(start int) {
 		l.readChar()
 		l.tokens = append(l.tokens, Token{Type: EQUALS, Start: start, End: l.position - 1})
 	} else {
-		l.readChar()
+		l.handleSingleCharToken(ASSIGN, start)
 	}
 }
 
@@ -121,10 +135,16 @@ func (l *Lexer) handleNotEqualsToken(start int) {
 	}
 }
 
+// handleMinusToken lexes a negative number, unless the minus follows an operand and so subtracts
+// from it: `a -1` is a subtraction while `a eq -1` compares with a negative number.
 func (l *Lexer) handleMinusToken(start int) {
-	// Check if this is a negative number
-	if unicode.IsDigit(l.peekChar()) {
+	if unicode.IsDigit(l.peekChar()) && !l.afterOperand() {
 		l.readChar() // consume the '-'
+
+		if l.handleDurationToken(start, "-") {
+			return
+		}
+
 		value, num, isLargeInt := l.readNumber()
 		// Make it negative
 		value = "-" + value
@@ -148,8 +168,37 @@ func (l *Lexer) handleMinusToken(start int) {
 			})
 		}
 	} else {
-		// Just a minus operator
-		l.readChar()
+		l.handleSingleCharToken(MINUS, start)
+	}
+}
+
+// isPropertyName reports whether a conditional or let keyword directly follows a dot, as in
+// event.end, where it names a property. Other keywords stay reserved.
+func (l *Lexer) isPropertyName(kwType TokenType, start int) bool {
+	switch kwType { //nolint:exhaustive // only the expression keywords double as property names
+	case IF, THEN, ELSE, CASE, WHEN, END, LET:
+		return l.followsDot(start)
+	default:
+		return false
+	}
+}
+
+// followsDot reports whether the token starting at start directly follows a dot.
+func (l *Lexer) followsDot(start int) bool {
+	return len(l.tokens) > 0 && l.tokens[len(l.tokens)-1].Type == DOT && l.tokens[len(l.tokens)-1].End == start
+}
+
+// afterOperand reports whether the last token ends an operand.
+func (l *Lexer) afterOperand() bool {
+	if len(l.tokens) == 0 {
+		return false
+	}
+
+	switch l.tokens[len(l.tokens)-1].Type { //nolint:exhaustive // only tokens that end an operand matter
+	case IDENTIFIER, STRING, NUMBER, BOOLEAN, ARRAY_END, PAREN_CLOSE, DURATION, PARAMETER, REFERENCE, END:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -164,7 +213,42 @@ func (l *Lexer) handleDefaultToken(start int) {
 	}
 }
 
+// handleParameterToken lexes a $name placeholder; the token value is the name without the $.
+func (l *Lexer) handleParameterToken(start int) {
+	l.readChar() // consume the '$'
+
+	if !unicode.IsLetter(l.current) && l.current != '_' {
+		l.errors = append(l.errors, &SyntaxError{
+			Err:   fmt.Errorf("%w: expected parameter name after $", ErrInvalidSyntax),
+			Start: start,
+			End:   start + 1,
+		})
+
+		return
+	}
+
+	name := l.readIdentifier()
+	l.tokens = append(l.tokens, Token{Type: PARAMETER, Value: name, Start: start, End: l.position - 1})
+}
+
+// handleReferenceToken lexes an @name reference to a definition. An @ that is not followed by a
+// name is skipped like any other unsupported character.
+func (l *Lexer) handleReferenceToken(start int) {
+	l.readChar() // consume the '@'
+
+	if !unicode.IsLetter(l.current) && l.current != '_' {
+		return
+	}
+
+	name := l.readIdentifier()
+	l.tokens = append(l.tokens, Token{Type: REFERENCE, Value: name, Start: start, End: l.position - 1})
+}
+
 func (l *Lexer) handleNumberToken(start int) {
+	if l.handleDurationToken(start, "") {
+		return
+	}
+
 	value, num, isLargeInt := l.readNumber()
 	if isLargeInt {
 		// Store large integers as strings to preserve precision
@@ -185,11 +269,43 @@ func (l *Lexer) handleNumberToken(start int) {
 	}
 }
 
+// handleDurationToken lexes a compact duration literal such as 30d or 1h30m at the current digit.
+// It consumes nothing and returns false when the number has no unit, so it is lexed as a number.
+func (l *Lexer) handleDurationToken(start int, sign string) bool {
+	from := l.position - 1
+	to := from
+
+	hasUnit := false
+
+	for to < len(l.runes) && (unicode.IsDigit(l.runes[to]) || unicode.IsLetter(l.runes[to]) || l.runes[to] == '.') {
+		hasUnit = hasUnit || unicode.IsLetter(l.runes[to])
+		to++
+	}
+
+	value := sign + string(l.runes[from:to])
+	if _, err := ParseDuration(value); !hasUnit || err != nil {
+		return false
+	}
+
+	for l.position-1 < to {
+		l.readChar()
+	}
+
+	l.tokens = append(l.tokens, Token{Type: DURATION, Value: value, Start: start, End: l.position - 1})
+
+	return true
+}
+
 func (l *Lexer) handleIdentifierToken(start int) {
+	// An ISO 8601 duration such as P1M or PT1.5S, unless it names a property after a dot
+	if l.current == 'P' && !l.followsDot(start) && l.handleDurationToken(start, "") {
+		return
+	}
+
 	value := l.readIdentifier()
 	tokenType := IDENTIFIER
 
-	if kwType, exists := keywordMap[value]; exists {
+	if kwType, exists := keywordMap[value]; exists && !l.isPropertyName(kwType, start) {
 		tokenType = kwType
 
 		// Check for compound operators like "not in"
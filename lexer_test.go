@@ -182,6 +182,103 @@ func TestLexerComplexExpression(t *testing.T) {
 	}
 }
 
+// Test lexer with arithmetic operators.
+func TestLexerArithmetic(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TokenType
+	}{
+		{`a + b * 2`, []TokenType{IDENTIFIER, PLUS, IDENTIFIER, MULTIPLY, NUMBER, EOF}},
+		{`a/b%c`, []TokenType{IDENTIFIER, DIVIDE, IDENTIFIER, MODULO, IDENTIFIER, EOF}},
+		{`a -1`, []TokenType{IDENTIFIER, MINUS, NUMBER, EOF}},
+		{`(a)-1`, []TokenType{PAREN_OPEN, IDENTIFIER, PAREN_CLOSE, MINUS, NUMBER, EOF}},
+		{`a - -1`, []TokenType{IDENTIFIER, MINUS, NUMBER, EOF}},
+		{`a eq -1`, []TokenType{IDENTIFIER, EQ, NUMBER, EOF}},
+		{`[-1, -2]`, []TokenType{ARRAY_START, NUMBER, COMMA, NUMBER, ARRAY_END, EOF}},
+		{`$p -1`, []TokenType{PARAMETER, MINUS, NUMBER, EOF}},
+		{`@total -1`, []TokenType{REFERENCE, MINUS, NUMBER, EOF}},
+		{`end -1`, []TokenType{END, MINUS, NUMBER, EOF}},
+	}
+
+	for _, tt := range tests {
+		tokens := NewLexer(tt.input).Tokenize()
+		if len(tokens) != len(tt.expected) {
+			t.Errorf("%q: expected %d tokens, got %v", tt.input, len(tt.expected), tokens)
+			continue
+		}
+
+		for i, expectedType := range tt.expected {
+			if tokens[i].Type != expectedType {
+				t.Errorf("%q: token %d: expected %v, got %v", tt.input, i, expectedType, tokens[i].Type)
+			}
+		}
+	}
+
+	if tokens := NewLexer(`a - -1`).Tokenize(); tokens[2].NumValue != -1 {
+		t.Errorf("Expected -1, got %f", tokens[2].NumValue)
+	}
+}
+
+func TestLexerConditionalKeywords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TokenType
+	}{
+		{`if a then 1 else 2`, []TokenType{IF, IDENTIFIER, THEN, NUMBER, ELSE, NUMBER, EOF}},
+		{`case when a then b end`, []TokenType{CASE, WHEN, IDENTIFIER, THEN, IDENTIFIER, END, EOF}},
+		{`event.end`, []TokenType{IDENTIFIER, DOT, IDENTIFIER, EOF}},
+		{`rule.if.then`, []TokenType{IDENTIFIER, DOT, IDENTIFIER, DOT, IDENTIFIER, EOF}},
+		{`event. end`, []TokenType{IDENTIFIER, DOT, END, EOF}},
+		{`x.eq`, []TokenType{IDENTIFIER, DOT, EQ, EOF}},
+		{`let t = a in t`, []TokenType{LET, IDENTIFIER, ASSIGN, IDENTIFIER, IN, IDENTIFIER, EOF}},
+		{`order.let`, []TokenType{IDENTIFIER, DOT, IDENTIFIER, EOF}},
+	}
+
+	for _, tt := range tests {
+		tokens := NewLexer(tt.input).Tokenize()
+		if len(tokens) != len(tt.expected) {
+			t.Errorf("%q: expected %d tokens, got %v", tt.input, len(tt.expected), tokens)
+			continue
+		}
+
+		for i, expectedType := range tt.expected {
+			if tokens[i].Type != expectedType {
+				t.Errorf("%q: token %d: expected %v, got %v", tt.input, i, expectedType, tokens[i].Type)
+			}
+		}
+	}
+}
+
+func TestLexerDurations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TokenType
+	}{
+		{`30d 12h 1h30m 1.5s`, []TokenType{DURATION, DURATION, DURATION, DURATION, EOF}},
+		{`P1M PT15M P1Y2M10DT2H`, []TokenType{DURATION, DURATION, DURATION, EOF}},
+		{`PT1.5S P1Y2M10DT2H30M1.5S`, []TokenType{DURATION, DURATION, EOF}},
+		{`now() - 7d`, []TokenType{IDENTIFIER, PAREN_OPEN, PAREN_CLOSE, MINUS, DURATION, EOF}},
+		{`x eq -7d`, []TokenType{IDENTIFIER, EQ, DURATION, EOF}},
+		{`x -7d`, []TokenType{IDENTIFIER, MINUS, DURATION, EOF}},
+		{`30days`, []TokenType{NUMBER, IDENTIFIER, EOF}},
+		{`x.P1M PX`, []TokenType{IDENTIFIER, DOT, IDENTIFIER, IDENTIFIER, EOF}},
+	}
+
+	for _, tt := range tests {
+		tokens := NewLexer(tt.input).Tokenize()
+		if len(tokens) != len(tt.expected) {
+			t.Errorf("%q: expected %d tokens, got %v", tt.input, len(tt.expected), tokens)
+			continue
+		}
+
+		for i, expectedType := range tt.expected {
+			if tokens[i].Type != expectedType {
+				t.Errorf("%q: token %d: expected %v, got %v", tt.input, i, expectedType, tokens[i].Type)
+			}
+		}
+	}
+}
+
 // Test lexer with unsupported characters.
 func TestLexerUnsupportedCharacters(t *testing.T) {
 	// Test with character that gets skipped
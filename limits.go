@@ -0,0 +1,146 @@
+package rule
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+)
+
+// cancellationCheckInterval is how many evaluation steps pass between checks of the context.
+const cancellationCheckInterval = 256
+
+// Limits bounds the resources a single rule may use, protecting an engine that evaluates rules
+// from untrusted sources. A zero field means no limit. Every limit except MaxSteps is enforced
+// when a rule is compiled; MaxSteps is enforced while a rule is evaluated.
+type Limits struct {
+	// MaxRuleLength is the maximum length of the rule source, in runes.
+	MaxRuleLength int
+	// MaxDepth is the maximum nesting depth of the AST; a single comparison has depth 2. Unlike
+	// the other limits, zero means DefaultMaxDepth, since unbounded nesting can exhaust the stack.
+	MaxDepth int
+	// MaxNodes is the maximum number of AST nodes. Rules that reference definitions are limited to
+	// DefaultMaxInlinedNodes once inlined even when it is zero.
+	MaxNodes int
+	// MaxArraySize is the maximum number of elements in an array literal.
+	MaxArraySize int
+	// MaxSteps is the maximum number of nodes visited by one evaluation.
+	MaxSteps int
+}
+
+// LimitError reports a rule that exceeds one of the engine limits. It unwraps to the sentinel for
+// the limit, such as ErrRuleTooLong or ErrStepBudgetExceeded.
+type LimitError struct {
+	Err   error
+	Limit int
+	// Actual is the measured value, or zero when measuring stopped at the limit.
+	Actual int
+}
+
+func (e *LimitError) Error() string {
+	if e.Actual == 0 {
+		return fmt.Sprintf("%s: the limit is %d", e.Err.Error(), e.Limit)
+	}
+
+	return fmt.Sprintf("%s: %d exceeds the limit of %d", e.Err.Error(), e.Actual, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// checkSource enforces MaxRuleLength before the rule is parsed.
+func (l *Limits) checkSource(rule string) error {
+	if l.MaxRuleLength > 0 {
+		if length := utf8.RuneCountInString(rule); length > l.MaxRuleLength {
+			return &LimitError{Err: ErrRuleTooLong, Limit: l.MaxRuleLength, Actual: length}
+		}
+	}
+
+	return nil
+}
+
+// maxDepth returns the nesting depth the parser and validator enforce.
+func (l *Limits) maxDepth() int {
+	if l == nil || l.MaxDepth <= 0 {
+		return DefaultMaxDepth
+	}
+
+	return l.MaxDepth
+}
+
+// maxInlinedNodes returns the number of nodes a rule may have once its definitions are inlined.
+func (l *Limits) maxInlinedNodes() int {
+	if l == nil || l.MaxNodes <= 0 {
+		return DefaultMaxInlinedNodes
+	}
+
+	return l.MaxNodes
+}
+
+// checkAST enforces the limits on the size of a parsed rule. Its depth is already bounded by the
+// parser and validator.
+func (l *Limits) checkAST(ast *ASTNode) error {
+	if l.MaxNodes <= 0 && l.MaxArraySize <= 0 {
+		return nil
+	}
+
+	var shape astShape
+
+	shape.measure(ast)
+
+	switch {
+	case l.MaxNodes > 0 && shape.nodes > l.MaxNodes:
+		return &LimitError{Err: ErrTooManyNodes, Limit: l.MaxNodes, Actual: shape.nodes}
+	case l.MaxArraySize > 0 && shape.arraySize > l.MaxArraySize:
+		return &LimitError{Err: ErrArrayTooLarge, Limit: l.MaxArraySize, Actual: shape.arraySize}
+	}
+
+	return nil
+}
+
+// astShape accumulates the size of an AST: its node count and largest array literal.
+type astShape struct {
+	nodes     int
+	arraySize int
+}
+
+func (s *astShape) measure(node *ASTNode) {
+	if node == nil {
+		return
+	}
+
+	s.nodes++
+
+	if node.Value.Type == ValueArray {
+		s.arraySize = max(s.arraySize, len(node.Value.ArrValue))
+	}
+
+	s.measure(node.Left)
+	s.measure(node.Right)
+
+	for _, child := range node.Children {
+		s.measure(child)
+	}
+}
+
+// meter counts the steps of a single evaluation and watches its context for cancellation. Only
+// evaluations started through EvaluateContext, or on an engine with MaxSteps set, carry one.
+type meter struct {
+	ctx      context.Context //nolint:containedctx // scoped to a single evaluation
+	maxSteps int
+	steps    int
+}
+
+func (m *meter) step() error {
+	m.steps++
+
+	if m.maxSteps > 0 && m.steps > m.maxSteps {
+		return &LimitError{Err: ErrStepBudgetExceeded, Limit: m.maxSteps}
+	}
+
+	if m.steps%cancellationCheckInterval == 0 {
+		return m.ctx.Err()
+	}
+
+	return nil
+}
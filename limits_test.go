@@ -0,0 +1,157 @@
+package rule
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimits(t *testing.T) {
+	t.Run("CompileTime", testLimitsCompileTime)
+	t.Run("StepBudget", testLimitsStepBudget)
+	t.Run("Cancellation", testLimitsCancellation)
+	t.Run("Unlimited", testLimitsUnlimited)
+	t.Run("ResetCache", testLimitsResetCache)
+	t.Run("MaxDepth", testLimitsMaxDepth)
+}
+
+func testLimitsCompileTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits Limits
+		rule   string
+		err    error
+		limit  int
+		actual int
+	}{
+		{"RuleLength", Limits{MaxRuleLength: 10}, `name eq "héllo"`, ErrRuleTooLong, 10, 15},
+		{"Depth", Limits{MaxDepth: 3}, `not not a eq 1`, ErrMaxDepthExceeded, 3, 0},
+		{"Nodes", Limits{MaxNodes: 6}, `a eq 1 and b eq 2 and c pr`, ErrTooManyNodes, 6, 10},
+		{"ArraySize", Limits{MaxArraySize: 2}, `x in [1, 2, 3]`, ErrArrayTooLarge, 2, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine()
+			engine.SetLimits(tt.limits)
+
+			_, err := engine.CompileRule(tt.rule)
+			require.ErrorIs(t, err, tt.err)
+
+			var limitErr *LimitError
+			require.True(t, errors.As(err, &limitErr))
+			require.Equal(t, tt.limit, limitErr.Limit)
+			require.Equal(t, tt.actual, limitErr.Actual)
+
+			_, err = engine.Evaluate(tt.rule, D{})
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	engine := NewEngine()
+	engine.SetLimits(Limits{MaxRuleLength: 6, MaxDepth: 2, MaxNodes: 3, MaxArraySize: 1})
+
+	result, err := engine.Evaluate(`a eq 1`, D{"a": 1})
+	require.NoError(t, err, "rules at every limit are accepted")
+	require.True(t, result)
+
+	_, err = engine.CompileRule(`a in [1]`)
+	require.ErrorIs(t, err, ErrRuleTooLong)
+	require.Equal(t, "Rule exceeds the maximum length: 8 exceeds the limit of 6", err.Error())
+}
+
+func testLimitsStepBudget(t *testing.T) {
+	engine := NewEngine()
+	engine.SetLimits(Limits{MaxSteps: 7})
+
+	rule := `a eq 1 or b eq 2 or c eq 3`
+
+	// The first comparison short-circuits the or chain after 5 steps.
+	result, err := engine.EvaluateContext(context.Background(), rule, D{"a": 1})
+	require.NoError(t, err)
+	require.True(t, result)
+
+	_, err = engine.EvaluateContext(context.Background(), rule, D{"c": 3})
+	require.ErrorIs(t, err, ErrStepBudgetExceeded)
+	require.EqualError(t, err, ErrStepBudgetExceeded.Error()+": the limit is 7", "steps stop at the limit")
+
+	_, err = engine.Evaluate(rule, D{"c": 3})
+	require.ErrorIs(t, err, ErrStepBudgetExceeded, "the budget applies without a context too")
+
+	compiled, err := engine.CompileRule(rule)
+	require.NoError(t, err)
+
+	_, err = engine.EvaluateCompiled(compiled, D{"c": 3})
+	require.ErrorIs(t, err, ErrStepBudgetExceeded)
+
+	engine.SetLimits(Limits{MaxSteps: 11})
+
+	result, err = engine.EvaluateCompiled(compiled, D{"c": 3})
+	require.NoError(t, err, "a full evaluation takes 11 steps")
+	require.True(t, result)
+}
+
+func testLimitsCancellation(t *testing.T) {
+	engine := NewEngine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := engine.EvaluateContext(ctx, `a eq 1`, D{"a": 1})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// A long rule notices the cancellation part-way through.
+	rule := strings.Repeat("a eq 2 or ", cancellationCheckInterval) + "a eq 1"
+
+	compiled, err := engine.CompileRule(rule)
+	require.NoError(t, err)
+
+	evaluator := Evaluator{meter: &meter{ctx: ctx}}
+
+	_, err = evaluator.Evaluate(compiled.AST, D{"a": 1})
+	require.ErrorIs(t, err, context.Canceled)
+
+	result, err := engine.EvaluateContext(context.Background(), rule, D{"a": 1})
+	require.NoError(t, err)
+	require.True(t, result)
+}
+
+func testLimitsUnlimited(t *testing.T) {
+	engine := NewEngine()
+	engine.SetLimits(Limits{})
+
+	rule := strings.Repeat("(", 200) + "a eq 1" + strings.Repeat(")", 200)
+
+	result, err := engine.EvaluateContext(context.Background(), rule, D{"a": 1})
+	require.NoError(t, err)
+	require.True(t, result)
+}
+
+func testLimitsResetCache(t *testing.T) {
+	engine := NewEngine()
+
+	require.NoError(t, engine.AddQuery(`a in [1, 2, 3]`))
+
+	engine.SetLimits(Limits{MaxArraySize: 2})
+
+	_, err := engine.Evaluate(`a in [1, 2, 3]`, D{"a": 1})
+	require.ErrorIs(t, err, ErrArrayTooLarge, "cached rules are checked against new limits")
+}
+
+func testLimitsMaxDepth(t *testing.T) {
+	rule := strings.Repeat("not ", DefaultMaxDepth) + "a eq 1"
+
+	engine := NewEngine()
+
+	_, err := engine.CompileRule(rule)
+	require.ErrorIs(t, err, ErrMaxDepthExceeded, "the default depth applies without limits")
+
+	engine.SetLimits(Limits{MaxDepth: 2 * DefaultMaxDepth})
+
+	result, err := engine.Evaluate(rule, D{"a": 1})
+	require.NoError(t, err)
+	require.True(t, result)
+}
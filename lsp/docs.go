@@ -0,0 +1,59 @@
+package lsp
+
+import "github.com/NSXBet/rule"
+
+// operatorDocs holds the hover and completion documentation of every operator and keyword.
+//
+//nolint:gochecknoglobals // Static documentation table
+var operatorDocs = map[rule.TokenType]string{
+	rule.EQ:         "**eq** — equal. Strings compare case-insensitively.\n\n`user.country eq \"BR\"`",
+	rule.EQUALS:     "**==** — alias of `eq`.\n\n`user.age == 18`",
+	rule.NE:         "**ne** — not equal. Strings compare case-insensitively.\n\n`status ne \"banned\"`",
+	rule.NOT_EQUALS: "**!=** — alias of `ne`.\n\n`user.age != 18`",
+	rule.LT:         "**lt** — less than.\n\n`order.amount lt 100`",
+	rule.GT:         "**gt** — greater than.\n\n`user.age gt 18`",
+	rule.LE:         "**le** — less than or equal.\n\n`order.amount le 99.5`",
+	rule.GE:         "**ge** — greater than or equal.\n\n`user.age ge 18`",
+	rule.CO:         "**co** — string contains, case-insensitive.\n\n`user.email co \"@example\"`",
+	rule.SW:         "**sw** — string starts with, case-insensitive.\n\n`user.name sw \"Jo\"`",
+	rule.EW:         "**ew** — string ends with, case-insensitive.\n\n`user.email ew \".com\"`",
+	rule.IN:         "**in** — membership in an array literal or array attribute.\n\n`tier in [\"gold\", \"vip\"]`",
+	rule.NOT_IN:     "**not in** — the value is not a member of the array.\n\n`user.tier not in [\"banned\"]`",
+	rule.PR:         "**pr** — present: the attribute exists in the context.\n\n`user.email pr`",
+	rule.DQ: "**dq** — datetime equal. Operands are RFC 3339 strings or Unix timestamps.\n\n" +
+		"`event.start dq \"2024-07-10T10:00:00Z\"`",
+	rule.DN: "**dn** — datetime not equal.\n\n`event.start dn 1720605600`",
+	rule.BE: "**be** — datetime before.\n\n`event.start be \"2024-07-10T10:00:00Z\"`",
+	rule.BQ: "**bq** — datetime before or equal.\n\n`event.start bq \"2024-07-10T10:00:00Z\"`",
+	rule.AF: "**af** — datetime after.\n\n`event.start af \"2024-07-10T10:00:00Z\"`",
+	rule.AQ: "**aq** — datetime after or equal, comparing instants across time zones.\n\n" +
+		"`event.start aq \"2024-07-10T10:00:00+02:00\"`",
+	rule.DL: "**dl** — days less than: the datetime lies less than N days before now. " +
+		"N may be fractional or a duration.\n\n`user.last_login dl 7`",
+	rule.DG: "**dg** — days greater than: the datetime lies more than N days before now. " +
+		"N may be fractional or a duration.\n\n`user.created_at dg 30`",
+	rule.PLUS: "**+** — addition, or a datetime shifted by a duration.\n\n" +
+		"`order.subtotal + order.shipping gt 100`",
+	rule.MINUS: "**-** — subtraction, or a datetime shifted back by a duration.\n\n" +
+		"`user.last_login af now() - 7d`",
+	rule.MULTIPLY: "**\\*** — multiplication.\n\n`order.amount * 0.9`",
+	rule.DIVIDE:   "**/** — division; dividing by zero has no value.\n\n`order.total / order.items gt 50`",
+	rule.MODULO:   "**%** — remainder of a division.\n\n`user.id % 10 lt 3`",
+	rule.AND:      "**and** — both conditions must hold; the right side is skipped when the left is false.",
+	rule.OR:       "**or** — either condition holds; the right side is skipped when the left is true.",
+	rule.NOT:      "**not** — negates the following condition.\n\n`not (user.banned eq true)`",
+	rule.BOOLEAN:  "**true** / **false** — boolean literal.",
+	rule.IF: "**if** — conditional value; the branch not taken is not evaluated.\n\n" +
+		"`(if user.vip then 0 else order.shipping) lt 10`",
+	rule.THEN: "**then** — separates a condition from the value it selects.",
+	rule.ELSE: "**else** — the value used when no condition holds.",
+	rule.CASE: "**case** — the value of the first `when` branch whose condition holds.\n\n" +
+		"`case when score ge 90 then \"A\" when score ge 80 then \"B\" else \"C\" end eq grade`",
+	rule.WHEN: "**when** — a branch of a `case` expression.",
+	rule.END:  "**end** — closes a `case` expression.",
+	rule.LET: "**let** — binds a value to a name for the rest of the rule; the value is computed once.\n\n" +
+		"`let total = order.subtotal + order.shipping in total gt 100 and total lt 500`",
+	rule.DURATION: "**duration** — `30d`, `12h`, `15m`, `45s` or ISO 8601 such as `P1M`; " +
+		"months are calendar months.\n\n" +
+		"`expires_at be created_at + 30d`",
+}
@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// document is an open text document. Like the rule command's rule files, it holds one rule per
+// line; blank lines and lines starting with # are ignored.
+type document struct {
+	uri     string
+	version int
+	lines   []string
+}
+
+func newDocument(uri string, version int, text string) *document {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+
+	return &document{uri: uri, version: version, lines: lines}
+}
+
+// isRule reports whether a line holds a rule rather than a comment or whitespace.
+func isRule(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#")
+}
+
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+// line returns the text of a line, or an empty string when it is out of range.
+func (d *document) line(index int) string {
+	if index < 0 || index >= len(d.lines) {
+		return ""
+	}
+
+	return d.lines[index]
+}
+
+// toCharacter converts a rune offset within line to an LSP UTF-16 character offset.
+func toCharacter(line string, offset int) int {
+	character := 0
+
+	for i, r := range []rune(line) {
+		if i >= offset {
+			break
+		}
+
+		character += utf16.RuneLen(r)
+	}
+
+	return character
+}
+
+// toOffset converts an LSP UTF-16 character offset within line to a rune offset.
+func toOffset(line string, character int) int {
+	units := 0
+
+	for i, r := range []rune(line) {
+		if units >= character {
+			return i
+		}
+
+		units += utf16.RuneLen(r)
+	}
+
+	return len([]rune(line))
+}
+
+// spanRange builds the range of a rune span on a line, widening empty spans to one character so
+// editors can display them.
+func spanRange(lineIndex int, line string, start, end int) Range {
+	length := len([]rune(line))
+	start = min(max(start, 0), length)
+	end = min(max(end, start), length)
+
+	if end == start && end < length {
+		end++
+	}
+
+	return Range{
+		Start: Position{Line: lineIndex, Character: toCharacter(line, start)},
+		End:   Position{Line: lineIndex, Character: toCharacter(line, end)},
+	}
+}
+
+// lineRange spans a whole line.
+func lineRange(lineIndex int, line string) Range {
+	return Range{
+		Start: Position{Line: lineIndex},
+		End:   Position{Line: lineIndex, Character: toCharacter(line, len([]rune(line)))},
+	}
+}
@@ -0,0 +1,40 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentLines(t *testing.T) {
+	doc := newDocument("file:///a", 3, "a eq 1\r\n# note\n\nb pr")
+
+	require.Equal(t, []string{"a eq 1", "# note", "", "b pr"}, doc.lines)
+	require.True(t, isRule(doc.line(0)))
+	require.False(t, isRule(doc.line(1)))
+	require.False(t, isRule(doc.line(2)))
+	require.Empty(t, doc.line(10))
+}
+
+func TestCharacterOffsets(t *testing.T) {
+	line := `"😀é" eq x`
+
+	// The emoji is one rune but two UTF-16 code units.
+	require.Equal(t, 0, toCharacter(line, 0))
+	require.Equal(t, 3, toCharacter(line, 2))
+	require.Equal(t, 4, toCharacter(line, 3))
+	require.Equal(t, 10, toCharacter(line, 100))
+
+	require.Equal(t, 2, toOffset(line, 3))
+	require.Equal(t, 3, toOffset(line, 4))
+	require.Equal(t, 9, toOffset(line, 100))
+}
+
+func TestSpanRange(t *testing.T) {
+	require.Equal(t,
+		Range{Start: Position{Line: 2, Character: 4}, End: Position{Line: 2, Character: 5}},
+		spanRange(2, "x eq ", 4, 4))
+	require.Equal(t,
+		Range{Start: Position{Line: 0, Character: 5}, End: Position{Line: 0, Character: 5}},
+		spanRange(0, "x eq ", 7, 9))
+}
@@ -0,0 +1,279 @@
+package lsp
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/NSXBet/rule"
+)
+
+// diagnose parses every rule of the document with error recovery and reports all of its errors.
+func diagnose(doc *document) []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	for index, line := range doc.lines {
+		if !isRule(line) {
+			continue
+		}
+
+		_, syntaxErrors := rule.ParseRuleWithRecovery(line)
+
+		for _, syntaxErr := range syntaxErrors {
+			diagnostic := Diagnostic{
+				Range:    spanRange(index, line, syntaxErr.Start, syntaxErr.End),
+				Severity: severityError,
+				Source:   serverName,
+				Message:  syntaxErr.Err.Error(),
+			}
+
+			if hint := syntaxErr.Hint(); hint != "" {
+				diagnostic.Message += ", " + hint
+			}
+
+			var engineErr *rule.EngineError
+			if errors.As(syntaxErr.Err, &engineErr) {
+				diagnostic.Code = engineErr.Code
+			}
+
+			diagnostics = append(diagnostics, diagnostic)
+		}
+	}
+
+	return diagnostics
+}
+
+// Semantic token types advertised in the legend, indexed by their position.
+const (
+	semanticKeyword = iota
+	semanticOperator
+	semanticVariable
+	semanticProperty
+	semanticString
+	semanticNumber
+	semanticComment
+)
+
+//nolint:gochecknoglobals // Static semantic token legend
+var semanticTokenTypes = []string{"keyword", "operator", "variable", "property", "string", "number", "comment"}
+
+// semanticType classifies a lexer token for highlighting. Structural tokens are not reported.
+func semanticType(token rule.Token, previous rule.TokenType, line []rune) (int, bool) {
+	switch token.Type {
+	case rule.AND, rule.OR, rule.NOT, rule.BOOLEAN, rule.IF, rule.THEN, rule.ELSE, rule.CASE, rule.WHEN, rule.END,
+		rule.LET:
+		return semanticKeyword, true
+	case rule.EQ, rule.NE, rule.LT, rule.GT, rule.LE, rule.GE, rule.CO, rule.SW, rule.EW, rule.IN, rule.NOT_IN,
+		rule.PR, rule.DQ, rule.DN, rule.BE, rule.BQ, rule.AF, rule.AQ, rule.DL, rule.DG, rule.EQUALS, rule.NOT_EQUALS,
+		rule.PLUS, rule.MINUS, rule.MULTIPLY, rule.DIVIDE, rule.MODULO:
+		return semanticOperator, true
+	case rule.IDENTIFIER:
+		if previous == rule.DOT {
+			return semanticProperty, true
+		}
+
+		return semanticVariable, true
+	case rule.PARAMETER, rule.REFERENCE:
+		return semanticVariable, true
+	case rule.STRING:
+		// Integers too large for float64 are lexed as strings; highlight them by their spelling.
+		if token.Start < len(line) && line[token.Start] != '"' {
+			return semanticNumber, true
+		}
+
+		return semanticString, true
+	case rule.NUMBER, rule.DURATION:
+		return semanticNumber, true
+	case rule.EOF, rule.ARRAY_START, rule.ARRAY_END, rule.PAREN_OPEN, rule.PAREN_CLOSE, rule.DOT, rule.COMMA,
+		rule.ASSIGN:
+		return 0, false
+	}
+
+	return 0, false
+}
+
+// semanticTokens encodes the highlighting of a document in the LSP relative format.
+func semanticTokens(doc *document) []int {
+	data := []int{}
+	lastLine, lastCharacter := 0, 0
+
+	emit := func(lineIndex, character, length, tokenType int) {
+		if lineIndex != lastLine {
+			lastCharacter = 0
+		}
+
+		data = append(data, lineIndex-lastLine, character-lastCharacter, length, tokenType, 0)
+		lastLine, lastCharacter = lineIndex, character
+	}
+
+	for index, line := range doc.lines {
+		if isComment(line) {
+			start := strings.Index(line, "#")
+			character := toCharacter(line, len([]rune(line[:start])))
+			emit(index, character, toCharacter(line, len([]rune(line)))-character, semanticComment)
+
+			continue
+		}
+
+		runes := []rune(line)
+		previous := rule.EOF
+
+		for _, token := range rule.NewLexer(line).Tokenize() {
+			if tokenType, ok := semanticType(token, previous, runes); ok && token.End > token.Start {
+				character := toCharacter(line, token.Start)
+				emit(index, character, toCharacter(line, token.End)-character, tokenType)
+			}
+
+			previous = token.Type
+		}
+	}
+
+	return data
+}
+
+// tokenAt returns the lexer token covering a rune offset of a line.
+func tokenAt(line string, offset int) (rule.Token, bool) {
+	for _, token := range rule.NewLexer(line).Tokenize() {
+		if token.Type != rule.EOF && offset >= token.Start && offset < token.End {
+			return token, true
+		}
+	}
+
+	return rule.Token{}, false
+}
+
+// hover documents the operator or keyword under the cursor.
+func hover(doc *document, position Position) *Hover {
+	line := doc.line(position.Line)
+	if !isRule(line) {
+		return nil
+	}
+
+	token, ok := tokenAt(line, toOffset(line, position.Character))
+	if !ok {
+		return nil
+	}
+
+	docs, ok := operatorDocs[token.Type]
+	if !ok {
+		return nil
+	}
+
+	tokenRange := spanRange(position.Line, line, token.Start, token.End)
+
+	return &Hover{
+		Contents: MarkupContent{Kind: markupKindMarkdown, Value: docs},
+		Range:    &tokenRange,
+	}
+}
+
+// completion offers operators, keywords and known attribute paths matching the word before the cursor.
+func completion(doc *document, position Position, attributes []string) CompletionList {
+	line := doc.line(position.Line)
+	runes := []rune(line)
+	end := min(toOffset(line, position.Character), len(runes))
+
+	start := end
+	for start > 0 && isPathRune(runes[start-1]) {
+		start--
+	}
+
+	prefix := string(runes[start:end])
+	replace := Range{
+		Start: Position{Line: position.Line, Character: toCharacter(line, start)},
+		End:   Position{Line: position.Line, Character: toCharacter(line, end)},
+	}
+
+	items := []CompletionItem{}
+
+	add := func(label string, kind int, detail string, docs string) {
+		if !strings.HasPrefix(label, prefix) {
+			return
+		}
+
+		item := CompletionItem{
+			Label:    label,
+			Kind:     kind,
+			Detail:   detail,
+			TextEdit: &TextEdit{Range: replace, NewText: label},
+		}
+
+		if docs != "" {
+			item.Documentation = &MarkupContent{Kind: markupKindMarkdown, Value: docs}
+		}
+
+		items = append(items, item)
+	}
+
+	for _, keyword := range completionKeywords() {
+		kind, detail := completionKindOperator, "operator"
+		if isKeyword(keyword.token) {
+			kind, detail = completionKindKeyword, "keyword"
+		}
+
+		add(keyword.label, kind, detail, operatorDocs[keyword.token])
+	}
+
+	for _, attribute := range attributes {
+		add(attribute, completionKindField, "attribute", "")
+	}
+
+	return CompletionList{Items: items}
+}
+
+// isKeyword reports whether a reserved word is a keyword rather than an operator.
+func isKeyword(token rule.TokenType) bool {
+	switch token { //nolint:exhaustive // every other reserved word is an operator
+	case rule.AND, rule.OR, rule.NOT, rule.BOOLEAN, rule.IF, rule.THEN, rule.ELSE, rule.CASE, rule.WHEN, rule.END,
+		rule.LET:
+		return true
+	default:
+		return false
+	}
+}
+
+type keyword struct {
+	label string
+	token rule.TokenType
+}
+
+// completionKeywords lists the reserved words plus the compound "not in" operator.
+func completionKeywords() []keyword {
+	keywords := []keyword{{label: "not in", token: rule.NOT_IN}}
+
+	for _, word := range rule.Keywords() {
+		token := rule.NewLexer(word).Tokenize()[0]
+		keywords = append(keywords, keyword{label: word, token: token.Type})
+	}
+
+	sort.Slice(keywords, func(i, j int) bool { return keywords[i].label < keywords[j].label })
+
+	return keywords
+}
+
+func isPathRune(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// format rewrites every valid rule of the document canonically. Lines with errors are left alone.
+func format(doc *document) []TextEdit {
+	edits := []TextEdit{}
+
+	for index, line := range doc.lines {
+		if !isRule(line) {
+			continue
+		}
+
+		ast, err := rule.ParseRule(line)
+		if err != nil {
+			continue
+		}
+
+		if formatted := rule.Format(ast); formatted != line {
+			edits = append(edits, TextEdit{Range: lineRange(index, line), NewText: formatted})
+		}
+	}
+
+	return edits
+}
@@ -0,0 +1,82 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func frame(body string) string {
+	return "Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body
+}
+
+func TestConnRoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+
+	writer := newConn(nil, &buffer)
+	require.NoError(t, writer.notify("window/logMessage", map[string]any{"message": "héllo"}))
+
+	id := json.RawMessage(`"abc"`)
+	require.NoError(t, writer.reply(&id, []int{1, 2}, nil))
+
+	reader := newConn(&buffer, io.Discard)
+
+	msg, err := reader.read()
+	require.NoError(t, err)
+	require.Equal(t, jsonrpcVersion, msg.JSONRPC)
+	require.Equal(t, "window/logMessage", msg.Method)
+	require.False(t, msg.isRequest())
+	require.JSONEq(t, `{"message": "héllo"}`, string(msg.Params))
+
+	msg, err = reader.read()
+	require.NoError(t, err)
+	require.JSONEq(t, `"abc"`, string(*msg.ID))
+	require.JSONEq(t, `[1, 2]`, string(msg.Result))
+
+	_, err = reader.read()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestConnInvalidFrames(t *testing.T) {
+	_, err := newConn(strings.NewReader("Content-Length: abc\r\n\r\n{}"), io.Discard).read()
+	require.ErrorContains(t, err, "invalid Content-Length")
+
+	_, err = newConn(strings.NewReader("Content-Length: 10\r\n\r\n{}"), io.Discard).read()
+	require.ErrorContains(t, err, "reading body")
+
+	_, err = newConn(strings.NewReader(frame("{not json")), io.Discard).read()
+
+	var rpcErr *ResponseError
+	require.ErrorAs(t, err, &rpcErr)
+	require.Equal(t, codeParseError, rpcErr.Code)
+}
+
+func TestServeParseErrorResponse(t *testing.T) {
+	input := frame("{not json") + frame(`{"jsonrpc":"2.0","method":"exit"}`)
+
+	var output bytes.Buffer
+
+	err := NewServer().Serve(context.Background(), strings.NewReader(input), &output)
+	require.ErrorIs(t, err, ErrExitWithoutShutdown)
+
+	require.Contains(t, output.String(), `"id":null`, "JSON-RPC 2.0 requires a null id when it is unknown")
+
+	msg, err := newConn(&output, io.Discard).read()
+	require.NoError(t, err)
+	require.NotNil(t, msg.Error)
+	require.Equal(t, codeParseError, msg.Error.Code)
+}
+
+func TestServeCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewServer().Serve(ctx, strings.NewReader(""), io.Discard)
+	require.ErrorIs(t, err, context.Canceled)
+}
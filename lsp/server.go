@@ -0,0 +1,337 @@
+// Package lsp implements a Language Server Protocol server for the rule language over stdio.
+//
+// Documents hold one rule per line, like the files accepted by the rule command; blank lines and
+// lines starting with # are ignored. The server publishes parse diagnostics, semantic tokens,
+// operator hover documentation, completion of operators and attribute paths, and formatting.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/NSXBet/rule"
+)
+
+const serverName = "rule"
+
+// ErrExitWithoutShutdown is returned by Serve when the client sends exit before shutdown.
+//
+//nolint:gochecknoglobals // Sentinel error
+var ErrExitWithoutShutdown = errors.New("lsp: exit received before shutdown")
+
+// Server is a rule language server. Attributes lists the attribute paths offered by completion;
+// clients may extend it through the attributes or context initialization options.
+type Server struct {
+	Attributes []string
+
+	mu          sync.Mutex
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer() *Server {
+	return &Server{documents: map[string]*document{}}
+}
+
+// handler processes the params of a method and returns its result.
+type handler func(s *Server, c *conn, params json.RawMessage) (any, error)
+
+//nolint:gochecknoglobals // Static method dispatch table
+var handlers = map[string]handler{
+	"initialize":                       (*Server).initialize,
+	"shutdown":                         (*Server).handleShutdown,
+	"textDocument/didOpen":             (*Server).didOpen,
+	"textDocument/didChange":           (*Server).didChange,
+	"textDocument/didClose":            (*Server).didClose,
+	"textDocument/hover":               (*Server).hover,
+	"textDocument/completion":          (*Server).completion,
+	"textDocument/formatting":          (*Server).formatting,
+	"textDocument/semanticTokens/full": (*Server).semanticTokens,
+}
+
+// Serve reads requests from r and writes responses and notifications to w until the client sends
+// exit, the input ends or ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	c := newConn(r, w)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		msg, err := c.read()
+		if err != nil {
+			var rpcErr *ResponseError
+			if errors.As(err, &rpcErr) {
+				if writeErr := c.reply(nil, nil, rpcErr); writeErr != nil {
+					return writeErr
+				}
+
+				continue
+			}
+
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if s.isShutdown() {
+				return nil
+			}
+
+			return ErrExitWithoutShutdown
+		}
+
+		if err := s.dispatch(c, msg); err != nil {
+			return err
+		}
+	}
+}
+
+// dispatch runs the handler of a message and, for requests, writes the response.
+func (s *Server) dispatch(c *conn, msg *message) error {
+	if msg.Method == "" {
+		return nil // Responses to server-initiated requests are not used.
+	}
+
+	result, rpcErr := s.call(c, msg)
+
+	if !msg.isRequest() {
+		return nil
+	}
+
+	return c.reply(msg.ID, result, rpcErr)
+}
+
+func (s *Server) call(c *conn, msg *message) (any, *ResponseError) {
+	s.mu.Lock()
+	initialized, shutdown := s.initialized, s.shutdown
+	s.mu.Unlock()
+
+	switch {
+	case !initialized && msg.Method != "initialize":
+		return nil, &ResponseError{Code: codeServerNotInitialized, Message: "server not initialized"}
+	case shutdown:
+		return nil, &ResponseError{Code: codeInvalidRequest, Message: "server is shutting down"}
+	}
+
+	method, ok := handlers[msg.Method]
+	if !ok {
+		return nil, &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+
+	result, err := method(s, c, msg.Params)
+	if err != nil {
+		var rpcErr *ResponseError
+		if errors.As(err, &rpcErr) {
+			return nil, rpcErr
+		}
+
+		return nil, &ResponseError{Code: codeInternalError, Message: err.Error()}
+	}
+
+	return result, nil
+}
+
+func decodeParams(params json.RawMessage, target any) error {
+	if err := json.Unmarshal(params, target); err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+
+	return nil
+}
+
+func (s *Server) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.shutdown
+}
+
+func (s *Server) initialize(_ *conn, params json.RawMessage) (any, error) {
+	var p InitializeParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if options := p.InitializationOptions; options != nil {
+		s.Attributes = append(s.Attributes, options.Attributes...)
+		s.Attributes = append(s.Attributes, rule.ContextPaths(options.Context)...)
+	}
+
+	sort.Strings(s.Attributes)
+
+	s.initialized = true
+
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           textDocumentSyncFull,
+			HoverProvider:              true,
+			CompletionProvider:         CompletionOptions{TriggerCharacters: []string{"."}},
+			DocumentFormattingProvider: true,
+			SemanticTokensProvider: SemanticTokensOptions{
+				Legend: SemanticTokensLegend{TokenTypes: semanticTokenTypes, TokenModifiers: []string{}},
+				Full:   true,
+			},
+		},
+		ServerInfo: ServerInfo{Name: serverName},
+	}, nil
+}
+
+func (s *Server) handleShutdown(_ *conn, _ json.RawMessage) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shutdown = true
+
+	return nil, nil
+}
+
+func (s *Server) didOpen(c *conn, params json.RawMessage) (any, error) {
+	var p DidOpenTextDocumentParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc := newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text)
+	s.store(doc)
+
+	return nil, publishDiagnostics(c, doc)
+}
+
+func (s *Server) didChange(c *conn, params json.RawMessage) (any, error) {
+	var p DidChangeTextDocumentParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+
+	// Full synchronisation: the last change holds the whole document.
+	text := p.ContentChanges[len(p.ContentChanges)-1].Text
+	doc := newDocument(p.TextDocument.URI, p.TextDocument.Version, text)
+	s.store(doc)
+
+	return nil, publishDiagnostics(c, doc)
+}
+
+func (s *Server) didClose(c *conn, params json.RawMessage) (any, error) {
+	var p DidCloseTextDocumentParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	delete(s.documents, p.TextDocument.URI)
+	s.mu.Unlock()
+
+	// Clear the diagnostics of the closed document.
+	return nil, c.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+func (s *Server) hover(_ *conn, params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	if result := hover(doc, p.Position); result != nil {
+		return result, nil
+	}
+
+	return nil, nil
+}
+
+func (s *Server) completion(_ *conn, params json.RawMessage) (any, error) {
+	var p TextDocumentPositionParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	attributes := s.Attributes
+	s.mu.Unlock()
+
+	return completion(doc, p.Position, attributes), nil
+}
+
+func (s *Server) formatting(_ *conn, params json.RawMessage) (any, error) {
+	var p DocumentFormattingParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return format(doc), nil
+}
+
+func (s *Server) semanticTokens(_ *conn, params json.RawMessage) (any, error) {
+	var p SemanticTokensParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return SemanticTokens{Data: semanticTokens(doc)}, nil
+}
+
+func (s *Server) store(doc *document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.documents[doc.uri] = doc
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &ResponseError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+
+	return doc, nil
+}
+
+func publishDiagnostics(c *conn, doc *document) error {
+	return c.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     doc.version,
+		Diagnostics: diagnose(doc),
+	})
+}
@@ -0,0 +1,382 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testClient is an in-process JSON-RPC client talking to a Server through pipes.
+type testClient struct {
+	t             *testing.T
+	conn          *conn
+	messages      chan *message
+	notifications []*message
+	nextID        int
+	served        chan error
+}
+
+func newTestClient(t *testing.T, server *Server) *testClient {
+	t.Helper()
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	client := &testClient{
+		t:        t,
+		conn:     newConn(clientReader, clientWriter),
+		messages: make(chan *message, 16),
+		served:   make(chan error, 1),
+	}
+
+	go func() {
+		client.served <- server.Serve(context.Background(), serverReader, serverWriter)
+		serverWriter.Close()
+	}()
+
+	go func() {
+		defer close(client.messages)
+
+		for {
+			msg, err := client.conn.read()
+			if err != nil {
+				return
+			}
+
+			client.messages <- msg
+		}
+	}()
+
+	t.Cleanup(func() {
+		clientWriter.Close()
+		clientReader.Close()
+	})
+
+	return client
+}
+
+// call sends a request and waits for its response, collecting notifications sent in between.
+func (c *testClient) call(method string, params, result any) *ResponseError {
+	c.t.Helper()
+
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+
+	encoded, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.conn.write(&message{ID: &id, Method: method, Params: encoded}))
+
+	for msg := range c.messages {
+		if msg.Method != "" {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+
+		require.JSONEq(c.t, string(id), string(*msg.ID))
+
+		if msg.Error != nil {
+			return msg.Error
+		}
+
+		if result != nil {
+			require.NoError(c.t, json.Unmarshal(msg.Result, result))
+		}
+
+		return nil
+	}
+
+	c.t.Fatalf("connection closed waiting for %s", method)
+
+	return nil
+}
+
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+	require.NoError(c.t, c.conn.notify(method, params))
+}
+
+// nextNotification returns the next notification, reading from the server if none is buffered.
+func (c *testClient) nextNotification() *message {
+	c.t.Helper()
+
+	if len(c.notifications) > 0 {
+		msg := c.notifications[0]
+		c.notifications = c.notifications[1:]
+
+		return msg
+	}
+
+	msg, ok := <-c.messages
+	require.True(c.t, ok, "connection closed waiting for notification")
+	require.NotEmpty(c.t, msg.Method)
+
+	return msg
+}
+
+func (c *testClient) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+
+	msg := c.nextNotification()
+	require.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+
+	var params PublishDiagnosticsParams
+	require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+
+	return params
+}
+
+func (c *testClient) initialize(options *InitializationOptions) InitializeResult {
+	c.t.Helper()
+
+	var result InitializeResult
+	require.Nil(c.t, c.call("initialize", InitializeParams{InitializationOptions: options}, &result))
+	c.notify("initialized", struct{}{})
+
+	return result
+}
+
+func (c *testClient) open(uri, text string) PublishDiagnosticsParams {
+	c.t.Helper()
+
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "rule", Version: 1, Text: text},
+	})
+
+	return c.diagnostics()
+}
+
+const testURI = "file:///rules.rule"
+
+func TestServer(t *testing.T) {
+	t.Run("Lifecycle", testLifecycle)
+	t.Run("ExitWithoutShutdown", testExitWithoutShutdown)
+	t.Run("Diagnostics", testDiagnostics)
+	t.Run("DidChangeAndClose", testDidChangeAndClose)
+	t.Run("SemanticTokens", testSemanticTokens)
+	t.Run("Hover", testHover)
+	t.Run("Completion", testCompletion)
+	t.Run("Formatting", testFormatting)
+}
+
+func testLifecycle(t *testing.T) {
+	client := newTestClient(t, NewServer())
+
+	rpcErr := client.call("textDocument/hover", TextDocumentPositionParams{}, nil)
+	require.Equal(t, codeServerNotInitialized, rpcErr.Code)
+
+	result := client.initialize(nil)
+	require.Equal(t, textDocumentSyncFull, result.Capabilities.TextDocumentSync)
+	require.True(t, result.Capabilities.HoverProvider)
+	require.True(t, result.Capabilities.DocumentFormattingProvider)
+	require.Equal(t, semanticTokenTypes, result.Capabilities.SemanticTokensProvider.Legend.TokenTypes)
+
+	rpcErr = client.call("workspace/symbol", struct{}{}, nil)
+	require.Equal(t, codeMethodNotFound, rpcErr.Code)
+
+	rpcErr = client.call("textDocument/formatting", DocumentFormattingParams{
+		TextDocument: TextDocumentIdentifier{URI: "file:///missing"},
+	}, nil)
+	require.Equal(t, codeInvalidParams, rpcErr.Code)
+
+	require.Nil(t, client.call("shutdown", nil, nil))
+
+	rpcErr = client.call("textDocument/formatting", DocumentFormattingParams{}, nil)
+	require.Equal(t, codeInvalidRequest, rpcErr.Code)
+
+	client.notify("exit", nil)
+	require.NoError(t, <-client.served)
+}
+
+func testExitWithoutShutdown(t *testing.T) {
+	client := newTestClient(t, NewServer())
+	client.initialize(nil)
+	client.notify("exit", nil)
+	require.ErrorIs(t, <-client.served, ErrExitWithoutShutdown)
+}
+
+func testDiagnostics(t *testing.T) {
+	client := newTestClient(t, NewServer())
+	client.initialize(nil)
+
+	params := client.open(testURI,
+		"x eq 1\n  tier in \"gold\"\n# comment\n\"😀\" eq a b\nname eq \"open\na eq and b gt")
+	require.Equal(t, testURI, params.URI)
+	require.Equal(t, 1, params.Version)
+	require.Equal(t, []Diagnostic{
+		{
+			Range:    Range{Start: Position{Line: 1, Character: 2}, End: Position{Line: 1, Character: 16}},
+			Severity: severityError,
+			Code:     "INVALID_IN_OPERAND",
+			Source:   serverName,
+			Message:  "IN operator requires an array operand",
+		},
+		{
+			// The emoji takes two UTF-16 code units, shifting the character offsets by one.
+			Range:    Range{Start: Position{Line: 3, Character: 10}, End: Position{Line: 3, Character: 11}},
+			Severity: severityError,
+			Code:     "TRAILING_TOKENS",
+			Source:   serverName,
+			Message:  "Unexpected tokens after complete expression",
+		},
+		{
+			Range:    Range{Start: Position{Line: 4, Character: 8}, End: Position{Line: 4, Character: 13}},
+			Severity: severityError,
+			Code:     "UNTERMINATED_STRING",
+			Source:   serverName,
+			Message:  "Unterminated string literal",
+		},
+		{
+			Range:    Range{Start: Position{Line: 5, Character: 5}, End: Position{Line: 5, Character: 8}},
+			Severity: severityError,
+			Source:   serverName,
+			Message:  "unexpected token and",
+		},
+		{
+			Range:    Range{Start: Position{Line: 5, Character: 13}, End: Position{Line: 5, Character: 13}},
+			Severity: severityError,
+			Source:   serverName,
+			Message:  "unexpected token EOF",
+		},
+	}, params.Diagnostics)
+}
+
+func testDidChangeAndClose(t *testing.T) {
+	client := newTestClient(t, NewServer())
+	client.initialize(nil)
+
+	require.Len(t, client.open(testURI, "x eq").Diagnostics, 1)
+
+	client.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: testURI, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "x eq 1"}},
+	})
+
+	params := client.diagnostics()
+	require.Equal(t, 2, params.Version)
+	require.Empty(t, params.Diagnostics)
+
+	client.notify("textDocument/didClose", DidCloseTextDocumentParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+	})
+	require.Empty(t, client.diagnostics().Diagnostics)
+
+	rpcErr := client.call("textDocument/semanticTokens/full", SemanticTokensParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+	}, nil)
+	require.Equal(t, codeInvalidParams, rpcErr.Code)
+}
+
+func testSemanticTokens(t *testing.T) {
+	client := newTestClient(t, NewServer())
+	client.initialize(nil)
+	client.open(testURI, "# rules\nuser.age ge 9007199254740993 and not tags in [\"a\", true]")
+
+	var tokens SemanticTokens
+	require.Nil(t, client.call("textDocument/semanticTokens/full", SemanticTokensParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+	}, &tokens))
+
+	require.Equal(t, []int{
+		0, 0, 7, semanticComment, 0,
+		1, 0, 4, semanticVariable, 0, // user
+		0, 5, 3, semanticProperty, 0, // age
+		0, 4, 2, semanticOperator, 0, // ge
+		0, 3, 16, semanticNumber, 0, // 9007199254740993
+		0, 17, 3, semanticKeyword, 0, // and
+		0, 4, 3, semanticKeyword, 0, // not
+		0, 4, 4, semanticVariable, 0, // tags
+		0, 5, 2, semanticOperator, 0, // in
+		0, 4, 3, semanticString, 0, // "a"
+		0, 5, 4, semanticKeyword, 0, // true
+	}, tokens.Data)
+}
+
+func testHover(t *testing.T) {
+	client := newTestClient(t, NewServer())
+	client.initialize(nil)
+	client.open(testURI, "last_login dl 7 and created aq \"2024-01-01T00:00:00Z\"")
+
+	var result Hover
+	require.Nil(t, client.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: 0, Character: 12},
+	}, &result))
+	require.Equal(t, markupKindMarkdown, result.Contents.Kind)
+	require.Contains(t, result.Contents.Value, "**dl** — days less than")
+	require.Equal(t, &Range{Start: Position{Character: 11}, End: Position{Character: 13}}, result.Range)
+
+	require.Nil(t, client.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: 0, Character: 29},
+	}, &result))
+	require.Contains(t, result.Contents.Value, "**aq**")
+
+	var empty *Hover
+	require.Nil(t, client.call("textDocument/hover", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: 0, Character: 2},
+	}, &empty))
+	require.Nil(t, empty)
+}
+
+func testCompletion(t *testing.T) {
+	client := newTestClient(t, NewServer())
+	client.initialize(&InitializationOptions{
+		Attributes: []string{"account.id"},
+		Context:    map[string]any{"user": map[string]any{"age": 1, "address": map[string]any{"city": "x"}}},
+	})
+	client.open(testURI, "user.a\nx eq 1 a")
+
+	labels := func(position Position) []string {
+		var list CompletionList
+		require.Nil(t, client.call("textDocument/completion", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: testURI},
+			Position:     position,
+		}, &list))
+
+		result := make([]string, len(list.Items))
+		for i, item := range list.Items {
+			result[i] = item.Label
+			require.NotNil(t, item.TextEdit)
+		}
+
+		return result
+	}
+
+	require.Equal(t, []string{"user.address", "user.address.city", "user.age"}, labels(Position{Line: 0, Character: 6}))
+	require.Equal(t, []string{"af", "and", "aq", "account.id"}, labels(Position{Line: 1, Character: 8}))
+
+	var list CompletionList
+	require.Nil(t, client.call("textDocument/completion", TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: 0, Character: 6},
+	}, &list))
+	require.Equal(t,
+		Range{Start: Position{Line: 0}, End: Position{Line: 0, Character: 6}},
+		list.Items[0].TextEdit.Range)
+	require.Equal(t, completionKindField, list.Items[0].Kind)
+
+	all := labels(Position{Line: 1, Character: 7})
+	require.Contains(t, all, "not in")
+	require.Contains(t, all, "dl")
+}
+
+func testFormatting(t *testing.T) {
+	client := newTestClient(t, NewServer())
+	client.initialize(nil)
+	client.open(testURI, "# keep\nx   ==  1\n\na eq 1\n(a or b) and (c)\nbroken eq")
+
+	var edits []TextEdit
+	require.Nil(t, client.call("textDocument/formatting", DocumentFormattingParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+	}, &edits))
+
+	require.Equal(t, []TextEdit{
+		{Range: Range{Start: Position{Line: 1}, End: Position{Line: 1, Character: 9}}, NewText: "x eq 1"},
+		{Range: Range{Start: Position{Line: 4}, End: Position{Line: 4, Character: 16}}, NewText: "(a or b) and c"},
+	}, edits)
+}
@@ -0,0 +1,367 @@
+package rule
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// MongoTranslator converts a parsed rule into a MongoDB filter document.
+//
+// The result is a D, which can be passed directly to the MongoDB driver as a filter. String
+// equality and pattern operators become case-insensitive regular expressions to match the
+// engine's semantics, and equality between two fields compares their lower-cased strings.
+// Negative operators require the field to exist because comparisons against missing attributes
+// are always false in the engine.
+type MongoTranslator struct {
+	// Fields maps attribute paths such as "user.age" to document fields. Unmapped attributes
+	// use their dotted path, which MongoDB resolves into embedded documents.
+	Fields map[string]string
+	// Now returns the reference time used by dl/dg. Defaults to time.Now.
+	Now func() time.Time
+}
+
+func NewMongoTranslator() *MongoTranslator {
+	return &MongoTranslator{Fields: make(map[string]string)}
+}
+
+// Translate returns the MongoDB filter document for the rule.
+func (t *MongoTranslator) Translate(node *ASTNode) (D, error) {
+	if node == nil {
+		return nil, ErrInvalidNode
+	}
+
+	return t.translateNode(node)
+}
+
+// TranslateRule parses the rule and translates it into a MongoDB filter document.
+func (t *MongoTranslator) TranslateRule(rule string) (D, error) {
+	ast, err := ParseRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Translate(ast)
+}
+
+func (t *MongoTranslator) translateNode(node *ASTNode) (D, error) {
+	switch node.Type {
+	case NodeBinaryOp:
+		if node.Operator == AND || node.Operator == OR {
+			return t.translateLogical(node)
+		}
+
+		return t.translateComparison(node)
+	case NodeUnaryOp:
+		return t.translateUnary(node)
+	case NodeLiteral:
+		if node.Value.Type != ValueBoolean {
+			return nil, unsupportedTranslation("non-boolean literal used as a condition")
+		}
+
+		return D{"$expr": node.Value.BoolValue}, nil
+	case NodeIdentifier, NodeProperty:
+		path, _ := attributePath(node)
+		return D{t.field(path): true}, nil
+	case NodeArray:
+		return nil, unsupportedTranslation("array used as a condition")
+	case NodeConditional:
+		return nil, unsupportedTranslation("conditional expression")
+	case NodeParameter:
+		return nil, unsupportedTranslation("parameter")
+	case NodeReference:
+		return nil, unsupportedTranslation("reference to a definition")
+	case NodeLet, NodeVariable:
+		return nil, unsupportedTranslation("let expression")
+	case NodeCall:
+		return nil, unsupportedTranslation("function " + node.Value.StrValue)
+	case NodeError:
+		return nil, ErrInvalidSyntax
+	default:
+		return nil, ErrInvalidNode
+	}
+}
+
+func (t *MongoTranslator) translateLogical(node *ASTNode) (D, error) {
+	key := "$and"
+	if node.Operator == OR {
+		key = "$or"
+	}
+
+	operands := flattenLogical(node, node.Operator, nil)
+	clauses := make([]any, 0, len(operands))
+
+	for _, operand := range operands {
+		clause, err := t.translateNode(operand)
+		if err != nil {
+			return nil, err
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	return D{key: clauses}, nil
+}
+
+func (t *MongoTranslator) translateUnary(node *ASTNode) (D, error) {
+	switch node.Operator { //nolint:exhaustive // only NOT and PR are unary operators
+	case NOT:
+		operand, err := t.translateNode(node.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		return D{"$nor": []any{operand}}, nil
+	case PR:
+		path, ok := attributePath(node.Left)
+		if !ok {
+			return nil, unsupportedTranslation("presence check on an expression")
+		}
+
+		return D{t.field(path): D{"$exists": true}}, nil
+	default:
+		return nil, unsupportedTranslation("unary operator " + node.Operator.String())
+	}
+}
+
+func (t *MongoTranslator) translateComparison(node *ASTNode) (D, error) {
+	cmp, err := newComparison(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmp.constant() {
+		value, foldErr := foldConstant(node)
+		if foldErr != nil {
+			return nil, foldErr
+		}
+
+		return D{"$expr": value}, nil
+	}
+
+	switch cmp.op { //nolint:exhaustive // logical operators are handled by translateLogical
+	case EQ, EQUALS, NE, NOT_EQUALS, LT, GT, LE, GE, DQ, DN, BE, BQ, AF, AQ:
+		return t.translateRelational(&cmp)
+	case CO, SW, EW:
+		return t.translatePattern(&cmp)
+	case IN, NOT_IN:
+		return t.translateMembership(&cmp)
+	case DL, DG:
+		return t.translateDays(&cmp)
+	default:
+		return nil, unsupportedTranslation("binary operator " + cmp.op.String())
+	}
+}
+
+func (t *MongoTranslator) translateRelational(cmp *comparison) (D, error) {
+	if cmp.field == nil {
+		return nil, unsupportedTranslation(cmp.op.String() + " between a literal and an expression")
+	}
+
+	field := t.field(cmp.field)
+
+	if cmp.other != nil {
+		return t.translateFieldComparison(cmp.op, field, t.field(cmp.other)), nil
+	}
+
+	value, err := t.literalOperand(cmp.op, cmp.right)
+	if err != nil {
+		return nil, err
+	}
+
+	negated := cmp.op == NE || cmp.op == NOT_EQUALS || cmp.op == DN
+
+	if cmp.right.Type == ValueString && !isDateTimeOperator(cmp.op) && (negated || cmp.op == EQ || cmp.op == EQUALS) {
+		pattern := mongoRegex("^" + regexp.QuoteMeta(cmp.right.StrValue) + "$")
+		if negated {
+			return D{field: D{"$exists": true, "$not": pattern}}, nil
+		}
+
+		return D{field: pattern}, nil
+	}
+
+	condition := D{mongoOperator(cmp.op): value}
+	if negated {
+		condition["$exists"] = true
+	}
+
+	return D{field: condition}, nil
+}
+
+// translateFieldComparison compares two document fields, requiring both to exist like the engine does.
+// Equality folds string fields to lower case, since the engine compares strings case-insensitively.
+func (t *MongoTranslator) translateFieldComparison(op TokenType, field, other string) D {
+	left, right := any("$"+field), any("$"+other)
+	if op == EQ || op == EQUALS || op == NE || op == NOT_EQUALS {
+		left, right = mongoFoldCase(field), mongoFoldCase(other)
+	}
+
+	return D{
+		field: D{"$exists": true},
+		other: D{"$exists": true},
+		"$expr": D{
+			mongoOperator(op): []any{left, right},
+		},
+	}
+}
+
+// mongoFoldCase returns an aggregation expression for the field, lower-cased when it holds a string.
+func mongoFoldCase(field string) D {
+	return D{"$cond": []any{
+		D{"$eq": []any{D{"$type": "$" + field}, "string"}},
+		D{"$toLower": "$" + field},
+		"$" + field,
+	}}
+}
+
+func (t *MongoTranslator) translatePattern(cmp *comparison) (D, error) {
+	if cmp.field == nil || cmp.right == nil || cmp.right.Type != ValueString {
+		return nil, unsupportedTranslation(
+			cmp.op.String() + " requires an attribute on the left and a string literal on the right",
+		)
+	}
+
+	pattern := regexp.QuoteMeta(cmp.right.StrValue)
+
+	switch cmp.op { //nolint:exhaustive // only called for pattern operators
+	case SW:
+		pattern = "^" + pattern
+	case EW:
+		pattern += "$"
+	default:
+	}
+
+	return D{t.field(cmp.field): mongoRegex(pattern)}, nil
+}
+
+func (t *MongoTranslator) translateMembership(cmp *comparison) (D, error) {
+	// A literal needle searched in an array attribute
+	if cmp.field == nil && cmp.other != nil {
+		match := D{"$eq": literalToAny(cmp.left)}
+		if cmp.left.Type == ValueString {
+			match = mongoRegex("^" + regexp.QuoteMeta(cmp.left.StrValue) + "$")
+		}
+
+		filter := D{t.field(cmp.other): D{"$elemMatch": match}}
+		if cmp.op == NOT_IN {
+			return D{t.field(cmp.other): D{"$type": "array"}, "$nor": []any{filter}}, nil
+		}
+
+		return filter, nil
+	}
+
+	if cmp.field == nil || cmp.right == nil || cmp.right.Type != ValueArray {
+		return nil, unsupportedTranslation(cmp.op.String() + " requires an array literal or an array attribute")
+	}
+
+	field := t.field(cmp.field)
+	filter := mongoMembership(field, cmp.right.ArrValue)
+
+	if cmp.op == NOT_IN {
+		return D{field: D{"$exists": true}, "$nor": []any{filter}}, nil
+	}
+
+	return filter, nil
+}
+
+func (t *MongoTranslator) translateDays(cmp *comparison) (D, error) {
+	if cmp.field == nil || cmp.right == nil {
+		return nil, unsupportedTranslation(
+			cmp.op.String() + " requires an attribute on the left and a numeric literal on the right",
+		)
+	}
+
+	days, ok := literalDays(cmp.right)
+	if !ok {
+		return nil, unsupportedTranslation(cmp.op.String() + " with a non-numeric threshold")
+	}
+
+	threshold := t.now().Add(-daysToDuration(days))
+	if cmp.op == DG {
+		return D{t.field(cmp.field): D{"$lt": threshold}}, nil
+	}
+
+	return D{t.field(cmp.field): D{"$gt": threshold}}, nil
+}
+
+// literalOperand converts the right-hand literal, parsing it as a datetime for datetime operators.
+func (t *MongoTranslator) literalOperand(op TokenType, literal *Value) (any, error) {
+	if !isDateTimeOperator(op) {
+		return literalToAny(literal), nil
+	}
+
+	timestamp, ok := literalDateTime(literal)
+	if !ok {
+		return nil, unsupportedTranslation(op.String() + " with a literal that is not a datetime")
+	}
+
+	return timestamp, nil
+}
+
+func (t *MongoTranslator) field(path []string) string {
+	key := strings.Join(path, ".")
+	if field, mapped := t.Fields[key]; mapped {
+		return field
+	}
+
+	return key
+}
+
+func (t *MongoTranslator) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+
+	return time.Now()
+}
+
+// mongoMembership matches string elements case-insensitively with one anchored alternation and
+// every other element with $in, since membership in the engine is type-strict.
+func mongoMembership(field string, elements []Value) D {
+	var (
+		alternatives []string
+		others       = make([]any, 0, len(elements))
+	)
+
+	for i := range elements {
+		if elements[i].Type == ValueString {
+			alternatives = append(alternatives, regexp.QuoteMeta(elements[i].StrValue))
+		} else {
+			others = append(others, literalToAny(&elements[i]))
+		}
+	}
+
+	if len(alternatives) == 0 {
+		return D{field: D{"$in": others}}
+	}
+
+	pattern := mongoRegex("^(?:" + strings.Join(alternatives, "|") + ")$")
+	if len(others) == 0 {
+		return D{field: pattern}
+	}
+
+	return D{"$or": []any{D{field: pattern}, D{field: D{"$in": others}}}}
+}
+
+func mongoRegex(pattern string) D {
+	return D{"$regex": pattern, "$options": "i"}
+}
+
+func mongoOperator(op TokenType) string {
+	switch op { //nolint:exhaustive // only called for relational and datetime operators
+	case EQ, EQUALS, DQ:
+		return "$eq"
+	case NE, NOT_EQUALS, DN:
+		return "$ne"
+	case LT, BE:
+		return "$lt"
+	case GT, AF:
+		return "$gt"
+	case LE, BQ:
+		return "$lte"
+	case GE, AQ:
+		return "$gte"
+	default:
+		return ""
+	}
+}
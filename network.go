@@ -0,0 +1,192 @@
+package rule
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Network evaluates many rules against one context while sharing work between them. It merges
+// identical predicates and identical and/or/not sub-expressions of all rules into a single graph
+// and evaluates every node at most once per context, so a predicate such as country eq "BR" costs
+// the same whether one rule or thousands use it. Short-circuiting still applies: a node is only
+// evaluated when some rule reaches it. A Network is immutable and safe for concurrent use.
+type Network struct {
+	nodes []networkNode
+	// roots holds the node of each rule, in the order the rules were given.
+	roots []int
+	// predicates is the number of distinct predicates among the nodes.
+	predicates int
+	evaluator  *Evaluator
+	// states recycles the per-evaluation node states.
+	states sync.Pool
+}
+
+// networkNode is a predicate, or an and/or/not over other nodes. and and or chains are flattened,
+// so their operands share a single node.
+type networkNode struct {
+	operator  TokenType
+	predicate *ASTNode
+	operands  []int
+}
+
+// Per-evaluation state of a node.
+const (
+	nodeUnknown uint8 = iota
+	nodeFalse
+	nodeTrue
+)
+
+// NewNetwork builds a network over the rules. Rules are identified by their position.
+func NewNetwork(rules ...*CompiledRule) *Network {
+	builder := networkBuilder{network: &Network{evaluator: NewEvaluator()}, ids: make(map[string]int)}
+
+	for _, compiled := range rules {
+		builder.network.roots = append(builder.network.roots, builder.add(compiled.AST))
+	}
+
+	network := builder.network
+	size := len(network.nodes)
+	network.states.New = func() any {
+		states := make([]uint8, size)
+		return &states
+	}
+
+	return network
+}
+
+// Len returns the number of rules in the network.
+func (n *Network) Len() int {
+	return len(n.roots)
+}
+
+// Predicates returns the number of distinct predicates in the network.
+func (n *Network) Predicates() int {
+	return n.predicates
+}
+
+// Evaluate returns the positions of the rules that match the context, in ascending order.
+func (n *Network) Evaluate(context D) ([]int, error) {
+	states, _ := n.states.Get().(*[]uint8)
+	defer func() {
+		clear(*states)
+		n.states.Put(states)
+	}()
+
+	var matches []int
+
+	for i, root := range n.roots {
+		matched, err := n.evaluate(root, context, *states)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			matches = append(matches, i)
+		}
+	}
+
+	return matches, nil
+}
+
+func (n *Network) evaluate(id int, context D, states []uint8) (bool, error) {
+	switch states[id] {
+	case nodeTrue:
+		return true, nil
+	case nodeFalse:
+		return false, nil
+	}
+
+	node := &n.nodes[id]
+
+	var (
+		result bool
+		err    error
+	)
+
+	switch node.operator { //nolint:exhaustive // nodes are predicates or logical operators
+	case AND:
+		result = true
+
+		for _, operand := range node.operands {
+			if result, err = n.evaluate(operand, context, states); err != nil || !result {
+				break
+			}
+		}
+
+	case OR:
+		for _, operand := range node.operands {
+			if result, err = n.evaluate(operand, context, states); err != nil || result {
+				break
+			}
+		}
+
+	case NOT:
+		result, err = n.evaluate(node.operands[0], context, states)
+		result = !result
+
+	default:
+		result, err = n.evaluator.Evaluate(node.predicate, context)
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	states[id] = nodeFalse
+	if result {
+		states[id] = nodeTrue
+	}
+
+	return result, nil
+}
+
+// networkBuilder assigns every distinct node an id. Predicates are keyed by their canonical form
+// and logical nodes by their operator and operand ids, so identical sub-expressions written
+// differently (== or eq, redundant parentheses) still share a node.
+type networkBuilder struct {
+	network *Network
+	ids     map[string]int
+}
+
+func (b *networkBuilder) add(node *ASTNode) int {
+	if !isConnective(node) {
+		return b.node("p:"+Format(node), networkNode{predicate: node})
+	}
+
+	var operands []*ASTNode
+	if node.Operator == NOT {
+		operands = []*ASTNode{node.Left}
+	} else {
+		operands = flattenLogical(node, node.Operator, nil)
+	}
+
+	ids := make([]int, len(operands))
+	key := strings.Builder{}
+	key.WriteString(node.Operator.String())
+
+	for i, operand := range operands {
+		ids[i] = b.add(operand)
+
+		key.WriteByte(':')
+		key.WriteString(strconv.Itoa(ids[i]))
+	}
+
+	return b.node(key.String(), networkNode{operator: node.Operator, operands: ids})
+}
+
+func (b *networkBuilder) node(key string, node networkNode) int {
+	if id, exists := b.ids[key]; exists {
+		return id
+	}
+
+	if node.predicate != nil {
+		b.network.predicates++
+	}
+
+	id := len(b.network.nodes)
+	b.network.nodes = append(b.network.nodes, node)
+	b.ids[key] = id
+
+	return id
+}
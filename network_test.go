@@ -0,0 +1,215 @@
+package rule
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// networkRules generates count rules that, like real rule sets, reuse a small pool of predicates.
+func networkRules(count int) []string {
+	countries := []string{"BR", "US", "PT", "AR", "MX"}
+	rules := make([]string, count)
+
+	for i := range rules {
+		country := countries[i%len(countries)]
+
+		switch i % 4 {
+		case 0:
+			rules[i] = fmt.Sprintf(`country eq %q and tier in ["gold", "vip"] and age ge %d`, country, 18+i%40)
+		case 1:
+			rules[i] = fmt.Sprintf(`(country == %q or vip) and not (score lt %d)`, country, i%100)
+		case 2:
+			rules[i] = fmt.Sprintf(`tier in ["gold", "vip"] and (age ge %d or country eq %q)`, 18+i%40, country)
+		default:
+			rules[i] = fmt.Sprintf(`not (country eq %q) and email ew ".com" and score ge %d`, country, i%100)
+		}
+	}
+
+	return rules
+}
+
+func networkContexts() []D {
+	return []D{
+		{"country": "BR", "tier": "gold", "age": 30, "score": 50, "email": "a@b.com"},
+		{"country": "US", "tier": "silver", "age": 17, "score": 99, "vip": true, "email": "a@b.org"},
+		{"country": "PT", "tier": "vip", "age": 45, "score": 5},
+		{"vip": false},
+		{},
+	}
+}
+
+func compileNetworkRules(tb testing.TB, engine *Engine, rules []string) []*CompiledRule {
+	tb.Helper()
+
+	compiled := make([]*CompiledRule, len(rules))
+
+	for i, rule := range rules {
+		var err error
+
+		compiled[i], err = engine.CompileRule(rule)
+		require.NoError(tb, err)
+	}
+
+	return compiled
+}
+
+func TestNetwork(t *testing.T) {
+	t.Run("MatchesEvaluate", testNetworkMatchesEvaluate)
+	t.Run("SharedPredicates", testNetworkSharedPredicates)
+	t.Run("Concurrent", testNetworkConcurrent)
+	t.Run("Error", testNetworkError)
+}
+
+func testNetworkMatchesEvaluate(t *testing.T) {
+	engine := NewEngine()
+	rules := networkRules(400)
+	network := NewNetwork(compileNetworkRules(t, engine, rules)...)
+
+	require.Equal(t, len(rules), network.Len())
+
+	for _, context := range networkContexts() {
+		var expected []int
+
+		for i, rule := range rules {
+			matched, err := engine.Evaluate(rule, context)
+			require.NoError(t, err)
+
+			if matched {
+				expected = append(expected, i)
+			}
+		}
+
+		// Evaluate twice to make sure no state leaks between evaluations.
+		for range 2 {
+			matches, err := network.Evaluate(context)
+			require.NoError(t, err)
+			require.Equal(t, expected, matches, "context=%v", context)
+		}
+	}
+}
+
+func testNetworkSharedPredicates(t *testing.T) {
+	engine := NewEngine()
+	network := NewNetwork(compileNetworkRules(t, engine, []string{
+		`country eq "BR" and tier in ["gold", "vip"]`,
+		`(country == "BR") and tier in ["gold","vip"]`,
+		`tier in ["gold", "vip"] or not (country eq "BR")`,
+		`country eq "BR" and tier in ["gold", "vip"] and age ge 18`,
+	})...)
+
+	require.Equal(t, 3, network.Predicates())
+	require.Len(t, network.nodes, 7, "the first two rules share their whole graph")
+	require.Equal(t, network.roots[0], network.roots[1])
+
+	matches, err := network.Evaluate(D{"country": "BR", "tier": "vip", "age": 20})
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2, 3}, matches)
+
+	matches, err = network.Evaluate(D{"country": "US"})
+	require.NoError(t, err)
+	require.Equal(t, []int{2}, matches)
+
+	empty := NewNetwork()
+	matches, err = empty.Evaluate(D{})
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
+
+func testNetworkConcurrent(t *testing.T) {
+	engine := NewEngine()
+	network := NewNetwork(compileNetworkRules(t, engine, networkRules(200))...)
+	contexts := networkContexts()
+
+	expected := make([][]int, len(contexts))
+
+	for i, context := range contexts {
+		var err error
+
+		expected[i], err = network.Evaluate(context)
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+
+	for worker := range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range 200 {
+				index := (worker + i) % len(contexts)
+
+				matches, err := network.Evaluate(contexts[index])
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if fmt.Sprint(matches) != fmt.Sprint(expected[index]) {
+					t.Errorf("context %d: got %v, want %v", index, matches, expected[index])
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func testNetworkError(t *testing.T) {
+	network := NewNetwork(&CompiledRule{AST: NewBinaryOpNode(AND, NewIdentifierNode("a"), NewErrorNode(0, 1))})
+
+	matches, err := network.Evaluate(D{"a": false})
+	require.NoError(t, err)
+	require.Empty(t, matches, "short-circuited nodes are never evaluated")
+
+	_, err = network.Evaluate(D{"a": true})
+	require.ErrorIs(t, err, ErrInvalidSyntax)
+}
+
+func BenchmarkNetwork(b *testing.B) {
+	context := networkContexts()[0]
+
+	for _, count := range []int{1000, 10000} {
+		engine := NewEngine()
+		rules := networkRules(count)
+		compiled := compileNetworkRules(b, engine, rules)
+
+		b.Run(fmt.Sprintf("Network/%d", count), func(b *testing.B) {
+			network := NewNetwork(compiled...)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for range b.N {
+				if _, err := network.Evaluate(context); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("EvaluateLoop/%d", count), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for range b.N {
+				var matches []int
+
+				for i, rule := range rules {
+					matched, err := engine.Evaluate(rule, context)
+					if err != nil {
+						b.Fatal(err)
+					}
+
+					if matched {
+						matches = append(matches, i)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package rule
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// Estimated relative cost of evaluating each kind of predicate, used to order and/or operands.
+const (
+	costAttribute  = 1.0
+	costComparison = 1.0
+	costPresence   = 0.5
+	costString     = 3.0
+	costElement    = 0.25
+	costDateTime   = 20.0
+)
+
+// Bounds on the probabilities used to rank operands, so no operand gets an infinite rank.
+const (
+	minProbability = 0.01
+	maxProbability = 0.99
+	// defaultProbability is assumed for predicates without enough observations.
+	defaultProbability = 0.5
+	// minObservations is how many observations a predicate needs before its rate is trusted.
+	minObservations = 20
+)
+
+// Optimizer reorders the operands of and/or chains so short-circuiting skips as much work as
+// possible: cheap predicates such as literal comparisons run before string matching, which runs
+// before datetime parsing. With Selectivity set, operands that are likely to decide the result
+// (false for and, true for or) are moved forward as well. Operands that read a $name parameter
+// fail when it is unbound, so they stay where they were written and operands are only reordered
+// between them; with that, reordering changes which attributes are read but not the results.
+type Optimizer struct {
+	// Selectivity holds observed predicate outcomes; nil orders by estimated cost alone.
+	Selectivity *Selectivity
+}
+
+// Optimize returns an equivalent rule with reordered and/or operands. The input is not modified.
+func (o *Optimizer) Optimize(compiled *CompiledRule) *CompiledRule {
+	optimized := *compiled
+	optimized.AST = o.optimize(compiled.AST)
+
+	return &optimized
+}
+
+// SetOptimizer makes the engine optimize rules when it compiles them. Passing nil disables
+// optimization. The rule cache is cleared so cached rules are compiled again; rules compiled
+// later pick up the selectivity observed so far.
+func (e *Engine) SetOptimizer(optimizer *Optimizer) {
+	e.optimizer.Store(optimizer)
+	e.compiledRules.Clear()
+}
+
+func (o *Optimizer) optimize(node *ASTNode) *ASTNode {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Type {
+	case NodeBinaryOp:
+		if node.Operator != AND && node.Operator != OR {
+			return node
+		}
+
+		var operands []*ASTNode
+
+		for _, operand := range flattenLogical(node, node.Operator, nil) {
+			operands = append(operands, o.optimize(operand))
+		}
+
+		o.order(node.Operator, operands)
+
+		result := operands[0]
+		for _, operand := range operands[1:] {
+			result = NewBinaryOpNode(node.Operator, result, operand)
+		}
+
+		return result
+
+	case NodeUnaryOp:
+		if node.Operator != NOT {
+			return node
+		}
+
+		operand := o.optimize(node.Left)
+		if operand == node.Left {
+			return node
+		}
+
+		optimized := *node
+		optimized.Left = operand
+
+		return &optimized
+
+	case NodeConditional, NodeLet, NodeIdentifier, NodeLiteral, NodeArray, NodeProperty, NodeParameter, NodeReference,
+		NodeVariable, NodeCall, NodeError:
+		return node
+	}
+
+	return node
+}
+
+// order sorts the operands of op by rank, keeping the written order between equal ranks. An
+// operand's rank is its cost divided by the probability that it decides the operator, which
+// minimises the expected cost of the chain. Operands that read a parameter are barriers: they
+// keep their position, and only the operands between two barriers are sorted.
+func (o *Optimizer) order(op TokenType, operands []*ASTNode) {
+	start := 0
+
+	for i, operand := range operands {
+		if readsParameter(operand) {
+			o.sortSegment(op, operands[start:i])
+			start = i + 1
+		}
+	}
+
+	o.sortSegment(op, operands[start:])
+}
+
+func (o *Optimizer) sortSegment(op TokenType, operands []*ASTNode) {
+	ranks := make(map[*ASTNode]float64, len(operands))
+
+	for _, operand := range operands {
+		deciding := o.probability(operand)
+		if op == AND {
+			deciding = 1 - deciding
+		}
+
+		ranks[operand] = estimateCost(operand) / min(max(deciding, minProbability), maxProbability)
+	}
+
+	sort.SliceStable(operands, func(i, j int) bool {
+		return ranks[operands[i]] < ranks[operands[j]]
+	})
+}
+
+// readsParameter reports whether evaluating node can fail on an unbound parameter.
+func readsParameter(node *ASTNode) bool {
+	return findNode(node, func(n *ASTNode) bool { return n.Type == NodeParameter }) != nil
+}
+
+// probability estimates how often a node evaluates to true, combining the observed rates of its
+// predicates as if they were independent.
+func (o *Optimizer) probability(node *ASTNode) float64 {
+	switch {
+	case node.Type == NodeBinaryOp && node.Operator == AND:
+		return o.probability(node.Left) * o.probability(node.Right)
+	case node.Type == NodeBinaryOp && node.Operator == OR:
+		return 1 - (1-o.probability(node.Left))*(1-o.probability(node.Right))
+	case node.Type == NodeUnaryOp && node.Operator == NOT:
+		return 1 - o.probability(node.Left)
+	}
+
+	if o.Selectivity != nil {
+		if rate, ok := o.Selectivity.rate(node); ok {
+			return rate
+		}
+	}
+
+	return defaultProbability
+}
+
+// estimateCost returns the relative cost of evaluating a node in full.
+func estimateCost(node *ASTNode) float64 {
+	if node == nil {
+		return 0
+	}
+
+	switch node.Type {
+	case NodeBinaryOp:
+		return operatorCost(node) + estimateCost(node.Left) + estimateCost(node.Right)
+	case NodeUnaryOp:
+		return operatorCost(node) + estimateCost(node.Left)
+	case NodeIdentifier:
+		return costAttribute
+	case NodeProperty:
+		return costAttribute * float64(len(node.Children))
+	case NodeConditional:
+		cost := 0.0
+		for _, child := range node.Children {
+			cost += estimateCost(child)
+		}
+
+		return cost
+	case NodeLet:
+		return estimateCost(node.Left) + estimateCost(node.Right)
+	case NodeLiteral, NodeArray, NodeParameter, NodeReference, NodeVariable, NodeCall, NodeError:
+		return 0
+	}
+
+	return 0
+}
+
+func operatorCost(node *ASTNode) float64 {
+	switch node.Operator { //nolint:exhaustive // only operators have a cost of their own
+	case AND, OR, NOT:
+		return 0
+	case PR:
+		return costPresence
+	case CO, SW, EW:
+		return costString
+	case IN, NOT_IN:
+		return costComparison + costElement*float64(len(node.Right.Value.ArrValue))
+	case DQ, DN, BE, BQ, AF, AQ, DL, DG:
+		return costDateTime
+	default:
+		return costComparison
+	}
+}
+
+// Selectivity records how often predicates evaluate to true, so an Optimizer can run the
+// predicates most likely to decide a chain first. Predicates are identified by their canonical
+// form, so identical predicates in different rules share their statistics. It is safe for
+// concurrent use.
+type Selectivity struct {
+	predicates *xsync.Map[string, *predicateStats]
+}
+
+type predicateStats struct {
+	observations atomic.Int64
+	matches      atomic.Int64
+}
+
+func NewSelectivity() *Selectivity {
+	return &Selectivity{predicates: xsync.NewMap[string, *predicateStats]()}
+}
+
+// Observe evaluates every predicate of a rule against a context, without short-circuiting, and
+// records the outcomes. Observing a sample of production contexts is enough; it is much slower
+// than evaluating the rule.
+func (s *Selectivity) Observe(compiled *CompiledRule, context D) error {
+	return s.observe(NewEvaluator(), compiled.AST, context)
+}
+
+func (s *Selectivity) observe(evaluator *Evaluator, node *ASTNode, context D) error {
+	if isConnective(node) {
+		if err := s.observe(evaluator, node.Left, context); err != nil {
+			return err
+		}
+
+		if node.Right == nil {
+			return nil
+		}
+
+		return s.observe(evaluator, node.Right, context)
+	}
+
+	matched, err := evaluator.Evaluate(node, context)
+	if err != nil {
+		return err
+	}
+
+	var matches int64
+	if matched {
+		matches = 1
+	}
+
+	s.record(node, 1, matches)
+
+	return nil
+}
+
+// record adds outcomes of a predicate to its statistics.
+func (s *Selectivity) record(node *ASTNode, observations, matches int64) {
+	stats, _ := s.predicates.LoadOrCompute(Format(node), func() (*predicateStats, bool) {
+		return &predicateStats{}, false
+	})
+
+	stats.observations.Add(observations)
+	stats.matches.Add(matches)
+}
+
+// Rate returns the fraction of observations in which a predicate, given in rule syntax, was true,
+// together with the number of observations.
+func (s *Selectivity) Rate(predicate string) (float64, int64) {
+	ast, err := ParseRule(predicate)
+	if err != nil {
+		return 0, 0
+	}
+
+	return s.stats(ast)
+}
+
+func (s *Selectivity) rate(node *ASTNode) (float64, bool) {
+	rate, observations := s.stats(node)
+	return rate, observations >= minObservations
+}
+
+func (s *Selectivity) stats(node *ASTNode) (float64, int64) {
+	stats, found := s.predicates.Load(Format(node))
+	if !found {
+		return 0, 0
+	}
+
+	observations := stats.observations.Load()
+	if observations == 0 {
+		return 0, 0
+	}
+
+	return float64(stats.matches.Load()) / float64(observations), observations
+}
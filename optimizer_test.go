@@ -0,0 +1,207 @@
+package rule
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimizer(t *testing.T) {
+	t.Run("CostOrder", testOptimizerCostOrder)
+	t.Run("Selectivity", testOptimizerSelectivity)
+	t.Run("ResultsUnchanged", testOptimizerResultsUnchanged)
+	t.Run("Engine", testOptimizerEngine)
+	t.Run("UnboundParameter", testOptimizerUnboundParameter)
+}
+
+func testOptimizerCostOrder(t *testing.T) {
+	tests := []struct {
+		rule      string
+		optimized string
+	}{
+		{`created_at dl 30 and name co "x" and age gt 18`, `age gt 18 and name co "x" and created_at dl 30`},
+		{`(a dl 1 or b eq 1) and c eq 2`, `c eq 2 and (b eq 1 or a dl 1)`},
+		{`a eq 1 and (b eq 2 and c eq 3)`, `a eq 1 and b eq 2 and c eq 3`},
+		{`user.profile.name eq "x" or id eq 1`, `id eq 1 or user.profile.name eq "x"`},
+		{`not (d af "2024-01-01" and e pr)`, `not (e pr and d af "2024-01-01")`},
+		{`x in [1, 2, 3, 4, 5, 6, 7, 8] and y in [1]`, `y in [1] and x in [1, 2, 3, 4, 5, 6, 7, 8]`},
+		{`b eq 1 and a eq 1`, `b eq 1 and a eq 1`},
+		{`a eq 1`, `a eq 1`},
+		{`x eq 1 or $p gt 2`, `x eq 1 or $p gt 2`},
+		{`a dl 1 and b eq 1 and c gt $p and e dl 1 and f eq 1`, `b eq 1 and a dl 1 and c gt $p and f eq 1 and e dl 1`},
+	}
+
+	optimizer := &Optimizer{}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			compiled, err := NewEngine().CompileRule(tt.rule)
+			require.NoError(t, err)
+
+			optimized := optimizer.Optimize(compiled)
+			require.Equal(t, tt.optimized, Format(optimized.AST))
+			require.Equal(t, compiled.Hash, optimized.Hash)
+			require.NotSame(t, compiled, optimized)
+		})
+	}
+}
+
+func testOptimizerSelectivity(t *testing.T) {
+	engine := NewEngine()
+	selectivity := NewSelectivity()
+
+	compiled, err := engine.CompileRule(`common eq 1 and rare eq 1`)
+	require.NoError(t, err)
+
+	for i := range 100 {
+		context := D{"common": 1, "rare": 0}
+		if i%10 == 0 {
+			context["rare"] = 1
+		}
+
+		require.NoError(t, selectivity.Observe(compiled, context))
+	}
+
+	rate, observations := selectivity.Rate(`rare == 1`)
+	require.InDelta(t, 0.1, rate, 1e-9)
+	require.Equal(t, int64(100), observations)
+
+	optimizer := &Optimizer{Selectivity: selectivity}
+
+	// and runs the operand most likely to be false first, or the one most likely to be true.
+	require.Equal(t, `rare eq 1 and common eq 1`, Format(optimizer.Optimize(compiled).AST))
+
+	compiled, err = engine.CompileRule(`rare eq 1 or common eq 1`)
+	require.NoError(t, err)
+	require.Equal(t, `common eq 1 or rare eq 1`, Format(optimizer.Optimize(compiled).AST))
+
+	// Negations and nested chains combine the rates of their predicates.
+	compiled, err = engine.CompileRule(`not (rare eq 1) and (common eq 1 and rare eq 1)`)
+	require.NoError(t, err)
+	require.Equal(t, `rare eq 1 and not rare eq 1 and common eq 1`, Format(optimizer.Optimize(compiled).AST))
+
+	// Too few observations fall back to the cost model.
+	sparse := NewSelectivity()
+	require.NoError(t, sparse.Observe(compiled, D{"common": 1, "rare": 0}))
+
+	compiled, err = engine.CompileRule(`common eq 1 and rare eq 1`)
+	require.NoError(t, err)
+	require.Equal(t, `common eq 1 and rare eq 1`, Format((&Optimizer{Selectivity: sparse}).Optimize(compiled).AST))
+
+	rate, observations = sparse.Rate(`unseen pr`)
+	require.Zero(t, rate)
+	require.Zero(t, observations)
+}
+
+func testOptimizerResultsUnchanged(t *testing.T) {
+	rules := []string{
+		`created_at dl 30 and name co "x" and age gt 18`,
+		`(a dl 1 or b eq 1) and (c eq 2 or not (d pr))`,
+		`not (name sw "A" and age lt 30) or tags in ["x", "y"]`,
+		`age gt 18 and (name ew "n" or created_at af "2024-01-01T00:00:00Z") and not c`,
+		`a or b or c or d`,
+	}
+
+	var contexts []D
+
+	for i := range 64 {
+		context := D{}
+
+		if i&1 != 0 {
+			context["age"] = 20 + i
+		}
+
+		if i&2 != 0 {
+			context["name"] = fmt.Sprintf("Ann%d", i)
+		}
+
+		if i&4 != 0 {
+			context["created_at"] = "2024-06-01T00:00:00Z"
+		}
+
+		if i&8 != 0 {
+			context["a"] = "2020-01-01T00:00:00Z"
+			context["b"] = 1
+		}
+
+		if i&16 != 0 {
+			context["c"] = i%3 == 0
+			context["tags"] = "y"
+		}
+
+		if i&32 != 0 {
+			context["d"] = true
+		}
+
+		contexts = append(contexts, context)
+	}
+
+	engine := NewEngine()
+	selectivity := NewSelectivity()
+
+	for _, rule := range rules {
+		compiled, err := engine.CompileRule(rule)
+		require.NoError(t, err)
+
+		for _, context := range contexts {
+			require.NoError(t, selectivity.Observe(compiled, context))
+		}
+	}
+
+	for _, optimizer := range []*Optimizer{{}, {Selectivity: selectivity}} {
+		for _, rule := range rules {
+			compiled, err := engine.CompileRule(rule)
+			require.NoError(t, err)
+
+			optimized := optimizer.Optimize(compiled)
+
+			for _, context := range contexts {
+				expected, err := engine.EvaluateCompiled(compiled, context)
+				require.NoError(t, err)
+
+				actual, err := engine.EvaluateCompiled(optimized, context)
+				require.NoError(t, err)
+				require.Equal(t, expected, actual, "rule=%q optimized=%q context=%v",
+					rule, Format(optimized.AST), context)
+			}
+		}
+	}
+}
+
+func testOptimizerEngine(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.AddQuery(`signup dl 30 and vip eq true`))
+
+	engine.SetOptimizer(&Optimizer{})
+
+	compiled, err := engine.CompileRule(`signup dl 30 and vip eq true`)
+	require.NoError(t, err)
+	require.Equal(t, `vip eq true and signup dl 30`, Format(compiled.AST), "cached rules are optimized again")
+	require.Equal(t, "signup", compiled.Attributes()[0].String(), "attributes are unaffected")
+
+	resolver := &countingResolver{values: D{"vip": false, "signup": "2024-01-01T00:00:00Z"}}
+
+	result, err := engine.EvaluateResolver(`signup dl 30 and vip eq true`, resolver)
+	require.NoError(t, err)
+	require.False(t, result)
+	require.Equal(t, []string{"vip"}, resolver.calls, "the expensive predicate is skipped")
+
+	engine.SetOptimizer(nil)
+
+	compiled, err = engine.CompileRule(`signup dl 30 and vip eq true`)
+	require.NoError(t, err)
+	require.Equal(t, `signup dl 30 and vip eq true`, Format(compiled.AST))
+}
+
+func testOptimizerUnboundParameter(t *testing.T) {
+	engine := NewEngine()
+	engine.SetOptimizer(&Optimizer{})
+
+	result, err := engine.Evaluate(`x eq 1 or $p gt 2`, D{"x": 1})
+	require.NoError(t, err, "the parameter is not read once x eq 1 decides the rule")
+	require.True(t, result)
+
+	_, err = engine.Evaluate(`x eq 2 or $p gt 2`, D{"x": 1})
+	require.ErrorIs(t, err, ErrUnboundParameter)
+}
@@ -0,0 +1,55 @@
+package rule
+
+import (
+	"context"
+	"sort"
+)
+
+// EvaluateWith evaluates a compiled rule with values bound to its $name placeholders, so one
+// compiled rule serves every tenant or threshold: `amount gt $limit and country in $countries`
+// is compiled once and evaluated with different params. Params are read like context values;
+// arrays must be []any. Evaluating a placeholder that params does not bind fails with
+// ErrUnboundParameter.
+func (e *Engine) EvaluateWith(compiled *CompiledRule, data D, params D) (bool, error) {
+	evaluator := Evaluator{params: params, profile: e.profile(compiled)}
+
+	if limits := e.limits.Load(); limits != nil && limits.MaxSteps > 0 {
+		evaluator.meter = &meter{ctx: context.Background(), maxSteps: limits.MaxSteps}
+	}
+
+	return evaluator.Evaluate(compiled.AST, data)
+}
+
+// Parameters returns the names of the placeholders the rule references, without the $, sorted
+// and without duplicates.
+func (c *CompiledRule) Parameters() []string {
+	names := make(map[string]bool)
+	collectParameters(c.AST, names)
+
+	parameters := make([]string, 0, len(names))
+	for name := range names {
+		parameters = append(parameters, name)
+	}
+
+	sort.Strings(parameters)
+
+	return parameters
+}
+
+func collectParameters(node *ASTNode, names map[string]bool) {
+	if node == nil {
+		return
+	}
+
+	if node.Type == NodeParameter {
+		names[node.Value.StrValue] = true
+		return
+	}
+
+	collectParameters(node.Left, names)
+	collectParameters(node.Right, names)
+
+	for _, child := range node.Children {
+		collectParameters(child, names)
+	}
+}
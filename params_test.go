@@ -0,0 +1,94 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluateWith(t *testing.T) {
+	t.Run("BindsPerCall", testEvaluateWithBindsPerCall)
+	t.Run("Unbound", testEvaluateWithUnbound)
+	t.Run("StepBudget", testEvaluateWithStepBudget)
+	t.Run("Parameters", testParameters)
+	t.Run("Syntax", testParameterSyntax)
+}
+
+func testEvaluateWithBindsPerCall(t *testing.T) {
+	engine := NewEngine()
+
+	compiled, err := engine.CompileRule(`amount gt $limit and country in $countries`)
+	require.NoError(t, err)
+
+	data := D{"amount": 250, "country": "br"}
+
+	tests := []struct {
+		params   D
+		expected bool
+	}{
+		{D{"limit": 100, "countries": []any{"BR", "AR"}}, true},
+		{D{"limit": 300, "countries": []any{"BR", "AR"}}, false},
+		{D{"limit": 100.5, "countries": []any{"US"}}, false},
+	}
+
+	for _, tt := range tests {
+		result, err := engine.EvaluateWith(compiled, data, tt.params)
+		require.NoError(t, err)
+		require.Equal(t, tt.expected, result, "params=%v", tt.params)
+	}
+
+	require.Equal(t, 1, engine.compiledRules.Size(), "params do not add cache entries")
+
+	result, err := engine.EvaluateWith(compiled, data, D{"limit": 100, "countries": "BR"})
+	require.NoError(t, err)
+	require.False(t, result, "a non-array parameter is never a haystack")
+}
+
+func testEvaluateWithUnbound(t *testing.T) {
+	engine := NewEngine()
+
+	compiled, err := engine.CompileRule(`amount gt $limit`)
+	require.NoError(t, err)
+
+	_, err = engine.EvaluateWith(compiled, D{"amount": 1}, D{"other": 1})
+	require.ErrorIs(t, err, ErrUnboundParameter)
+	require.ErrorContains(t, err, "$limit")
+
+	_, err = engine.Evaluate(`amount gt $limit`, D{"amount": 1})
+	require.ErrorIs(t, err, ErrUnboundParameter)
+}
+
+func testEvaluateWithStepBudget(t *testing.T) {
+	engine := NewEngine()
+	engine.SetLimits(Limits{MaxSteps: 2})
+
+	compiled, err := engine.CompileRule(`a gt $min and a lt $max`)
+	require.NoError(t, err)
+
+	_, err = engine.EvaluateWith(compiled, D{"a": 5}, D{"min": 1, "max": 10})
+	require.ErrorIs(t, err, ErrStepBudgetExceeded)
+}
+
+func testParameters(t *testing.T) {
+	compiled, err := NewEngine().CompileRule(`$b lt x and (if $a then $b else 0) gt y`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, compiled.Parameters())
+	require.Equal(t, []string{"x", "y"}, []string{compiled.Attributes()[0].String(), compiled.Attributes()[1].String()})
+
+	constant, err := NewEngine().CompileRule(`x eq 1`)
+	require.NoError(t, err)
+	require.Empty(t, constant.Parameters())
+}
+
+func testParameterSyntax(t *testing.T) {
+	ast, err := ParseRule(`amount gt $limit_1`)
+	require.NoError(t, err)
+	require.Equal(t, NodeParameter, ast.Right.Type)
+	require.Equal(t, "limit_1", ast.Right.Value.StrValue)
+	require.Equal(t, "amount gt $limit_1", Format(ast))
+
+	for _, rule := range []string{`amount gt $`, `amount gt $1`, `$limit pr`, `x in [$a]`, `$a $b`} {
+		_, err := ParseRule(rule)
+		require.Error(t, err, rule)
+	}
+}
@@ -3,20 +3,43 @@ package rule
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 type Parser struct {
+	// MaxDepth bounds how deeply not operators and parenthesized groups may nest, so hostile input
+	// cannot exhaust the stack. NewParser sets it to DefaultMaxDepth; zero disables the check.
+	MaxDepth int
+
 	tokens   []Token
 	current  int
 	curToken Token
+	// prevEnd is the end offset of the last consumed token, used to compute node spans.
+	prevEnd int
+	// depth counts the not operators and groups being parsed, see MaxDepth.
+	depth int
+	// parenDepth counts the parenthesized groups being parsed, so recovery stops at their end.
+	parenDepth int
+	// recovering makes syntax errors produce error nodes instead of aborting, see ParseWithRecovery.
+	recovering bool
+	errors     []*SyntaxError
+	// variables counts the let bindings of each name in scope, so shadowed names stay bound when
+	// the inner binding goes out of scope.
+	variables map[string]int
+	// bindingValue is set while the value of a let binding is parsed, so a bare in ends the
+	// value instead of starting a membership test. Parentheses clear it.
+	bindingValue bool
 }
 
 func NewParser(tokens []Token) *Parser {
 	p := &Parser{
-		tokens:  tokens,
-		current: 0,
+		MaxDepth:  DefaultMaxDepth,
+		tokens:    tokens,
+		current:   0,
+		variables: make(map[string]int),
 	}
 	p.curToken = p.tokens[0]
 
@@ -31,13 +54,105 @@ func (p *Parser) Parse() (*ASTNode, error) {
 
 	// Check for trailing tokens after a complete expression
 	if p.curToken.Type != EOF {
-		return nil, ErrTrailingTokens
+		return nil, p.errorAt(p.curToken, ErrTrailingTokens)
 	}
 
 	return ast, nil
 }
 
+// ParseWithRecovery parses like Parse but does not stop at the first syntax error. Each error is
+// recorded, the offending input up to the next and/or or closing parenthesis is replaced by a
+// NodeError, and parsing resumes, so the result is a partial AST covering all valid parts.
+func (p *Parser) ParseWithRecovery() (*ASTNode, []*SyntaxError) {
+	p.recovering = true
+	defer func() { p.recovering = false }()
+
+	return p.parseRecoveredExpression(), p.errors
+}
+
+// parseRecoveredExpression parses an expression in recovery mode up to the end of input or of the
+// enclosing group. Tokens left after a complete expression are reported and skipped, and any
+// and/or operands that follow them are folded into the result.
+func (p *Parser) parseRecoveredExpression() *ASTNode {
+	ast, err := p.parseExpression()
+	if err != nil {
+		ast = p.recover(p.curToken.Start, err)
+	}
+
+	for p.curToken.Type != EOF && (p.curToken.Type != PAREN_CLOSE || p.parenDepth == 0) {
+		if p.parenDepth > 0 {
+			p.record(p.errorAt(p.curToken, fmt.Errorf("expected %s, got %s", PAREN_CLOSE, p.curToken.Type)))
+		} else {
+			p.record(p.errorAt(p.curToken, ErrTrailingTokens))
+		}
+
+		p.synchronize()
+
+		if p.curToken.Type != AND && p.curToken.Type != OR {
+			continue
+		}
+
+		op := p.curToken.Type
+		p.advance()
+
+		right, rightErr := p.parseExpression()
+		if rightErr != nil {
+			right = p.recover(p.curToken.Start, rightErr)
+		}
+
+		ast = p.spanned(NewBinaryOpNode(op, ast, right), ast.Start)
+	}
+
+	return ast
+}
+
+// record adds an error to the diagnostics collected by a recovering parse.
+func (p *Parser) record(err error) {
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		syntaxErr = &SyntaxError{Err: err, Start: p.curToken.Start, End: p.curToken.End}
+	}
+
+	p.errors = append(p.errors, syntaxErr)
+}
+
+// recover records err and skips the input that caused it, returning an error node spanning the
+// skipped source from start.
+func (p *Parser) recover(start int, err error) *ASTNode {
+	p.record(err)
+	p.synchronize()
+
+	return NewErrorNode(start, max(start, p.prevEnd))
+}
+
+// synchronize skips tokens up to the next and/or at the current nesting level, the closing
+// parenthesis of the enclosing group or the end of input.
+func (p *Parser) synchronize() {
+	nested := 0
+
+	for p.curToken.Type != EOF {
+		switch p.curToken.Type { //nolint:exhaustive // only grouping and logical tokens end a skipped region
+		case AND, OR:
+			if nested == 0 {
+				return
+			}
+		case PAREN_OPEN:
+			nested++
+		case PAREN_CLOSE:
+			if nested > 0 {
+				nested--
+			} else if p.parenDepth > 0 {
+				return
+			}
+		}
+
+		p.advance()
+	}
+}
+
 func (p *Parser) advance() {
+	p.prevEnd = p.curToken.End
+
 	if p.current < len(p.tokens)-1 {
 		p.current++
 		p.curToken = p.tokens[p.current]
@@ -65,14 +180,46 @@ func (p *Parser) isLargeIntegerString(s string) (int64, bool) {
 	return 0, false
 }
 
+// spanned records the source span of a node that started at start and ends at the last consumed token.
+func (p *Parser) spanned(node *ASTNode, start int) *ASTNode {
+	node.Start = start
+	node.End = p.prevEnd
+
+	return node
+}
+
+// errorAt attaches the position of the offending token to a parse error. An identifier is never
+// valid where the parser reports an error, so it is treated as a misspelled keyword and the
+// closest keywords are suggested.
+func (p *Parser) errorAt(token Token, err error) error {
+	syntaxErr := &SyntaxError{Err: err, Start: token.Start, End: token.End}
+
+	if token.Type == IDENTIFIER {
+		syntaxErr.Suggestions = suggestKeywords(token.Value)
+	}
+
+	return syntaxErr
+}
+
+// enter descends one nesting level at the current token, failing with ErrMaxDepthExceeded when that
+// would exceed MaxDepth. Every successful enter is paired with a leave.
+func (p *Parser) enter() error {
+	if p.MaxDepth > 0 && p.depth >= p.MaxDepth {
+		return p.errorAt(p.curToken, &LimitError{Err: ErrMaxDepthExceeded, Limit: p.MaxDepth})
+	}
+
+	p.depth++
+
+	return nil
+}
+
+func (p *Parser) leave() {
+	p.depth--
+}
+
 func (p *Parser) expect(tokenType TokenType) error {
 	if p.curToken.Type != tokenType {
-		return fmt.Errorf(
-			"expected %s, got %s at position %d",
-			tokenType,
-			p.curToken.Type,
-			p.current,
-		)
+		return p.errorAt(p.curToken, fmt.Errorf("expected %s, got %s", tokenType, p.curToken.Type))
 	}
 
 	p.advance()
@@ -99,7 +246,7 @@ func (p *Parser) parseOrExpression() (*ASTNode, error) {
 			return nil, parseErr
 		}
 
-		left = NewBinaryOpNode(op, left, right)
+		left = p.spanned(NewBinaryOpNode(op, left, right), left.Start)
 	}
 
 	return left, nil
@@ -120,7 +267,7 @@ func (p *Parser) parseAndExpression() (*ASTNode, error) {
 			return nil, parseErr
 		}
 
-		left = NewBinaryOpNode(op, left, right)
+		left = p.spanned(NewBinaryOpNode(op, left, right), left.Start)
 	}
 
 	return left, nil
@@ -128,69 +275,121 @@ func (p *Parser) parseAndExpression() (*ASTNode, error) {
 
 func (p *Parser) parseNotExpression() (*ASTNode, error) {
 	if p.curToken.Type == NOT {
+		start := p.curToken.Start
+
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+
 		p.advance()
 
 		operand, err := p.parseNotExpression()
+		p.leave()
+
 		if err != nil {
 			return nil, err
 		}
 
-		return NewUnaryOpNode(NOT, operand), nil
+		return p.spanned(NewUnaryOpNode(NOT, operand), start), nil
 	}
 
-	return p.parseComparisonExpression()
+	start := p.curToken.Start
+
+	node, err := p.parseComparisonExpression()
+	if err != nil && p.recovering {
+		return p.recover(start, err), nil
+	}
+
+	return node, err
 }
 
 func (p *Parser) parseComparisonExpression() (*ASTNode, error) {
-	left, err := p.parsePrimaryExpression()
+	start := p.curToken.Start
+
+	left, err := p.parseAdditiveExpression()
 	if err != nil {
 		return nil, err
 	}
 
-	if p.isComparisonOperator(p.curToken.Type) {
+	if p.isComparisonOperator(p.curToken.Type) && (p.curToken.Type != IN || !p.bindingValue) {
 		op := p.curToken.Type
 		p.advance()
 
 		if op == PR {
-			return NewUnaryOpNode(PR, left), nil
+			return p.spanned(NewUnaryOpNode(PR, left), start), nil
 		}
 
-		right, parseErr := p.parsePrimaryExpression()
+		rightStart := p.curToken.Start
+
+		right, parseErr := p.parseAdditiveExpression()
 		if parseErr != nil {
-			return nil, parseErr
+			if !p.recovering {
+				return nil, parseErr
+			}
+
+			// Keep the comparison so tooling still sees its attribute and operator.
+			right = p.recover(rightStart, parseErr)
 		}
 
-		return NewBinaryOpNode(op, left, right), nil
+		return p.spanned(NewBinaryOpNode(op, left, right), start), nil
 	}
 
 	// Check for missing operator - if we have another value without an operator, that's an error
 	if p.isValue(p.curToken.Type) {
-		return nil, ErrMissingOperator
+		return nil, p.errorAt(p.curToken, ErrMissingOperator)
 	}
 
 	return left, nil
 }
 
-func (p *Parser) parsePrimaryExpression() (*ASTNode, error) {
-	switch p.curToken.Type {
-	case PAREN_OPEN:
+func (p *Parser) parseAdditiveExpression() (*ASTNode, error) {
+	left, err := p.parseMultiplicativeExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.curToken.Type == PLUS || p.curToken.Type == MINUS {
+		op := p.curToken.Type
 		p.advance()
 
-		// Check for empty parentheses
-		if p.curToken.Type == PAREN_CLOSE {
-			return nil, ErrEmptyParentheses
+		right, parseErr := p.parseMultiplicativeExpression()
+		if parseErr != nil {
+			return nil, parseErr
 		}
 
-		expr, err := p.parseExpression()
-		if err != nil {
-			return nil, err
-		}
+		left = p.spanned(NewBinaryOpNode(op, left, right), left.Start)
+	}
 
-		if expectErr := p.expect(PAREN_CLOSE); expectErr != nil {
-			return nil, expectErr
+	return left, nil
+}
+
+func (p *Parser) parseMultiplicativeExpression() (*ASTNode, error) {
+	left, err := p.parsePrimaryExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.curToken.Type == MULTIPLY || p.curToken.Type == DIVIDE || p.curToken.Type == MODULO {
+		op := p.curToken.Type
+		p.advance()
+
+		right, parseErr := p.parsePrimaryExpression()
+		if parseErr != nil {
+			return nil, parseErr
 		}
 
-		return expr, nil
+		left = p.spanned(NewBinaryOpNode(op, left, right), left.Start)
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parsePrimaryExpression() (*ASTNode, error) {
+	start := p.curToken.Start
+
+	switch p.curToken.Type {
+	case PAREN_OPEN:
+		return p.parseParenthesized()
 
 	case STRING:
 		value := p.curToken.Value
@@ -198,22 +397,32 @@ func (p *Parser) parsePrimaryExpression() (*ASTNode, error) {
 
 		// Check if this string represents a large integer
 		if intVal, isLargeInt := p.isLargeIntegerString(value); isLargeInt {
-			return NewLargeIntegerLiteralNode(intVal), nil
+			return p.spanned(NewLargeIntegerLiteralNode(intVal), start), nil
 		}
 
-		return NewStringLiteralNode(value), nil
+		return p.spanned(NewStringLiteralNode(value), start), nil
 
 	case NUMBER:
 		value := p.curToken.NumValue
 		p.advance()
 
-		return NewNumberLiteralNode(value), nil
+		return p.spanned(NewNumberLiteralNode(value), start), nil
 
 	case BOOLEAN:
 		value := p.curToken.BoolValue
 		p.advance()
 
-		return NewBooleanLiteralNode(value), nil
+		return p.spanned(NewBooleanLiteralNode(value), start), nil
+
+	case DURATION:
+		value, err := ParseDuration(p.curToken.Value)
+		if err != nil {
+			return nil, p.errorAt(p.curToken, err)
+		}
+
+		p.advance()
+
+		return p.spanned(NewDurationLiteralNode(value), start), nil
 
 	case ARRAY_START:
 		return p.parseArray()
@@ -221,6 +430,27 @@ func (p *Parser) parsePrimaryExpression() (*ASTNode, error) {
 	case IDENTIFIER:
 		return p.parseIdentifierOrProperty()
 
+	case PARAMETER:
+		name := p.curToken.Value
+		p.advance()
+
+		return p.spanned(NewParameterNode(name), start), nil
+
+	case REFERENCE:
+		name := p.curToken.Value
+		p.advance()
+
+		return p.spanned(NewReferenceNode(name), start), nil
+
+	case IF:
+		return p.parseIf()
+
+	case LET:
+		return p.parseLet()
+
+	case CASE:
+		return p.parseCase()
+
 	case EOF,
 		ARRAY_END,
 		PAREN_CLOSE,
@@ -250,15 +480,232 @@ func (p *Parser) parsePrimaryExpression() (*ASTNode, error) {
 		OR,
 		NOT,
 		EQUALS,
-		NOT_EQUALS:
-		return nil, fmt.Errorf("unexpected token %s at position %d", p.curToken.Type, p.current)
+		NOT_EQUALS,
+		PLUS,
+		MINUS,
+		MULTIPLY,
+		DIVIDE,
+		MODULO,
+		THEN,
+		ELSE,
+		WHEN,
+		END,
+		ASSIGN:
+		return nil, p.errorAt(p.curToken, fmt.Errorf("unexpected token %s", p.curToken.Type))
 
 	default:
-		return nil, fmt.Errorf("unexpected token %s at position %d", p.curToken.Type, p.current)
+		return nil, p.errorAt(p.curToken, fmt.Errorf("unexpected token %s", p.curToken.Type))
 	}
 }
 
+// parseIf parses `if condition then value else value`. The else branch is required and extends
+// as far as possible, so `else if` chains nest and a conditional used as an operand needs
+// parentheses.
+func (p *Parser) parseIf() (*ASTNode, error) {
+	start := p.curToken.Start
+
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	p.advance()
+
+	branch, err := p.parseBranch()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(ELSE); err != nil {
+		return nil, err
+	}
+
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.spanned(NewConditionalNode(append(branch, otherwise)...), start), nil
+}
+
+// parseLet parses `let name = value, ... in body`. Each name is visible in the values that follow
+// it and in the body, which extends as far as possible. A value containing a membership test
+// must parenthesize it, as a bare in ends the value.
+func (p *Parser) parseLet() (*ASTNode, error) {
+	start := p.curToken.Start
+	p.advance()
+
+	return p.parseBinding(start)
+}
+
+// parseBinding parses `name = value` and what follows it: more bindings or the body. Each binding
+// is a let node of its own, so each counts towards MaxDepth.
+func (p *Parser) parseBinding(start int) (*ASTNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	if p.curToken.Type != IDENTIFIER {
+		return nil, p.errorAt(p.curToken, fmt.Errorf("expected variable name, got %s", p.curToken.Type))
+	}
+
+	name := p.curToken.Value
+	p.advance()
+
+	if err := p.expect(ASSIGN); err != nil {
+		return nil, err
+	}
+
+	bindingValue := p.bindingValue
+	p.bindingValue = true
+
+	value, err := p.parseExpression()
+
+	p.bindingValue = bindingValue
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.variables[name]++
+	defer func() { p.variables[name]-- }()
+
+	var body *ASTNode
+
+	if p.curToken.Type == COMMA {
+		p.advance()
+		body, err = p.parseBinding(p.curToken.Start)
+	} else if err = p.expect(IN); err == nil {
+		body, err = p.parseExpression()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p.spanned(NewLetNode(name, value, body), start), nil
+}
+
+// parseCase parses `case when condition then value ... [else value] end`.
+func (p *Parser) parseCase() (*ASTNode, error) {
+	start := p.curToken.Start
+
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	p.advance()
+
+	var branches []*ASTNode
+
+	for len(branches) == 0 || p.curToken.Type == WHEN {
+		if err := p.expect(WHEN); err != nil {
+			return nil, err
+		}
+
+		branch, err := p.parseBranch()
+		if err != nil {
+			return nil, err
+		}
+
+		branches = append(branches, branch...)
+	}
+
+	if p.curToken.Type == ELSE {
+		p.advance()
+
+		otherwise, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+
+		branches = append(branches, otherwise)
+	}
+
+	if err := p.expect(END); err != nil {
+		return nil, err
+	}
+
+	return p.spanned(NewConditionalNode(branches...), start), nil
+}
+
+// parseBranch parses `condition then value`.
+func (p *Parser) parseBranch() ([]*ASTNode, error) {
+	condition, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.expect(THEN); err != nil {
+		return nil, err
+	}
+
+	value, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	return []*ASTNode{condition, value}, nil
+}
+
+func (p *Parser) parseParenthesized() (*ASTNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	p.advance()
+
+	// Check for empty parentheses
+	if p.curToken.Type == PAREN_CLOSE {
+		err := p.errorAt(p.curToken, ErrEmptyParentheses)
+		if p.recovering {
+			p.advance() // the group is complete, resume after it
+		}
+
+		return nil, err
+	}
+
+	p.parenDepth++
+
+	bindingValue := p.bindingValue
+	p.bindingValue = false
+
+	var (
+		expr *ASTNode
+		err  error
+	)
+
+	if p.recovering {
+		expr = p.parseRecoveredExpression()
+	} else {
+		expr, err = p.parseExpression()
+	}
+
+	p.parenDepth--
+	p.bindingValue = bindingValue
+
+	if err != nil {
+		return nil, err
+	}
+
+	if expectErr := p.expect(PAREN_CLOSE); expectErr != nil {
+		if !p.recovering {
+			return nil, expectErr
+		}
+
+		// Keep the group's expression and report the missing parenthesis.
+		p.record(expectErr)
+	}
+
+	return expr, nil
+}
+
 func (p *Parser) parseArray() (*ASTNode, error) {
+	start := p.curToken.Start
+
 	if err := p.expect(ARRAY_START); err != nil {
 		return nil, err
 	}
@@ -304,6 +751,19 @@ func (p *Parser) parseArray() (*ASTNode, error) {
 
 				p.advance()
 
+			case DURATION:
+				value, err := ParseDuration(p.curToken.Value)
+				if err != nil {
+					return nil, p.errorAt(p.curToken, err)
+				}
+
+				elements = append(elements, Value{
+					Type:     ValueDuration,
+					DurValue: value,
+				})
+
+				p.advance()
+
 			case EOF,
 				IDENTIFIER,
 				ARRAY_START,
@@ -336,10 +796,25 @@ func (p *Parser) parseArray() (*ASTNode, error) {
 				OR,
 				NOT,
 				EQUALS,
-				NOT_EQUALS:
-				return nil, fmt.Errorf("unexpected token in array: %s", p.curToken.Type)
+				NOT_EQUALS,
+				PLUS,
+				MINUS,
+				MULTIPLY,
+				DIVIDE,
+				MODULO,
+				IF,
+				THEN,
+				ELSE,
+				CASE,
+				WHEN,
+				END,
+				PARAMETER,
+				REFERENCE,
+				LET,
+				ASSIGN:
+				return nil, p.errorAt(p.curToken, fmt.Errorf("unexpected token in array: %s", p.curToken.Type))
 			default:
-				return nil, fmt.Errorf("unexpected token in array: %s", p.curToken.Type)
+				return nil, p.errorAt(p.curToken, fmt.Errorf("unexpected token in array: %s", p.curToken.Type))
 			}
 
 			if p.curToken.Type == COMMA {
@@ -354,29 +829,72 @@ func (p *Parser) parseArray() (*ASTNode, error) {
 		return nil, err
 	}
 
-	return NewArrayLiteralNode(elements), nil
+	return p.spanned(NewArrayLiteralNode(elements), start), nil
 }
 
 func (p *Parser) parseIdentifierOrProperty() (*ASTNode, error) {
-	path := []string{p.curToken.Value}
+	segments := []Token{p.curToken}
 	p.advance()
 
 	for p.curToken.Type == DOT {
 		p.advance()
 
 		if p.curToken.Type != IDENTIFIER {
-			return nil, fmt.Errorf("expected identifier after dot, got %s", p.curToken.Type)
+			return nil, p.errorAt(p.curToken, fmt.Errorf("expected identifier after dot, got %s", p.curToken.Type))
 		}
 
-		path = append(path, p.curToken.Value)
+		segments = append(segments, p.curToken)
 		p.advance()
 	}
 
-	if len(path) == 1 {
-		return NewIdentifierNode(path[0]), nil
+	if len(segments) == 1 && p.curToken.Type == PAREN_OPEN {
+		return p.parseCall(segments[0])
+	}
+
+	if p.variables[segments[0].Value] > 0 {
+		if len(segments) > 1 {
+			return nil, p.errorAt(segments[1], fmt.Errorf("variable %s has no properties", segments[0].Value))
+		}
+
+		return p.spanned(NewVariableNode(segments[0].Value), segments[0].Start), nil
+	}
+
+	if len(segments) == 1 {
+		return p.spanned(NewIdentifierNode(segments[0].Value), segments[0].Start), nil
+	}
+
+	path := make([]string, len(segments))
+	for i, segment := range segments {
+		path[i] = segment.Value
+	}
+
+	node := NewPropertyNode(path)
+	for i, segment := range segments {
+		node.Children[i].Start = segment.Start
+		node.Children[i].End = segment.End
+	}
+
+	return p.spanned(node, segments[0].Start), nil
+}
+
+// parseCall parses a call of a built-in function. now() is the only one and takes no arguments.
+func (p *Parser) parseCall(name Token) (*ASTNode, error) {
+	if !slices.Contains(functions, name.Value) {
+		return nil, &SyntaxError{
+			Err:         fmt.Errorf("unknown function %s", name.Value),
+			Start:       name.Start,
+			End:         name.End,
+			Suggestions: suggest(name.Value, functions),
+		}
+	}
+
+	p.advance() // consume '('
+
+	if err := p.expect(PAREN_CLOSE); err != nil {
+		return nil, err
 	}
 
-	return NewPropertyNode(path), nil
+	return p.spanned(NewCallNode(name.Value), name.Start), nil
 }
 
 func (p *Parser) isComparisonOperator(tokenType TokenType) bool {
@@ -396,7 +914,23 @@ func (p *Parser) isComparisonOperator(tokenType TokenType) bool {
 		COMMA,
 		AND,
 		OR,
-		NOT:
+		NOT,
+		PLUS,
+		MINUS,
+		MULTIPLY,
+		DIVIDE,
+		MODULO,
+		IF,
+		THEN,
+		ELSE,
+		CASE,
+		WHEN,
+		END,
+		PARAMETER,
+		REFERENCE,
+		LET,
+		ASSIGN,
+		DURATION:
 		return false
 	default:
 		return false
@@ -405,18 +939,25 @@ func (p *Parser) isComparisonOperator(tokenType TokenType) bool {
 
 func (p *Parser) isValue(tokenType TokenType) bool {
 	switch tokenType {
-	case IDENTIFIER, STRING, NUMBER, BOOLEAN, ARRAY_START:
+	case IDENTIFIER, STRING, NUMBER, BOOLEAN, ARRAY_START, PARAMETER, REFERENCE, DURATION:
 		return true
-	case EOF, ARRAY_END, PAREN_OPEN, PAREN_CLOSE, DOT, COMMA,
+	case LET, ASSIGN, EOF, ARRAY_END, PAREN_OPEN, PAREN_CLOSE, DOT, COMMA,
 		EQ, NE, LT, GT, LE, GE, CO, SW, EW, IN, NOT_IN, PR,
-		DQ, DN, BE, BQ, AF, AQ, DL, DG, AND, OR, NOT, EQUALS, NOT_EQUALS:
+		DQ, DN, BE, BQ, AF, AQ, DL, DG, AND, OR, NOT, EQUALS, NOT_EQUALS,
+		PLUS, MINUS, MULTIPLY, DIVIDE, MODULO, IF, THEN, ELSE, CASE, WHEN, END:
 		return false
 	default:
 		return false
 	}
 }
 
+// ParseRule parses and validates a rule, rejecting rules nested deeper than DefaultMaxDepth.
 func ParseRule(rule string) (*ASTNode, error) {
+	return parseRule(rule, DefaultMaxDepth)
+}
+
+// parseRule implements ParseRule with a configurable maximum nesting depth.
+func parseRule(rule string, maxDepth int) (*ASTNode, error) {
 	// Check for empty query
 	if len(strings.TrimSpace(rule)) == 0 {
 		return nil, ErrEmptyQuery
@@ -431,6 +972,7 @@ func ParseRule(rule string) (*ASTNode, error) {
 	}
 
 	parser := NewParser(tokens)
+	parser.MaxDepth = maxDepth
 
 	ast, err := parser.Parse()
 	if err != nil {
@@ -438,9 +980,64 @@ func ParseRule(rule string) (*ASTNode, error) {
 	}
 
 	// Perform semantic validation
-	if validationErr := ValidateAST(ast); validationErr != nil {
+	if validationErr := validateAST(ast, maxDepth); validationErr != nil {
 		return nil, validationErr
 	}
 
 	return ast, nil
 }
+
+// ParseRuleWithRecovery parses a rule without stopping at the first error. It returns a partial
+// AST, in which skipped input is represented by NodeError nodes, together with every lexical,
+// syntax and validation error sorted by position. Unlike ParseRule the AST may be returned
+// alongside errors; it is meant for tooling such as editors and must not be evaluated.
+func ParseRuleWithRecovery(rule string) (*ASTNode, []*SyntaxError) {
+	if len(strings.TrimSpace(rule)) == 0 {
+		return nil, []*SyntaxError{{Err: ErrEmptyQuery}}
+	}
+
+	lexer := NewLexer(rule)
+	tokens := lexer.Tokenize()
+
+	var diagnostics []*SyntaxError
+
+	// Lexing stops at its first error; parse errors past that point only echo the truncation.
+	truncatedAt := -1
+
+	for _, err := range lexer.GetErrors() {
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			syntaxErr = &SyntaxError{Err: err}
+		}
+
+		if truncatedAt < 0 {
+			truncatedAt = syntaxErr.Start
+		}
+
+		diagnostics = append(diagnostics, syntaxErr)
+	}
+
+	ast, parseErrors := NewParser(tokens).ParseWithRecovery()
+
+	for _, err := range parseErrors {
+		if truncatedAt < 0 || err.Start < truncatedAt {
+			diagnostics = append(diagnostics, err)
+		}
+	}
+
+	if deep := nodeAtDepth(ast, DefaultMaxDepth+1); deep != nil {
+		diagnostics = append(diagnostics, &SyntaxError{
+			Err:   &LimitError{Err: ErrMaxDepthExceeded, Limit: DefaultMaxDepth},
+			Start: deep.Start,
+			End:   deep.End,
+		})
+	}
+
+	collectValidationErrors(ast, &diagnostics)
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Start < diagnostics[j].Start
+	})
+
+	return ast, diagnostics
+}
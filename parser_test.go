@@ -1,6 +1,10 @@
 package rule
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -338,3 +342,204 @@ func TestParserPresenceOperator(t *testing.T) {
 		t.Error("Expected PR operator")
 	}
 }
+
+// describeDiagnostics renders diagnostics as "start-end: message" for comparison in tests.
+func describeDiagnostics(diagnostics []*SyntaxError) []string {
+	described := make([]string, len(diagnostics))
+	for i, diagnostic := range diagnostics {
+		described[i] = fmt.Sprintf("%d-%d: %v", diagnostic.Start, diagnostic.End, diagnostic.Err)
+	}
+
+	return described
+}
+
+// Test that the recovering parser reports every error and keeps the valid parts of the rule.
+func TestParseRuleWithRecovery(t *testing.T) {
+	tests := []struct {
+		rule        string
+		diagnostics []string
+		formatted   string
+	}{
+		{
+			rule:        `a eq 1 and b eq 2`,
+			diagnostics: []string{},
+			formatted:   `a eq 1 and b eq 2`,
+		},
+		{
+			rule: `a eq and b gt or c in "x"`,
+			diagnostics: []string{
+				"5-8: unexpected token and",
+				"14-16: unexpected token or",
+				"17-25: IN operator requires an array operand",
+			},
+			formatted: `a eq  and b gt  or c in "x"`,
+		},
+		{
+			rule: `(x eq 1 y) and (z pr or ) and w eq 3`,
+			diagnostics: []string{
+				"8-9: expected ), got IDENTIFIER",
+				"24-25: unexpected token )",
+			},
+			formatted: `x eq 1 and (z pr or ) and w eq 3`,
+		},
+		{
+			rule:        `(a eq 1 or b eq 2`,
+			diagnostics: []string{"17-17: expected ), got EOF"},
+			formatted:   `a eq 1 or b eq 2`,
+		},
+		{
+			rule:        `a eq 1 ) and b eq 2`,
+			diagnostics: []string{"7-8: Unexpected tokens after complete expression"},
+			formatted:   `a eq 1 and b eq 2`,
+		},
+		{
+			rule:        `a eq () or name co 5`,
+			diagnostics: []string{"6-7: Empty parentheses are not allowed", "11-20: " + ErrInvalidStringOp.Message},
+			formatted:   `a eq  or name co 5`,
+		},
+		{
+			rule:        `a eq 1 and name eq "open`,
+			diagnostics: []string{"19-24: Unterminated string literal"},
+			formatted:   `a eq 1 and name eq `,
+		},
+	}
+
+	for _, test := range tests {
+		ast, diagnostics := ParseRuleWithRecovery(test.rule)
+
+		got := describeDiagnostics(diagnostics)
+		if !reflect.DeepEqual(got, test.diagnostics) {
+			t.Errorf("%q: expected diagnostics %q, got %q", test.rule, test.diagnostics, got)
+		}
+
+		if formatted := Format(ast); formatted != test.formatted {
+			t.Errorf("%q: expected partial AST %q, got %q", test.rule, test.formatted, formatted)
+		}
+	}
+}
+
+// Test that ParseRule stays fail-fast and partial ASTs cannot be evaluated.
+func TestParseRuleWithRecoveryContracts(t *testing.T) {
+	rule := `a eq and b gt`
+
+	if ast, err := ParseRule(rule); err == nil || ast != nil {
+		t.Fatal("Expected ParseRule to fail without an AST")
+	}
+
+	ast, diagnostics := ParseRuleWithRecovery(rule)
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %d", len(diagnostics))
+	}
+
+	if ast.Operator != AND || ast.Left.Right.Type != NodeError || ast.Right.Right.Type != NodeError {
+		t.Errorf("Expected both comparisons to keep an error node as right operand, got %q", Format(ast))
+	}
+
+	if _, err := NewEvaluator().Evaluate(ast, D{}); !errors.Is(err, ErrInvalidSyntax) {
+		t.Errorf("Expected ErrInvalidSyntax evaluating a partial AST, got %v", err)
+	}
+
+	if err := ValidateAST(ast); !errors.Is(err, ErrInvalidSyntax) {
+		t.Errorf("Expected ErrInvalidSyntax validating a partial AST, got %v", err)
+	}
+
+	ast, diagnostics = ParseRuleWithRecovery("   ")
+	if ast != nil || len(diagnostics) != 1 || !errors.Is(diagnostics[0], ErrEmptyQuery) {
+		t.Errorf("Expected a single ErrEmptyQuery diagnostic, got %v", describeDiagnostics(diagnostics))
+	}
+}
+
+// Test that nesting beyond MaxDepth is rejected instead of exhausting the stack.
+func TestParserMaxDepth(t *testing.T) {
+	const depth = 100000
+
+	var bindings strings.Builder
+
+	bindings.WriteString("let ")
+
+	bindingStart := 0
+
+	for i := range depth {
+		if i == DefaultMaxDepth {
+			bindingStart = bindings.Len()
+		}
+
+		fmt.Fprintf(&bindings, "v%d = 1, ", i)
+	}
+
+	bindings.WriteString("last = 1 in v0 eq last")
+
+	tests := []struct {
+		name  string
+		rule  string
+		start int
+	}{
+		{"Parentheses", strings.Repeat("(", depth) + "a eq 1" + strings.Repeat(")", depth), DefaultMaxDepth},
+		{"Not", strings.Repeat("not ", depth) + "a eq 1", 4 * DefaultMaxDepth},
+		{"Unbalanced", strings.Repeat("(", depth), DefaultMaxDepth},
+		{"LetBindings", bindings.String(), bindingStart},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRule(tt.rule)
+			if !errors.Is(err, ErrMaxDepthExceeded) {
+				t.Fatalf("Expected ErrMaxDepthExceeded, got %v", err)
+			}
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) || syntaxErr.Start != tt.start {
+				t.Errorf("Expected the error at position %d, got %v", tt.start, err)
+			}
+
+			_, diagnostics := ParseRuleWithRecovery(tt.rule)
+			if len(diagnostics) == 0 || !errors.Is(diagnostics[0], ErrMaxDepthExceeded) {
+				t.Errorf("Expected ErrMaxDepthExceeded from the recovering parser, got %v", diagnostics)
+			}
+		})
+	}
+
+	parser := NewParser(NewLexer("not (not (a eq 1))").Tokenize())
+	parser.MaxDepth = 3
+
+	if _, err := parser.Parse(); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("Expected ErrMaxDepthExceeded with MaxDepth 3, got %v", err)
+	}
+
+	parser = NewParser(NewLexer("not (not (a eq 1))").Tokenize())
+	parser.MaxDepth = 4
+
+	if _, err := parser.Parse(); err != nil {
+		t.Errorf("Expected nesting of exactly MaxDepth to parse, got %v", err)
+	}
+
+	deep := strings.Repeat("(", 2*DefaultMaxDepth) + "a pr" + strings.Repeat(")", 2*DefaultMaxDepth)
+	parser = NewParser(NewLexer(deep).Tokenize())
+	parser.MaxDepth = 0
+
+	if _, err := parser.Parse(); err != nil {
+		t.Errorf("Expected MaxDepth 0 to disable the check, got %v", err)
+	}
+}
+
+// Test that the conditional and let keywords are reserved at the top level but remain usable as
+// property names after a dot.
+func TestParserReservedWords(t *testing.T) {
+	for _, word := range []string{"if", "then", "else", "case", "when", "end", "let"} {
+		var syntaxErr *SyntaxError
+		if _, err := ParseRule(word + " eq 1"); !errors.As(err, &syntaxErr) {
+			t.Errorf("Expected %q to be reserved, got %v", word, err)
+		}
+
+		ast, err := ParseRule("ctx." + word + " eq 1")
+		if err != nil {
+			t.Errorf("Expected ctx.%s to parse, got %v", word, err)
+			continue
+		}
+
+		result, err := NewEvaluator().Evaluate(ast, D{"ctx": D{word: 1}})
+		if err != nil || !result {
+			t.Errorf("Expected ctx.%s eq 1 to be true, got %v, %v", word, result, err)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package rule
+
+import (
+	"math"
+)
+
+// PartialEvaluate evaluates the parts of a rule that only read attributes present in known and
+// returns the residual rule, which reads only the remaining attributes. Conditions that can be
+// decided are folded away: if the whole rule is decided the residual is a constant, see
+// CompiledRule.Constant. Evaluating the residual with the rest of the context gives the same
+// result as evaluating the rule with the full context. Known attributes compared against unknown
+// ones are substituted as literals; values that have no literal form, such as time.Time, maps or
+// arrays outside an in operand, stay as references and must be supplied again. Rules that read
+// the clock, through now(), dl or dg, are left in the residual, so a residual evaluated later
+// compares against the time it is evaluated at.
+func (e *Engine) PartialEvaluate(rule string, known D) (*CompiledRule, error) {
+	compiled, err := e.CompileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.PartialEvaluateCompiled(compiled, known)
+}
+
+// PartialEvaluateCompiled is PartialEvaluate for a pre-compiled rule.
+func (e *Engine) PartialEvaluateCompiled(compiled *CompiledRule, known D) (*CompiledRule, error) {
+	partial := partialEvaluator{evaluator: e.evaluator, known: known}
+
+	residual, err := partial.fold(compiled.AST)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCompiledRule(residual, hash(Format(residual))), nil
+}
+
+// Constant returns the value of a rule that partial evaluation decided completely. The second
+// result is false when the rule still depends on attributes.
+func (c *CompiledRule) Constant() (bool, bool) {
+	return booleanConstant(c.AST)
+}
+
+func booleanConstant(node *ASTNode) (bool, bool) {
+	if node.Type != NodeLiteral || node.Value.Type != ValueBoolean {
+		return false, false
+	}
+
+	return node.Value.BoolValue, true
+}
+
+type partialEvaluator struct {
+	evaluator *Evaluator
+	known     D
+}
+
+// fold returns the residual of a node in a boolean position: the root of a rule or an operand of
+// a logical operator.
+func (p *partialEvaluator) fold(node *ASTNode) (*ASTNode, error) {
+	switch node.Type {
+	case NodeBinaryOp:
+		if node.Operator == AND || node.Operator == OR {
+			return p.foldLogical(node)
+		}
+
+		return p.foldOperation(node)
+
+	case NodeUnaryOp:
+		if node.Operator == NOT {
+			return p.foldNot(node)
+		}
+
+		return p.foldOperation(node)
+
+	case NodeConditional, NodeLet, NodeIdentifier, NodeProperty, NodeLiteral, NodeArray, NodeParameter, NodeReference,
+		NodeVariable, NodeCall:
+		return p.foldOperation(node)
+
+	case NodeError:
+		return nil, ErrInvalidSyntax
+	}
+
+	return nil, ErrInvalidNode
+}
+
+// foldLogical folds an and/or whose decided operands either short-circuit it or drop out.
+func (p *partialEvaluator) foldLogical(node *ASTNode) (*ASTNode, error) {
+	// The value that decides the operator on its own: false for and, true for or.
+	deciding := node.Operator == OR
+
+	left, err := p.fold(node.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := booleanConstant(left); ok {
+		if value == deciding {
+			return left, nil
+		}
+
+		return p.fold(node.Right)
+	}
+
+	right, err := p.fold(node.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := booleanConstant(right); ok {
+		if value == deciding {
+			return right, nil
+		}
+
+		return left, nil
+	}
+
+	return NewBinaryOpNode(node.Operator, left, right), nil
+}
+
+func (p *partialEvaluator) foldNot(node *ASTNode) (*ASTNode, error) {
+	operand, err := p.fold(node.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := booleanConstant(operand); ok {
+		return NewBooleanLiteralNode(!value), nil
+	}
+
+	return NewUnaryOpNode(NOT, operand), nil
+}
+
+// foldOperation decides a comparison, presence check or bare operand when every attribute it
+// reads is known. Otherwise the known attributes it compares are replaced by literals.
+func (p *partialEvaluator) foldOperation(node *ASTNode) (*ASTNode, error) {
+	if p.isKnown(node) {
+		value, err := p.evaluator.Evaluate(node, p.known)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewBooleanLiteralNode(value), nil
+	}
+
+	if node.Type != NodeBinaryOp {
+		return node, nil
+	}
+
+	membership := node.Operator == IN || node.Operator == NOT_IN
+
+	left := p.substitute(node.Left, false)
+	right := p.substitute(node.Right, membership)
+
+	if left == node.Left && right == node.Right {
+		return node, nil
+	}
+
+	return NewBinaryOpNode(node.Operator, left, right), nil
+}
+
+// isKnown reports whether every attribute read by an operation is present in the known context.
+func (p *partialEvaluator) isKnown(node *ASTNode) bool {
+	switch node.Type {
+	case NodeBinaryOp:
+		// dl and dg read the clock, so like now() they are decided when the residual is evaluated
+		if node.Operator == DL || node.Operator == DG {
+			return false
+		}
+
+		return p.isKnown(node.Left) && p.isKnown(node.Right)
+	case NodeUnaryOp:
+		return p.isKnown(node.Left)
+	case NodeIdentifier, NodeProperty:
+		_, found := p.lookup(node)
+		return found
+	case NodeConditional:
+		for _, child := range node.Children {
+			if !p.isKnown(child) {
+				return false
+			}
+		}
+
+		return true
+	case NodeLet:
+		return p.isKnown(node.Left) && p.isKnown(node.Right)
+	case NodeLiteral, NodeArray:
+		return true
+	case NodeVariable:
+		// Known when the value it is bound to is, which the enclosing let checks
+		return true
+	case NodeCall:
+		// now() is read when the residual is evaluated, not when it is folded
+		return false
+	case NodeParameter, NodeReference, NodeError:
+		return false
+	}
+
+	return false
+}
+
+// substitute replaces a known attribute operand by a literal when the evaluator would read the
+// literal exactly as it reads the context value. Arrays are only substituted as in operands.
+func (p *partialEvaluator) substitute(operand *ASTNode, allowArray bool) *ASTNode {
+	if operand.Type == NodeBinaryOp && isArithmeticOperator(operand.Operator) {
+		return p.substituteArithmetic(operand)
+	}
+
+	value, found := p.lookup(operand)
+	if !found {
+		return operand
+	}
+
+	literal, ok := literalValue(value, allowArray)
+	if !ok {
+		return operand
+	}
+
+	return &ASTNode{Type: NodeLiteral, Value: literal}
+}
+
+// substituteArithmetic substitutes the known attributes of an arithmetic expression and computes
+// it when every operand has become a literal.
+func (p *partialEvaluator) substituteArithmetic(node *ASTNode) *ASTNode {
+	left := p.substitute(node.Left, false)
+	right := p.substitute(node.Right, false)
+
+	if left == node.Left && right == node.Right {
+		return node
+	}
+
+	residual := NewBinaryOpNode(node.Operator, left, right)
+	if left.Type != NodeLiteral || right.Type != NodeLiteral {
+		return residual
+	}
+
+	value, err := p.evaluator.EvaluateValue(residual, nil)
+	if err != nil || (!value.IsInt && (math.IsNaN(value.NumValue) || math.IsInf(value.NumValue, 0))) {
+		return residual
+	}
+
+	return &ASTNode{Type: NodeLiteral, Value: value}
+}
+
+func (p *partialEvaluator) lookup(node *ASTNode) (any, bool) {
+	path, ok := attributePath(node)
+	if !ok {
+		return nil, false
+	}
+
+	return MapResolver(p.known).Resolve(path)
+}
+
+// literalValue converts a context value into the literal the evaluator treats identically, using
+// the same numeric conversions as setResultFromAny.
+func literalValue(value any, allowArray bool) (Value, bool) {
+	switch v := value.(type) {
+	case bool:
+		return Value{Type: ValueBoolean, BoolValue: v}, true
+	case string:
+		return Value{Type: ValueString, StrValue: v}, true
+	case int:
+		return integerValue(int64(v)), true
+	case int8:
+		return integerValue(int64(v)), true
+	case int16:
+		return integerValue(int64(v)), true
+	case int32:
+		return integerValue(int64(v)), true
+	case int64:
+		return integerValue(v), true
+	case uint:
+		return unsignedValue(uint64(v)), true
+	case uint8:
+		return unsignedValue(uint64(v)), true
+	case uint16:
+		return unsignedValue(uint64(v)), true
+	case uint32:
+		return unsignedValue(uint64(v)), true
+	case uint64:
+		return unsignedValue(v), true
+	case float32:
+		return floatValue(float64(v))
+	case float64:
+		return floatValue(v)
+	case []any:
+		if !allowArray {
+			return Value{}, false
+		}
+
+		elements := make([]Value, len(v))
+
+		for i, item := range v {
+			element, ok := literalValue(item, false)
+			if !ok {
+				return Value{}, false
+			}
+
+			elements[i] = element
+		}
+
+		return Value{Type: ValueArray, ArrValue: elements}, true
+	}
+
+	return Value{}, false
+}
+
+func integerValue(v int64) Value {
+	return Value{Type: ValueNumber, NumValue: float64(v), IntValue: v, IsInt: true}
+}
+
+func unsignedValue(v uint64) Value {
+	value := Value{Type: ValueNumber, NumValue: float64(v), IntValue: maxSafeInteger, IsInt: true}
+	if v <= uint64(maxSafeInteger) {
+		value.IntValue = int64(v)
+	}
+
+	return value
+}
+
+// floatValue rejects values such as NaN that have no literal syntax, keeping the residual printable.
+func floatValue(v float64) (Value, bool) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return Value{}, false
+	}
+
+	return Value{Type: ValueNumber, NumValue: v}, true
+}
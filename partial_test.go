@@ -0,0 +1,154 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialEvaluate(t *testing.T) {
+	t.Run("Residuals", testPartialEvaluateResiduals)
+	t.Run("Equivalence", testPartialEvaluateEquivalence)
+	t.Run("Unrepresentable", testPartialEvaluateUnrepresentable)
+	t.Run("Errors", testPartialEvaluateErrors)
+}
+
+func testPartialEvaluateResiduals(t *testing.T) {
+	known := D{
+		"user": D{"age": 30, "tier": "gold", "country": "BR", "beta": false, "joined": "2024-01-31T10:00:00Z"},
+		"max":  uint64(500),
+		"skus": []any{"a1", "b2"},
+	}
+
+	tests := []struct {
+		rule     string
+		residual string
+	}{
+		{`user.age ge 18 and order.total gt 100`, `order.total gt 100`},
+		{`user.age lt 18 and order.total gt 100`, `false`},
+		{`user.tier eq "gold" or order.total gt 100`, `true`},
+		{`user.tier eq "silver" or order.total gt 100`, `order.total gt 100`},
+		{`order.total gt 100 and user.beta`, `false`},
+		{`order.total gt 100 and not user.beta`, `order.total gt 100`},
+		{`order.total le max and order.sku in skus`, `order.total le 500 and order.sku in ["a1", "b2"]`},
+		{`(user.country eq "BR" and order.currency eq "BRL") or order.total lt user.age`,
+			`order.currency eq "BRL" or order.total lt 30`},
+		{`not (user.age gt 21 and order.express)`, `not order.express`},
+		{`user.age pr and order.id pr`, `order.id pr`},
+		{`user.email pr or user.age gt 65`, `user.email pr`},
+		{`order.total gt 10 or order.total lt 5`, `order.total gt 10 or order.total lt 5`},
+		{`order.total gt user.age * 2 + 1`, `order.total gt 61`},
+		{`order.total - user.age ge max / 4`, `order.total - 30 ge 125`},
+		{`user.age * 2 gt 50 and order.total pr`, `order.total pr`},
+		{`order.placed af user.joined + P1M`, `order.placed af "2024-02-29T10:00:00Z"`},
+		{`user.joined af now() - 7d`, `"2024-01-31T10:00:00Z" af now() - 7d`},
+		{`user.joined dl 30 and user.age ge 18`, `"2024-01-31T10:00:00Z" dl 30`},
+		{`user.joined dg P1M or order.total gt 100`, `"2024-01-31T10:00:00Z" dg P1M or order.total gt 100`},
+	}
+
+	engine := NewEngine()
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			residual, err := engine.PartialEvaluate(tt.rule, known)
+			require.NoError(t, err)
+			require.Equal(t, tt.residual, Format(residual.AST))
+
+			reparsed, err := ParseRule(Format(residual.AST))
+			require.NoError(t, err, "the residual is a valid rule")
+			require.Equal(t, tt.residual, Format(reparsed))
+		})
+	}
+
+	residual, err := engine.PartialEvaluate(`user.joined dl 30`, known)
+	require.NoError(t, err)
+
+	_, ok := residual.Constant()
+	require.False(t, ok, "relative-time comparisons are not frozen at partial evaluation time")
+
+	residual, err = engine.PartialEvaluate(`user.age ge 18 and user.tier eq "gold"`, known)
+	require.NoError(t, err)
+
+	value, ok := residual.Constant()
+	require.True(t, ok)
+	require.True(t, value)
+
+	residual, err = engine.PartialEvaluate(`user.age ge 18 and order.total gt 100`, known)
+	require.NoError(t, err)
+
+	_, ok = residual.Constant()
+	require.False(t, ok)
+
+	result, err := engine.EvaluateCompiled(residual, D{"order": D{"total": 150}})
+	require.NoError(t, err)
+	require.True(t, result)
+}
+
+func testPartialEvaluateEquivalence(t *testing.T) {
+	rules := []string{
+		`user.age ge 18 and order.total gt 100`,
+		`user.vip or (order.total gt user.limit and order.items in [1, 2, 3])`,
+		`not (user.name co "bot") and order.coupon pr`,
+		`user.age gt order.min_age or user.name eq order.name`,
+		`order.total ne user.limit and not user.vip`,
+		`user.tags in order.tags or order.id in user.ids`,
+		`user.name sw "A" or order.total lt 0 or user.missing pr`,
+	}
+
+	users := []D{
+		{"age": 17, "name": "Ann", "vip": false, "limit": 100, "ids": []any{1, 2}},
+		{"age": 40, "name": "bot-7", "vip": true, "limit": 250.5, "tags": "x"},
+		{"age": int64(21), "name": "Bob", "limit": uint8(3), "ids": []any{"7"}},
+		{},
+	}
+
+	orders := []D{
+		{"total": 150, "items": 2, "coupon": "X", "min_age": 18, "name": "Ann", "id": 2},
+		{"total": 100, "items": 9, "min_age": 50, "id": "7", "tags": []any{"x"}},
+		{"total": 3.5, "name": "Bob"},
+		{},
+	}
+
+	engine := NewEngine()
+
+	for _, rule := range rules {
+		for _, user := range users {
+			residual, err := engine.PartialEvaluate(rule, D{"user": user})
+			require.NoError(t, err)
+
+			for _, order := range orders {
+				expected, err := engine.Evaluate(rule, D{"user": user, "order": order})
+				require.NoError(t, err)
+
+				actual, err := engine.EvaluateCompiled(residual, D{"order": order})
+				require.NoError(t, err)
+				require.Equal(t, expected, actual, "rule=%q user=%v order=%v residual=%q",
+					rule, user, order, Format(residual.AST))
+			}
+		}
+	}
+}
+
+func testPartialEvaluateUnrepresentable(t *testing.T) {
+	signup := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine := NewEngine()
+
+	residual, err := engine.PartialEvaluate(`user.signup af "2023-01-01T00:00:00Z"`, D{"user": D{"signup": signup}})
+	require.NoError(t, err)
+	require.Equal(t, "true", Format(residual.AST), "fully known conditions are still decided")
+
+	residual, err = engine.PartialEvaluate(`order.date af user.signup`, D{"user": D{"signup": signup}})
+	require.NoError(t, err)
+	require.Equal(t, "order.date af user.signup", Format(residual.AST))
+}
+
+func testPartialEvaluateErrors(t *testing.T) {
+	engine := NewEngine()
+
+	_, err := engine.PartialEvaluate(`a eq`, D{})
+	require.Error(t, err)
+
+	_, err = engine.PartialEvaluateCompiled(&CompiledRule{AST: NewErrorNode(0, 1)}, D{})
+	require.ErrorIs(t, err, ErrInvalidSyntax)
+}
@@ -0,0 +1,219 @@
+package rule
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/puzpuzpuz/xsync/v4"
+)
+
+// Profiler counts how often each boolean sub-expression of a rule is evaluated and how often it is
+// true. Sub-expressions are the rule itself and the operands of and, or and not; the operands of
+// comparisons are values, not sub-expressions. Counters are atomic, so profiling concurrent
+// evaluations takes no locks. A profiler keeps every rule it has seen until Reset is called.
+type Profiler struct {
+	rules *xsync.Map[*CompiledRule, *ruleProfile]
+}
+
+// ruleProfile holds the counters of one compiled rule. The nodes map is built once and only read
+// afterwards, so evaluations can share it.
+type ruleProfile struct {
+	compiled *CompiledRule
+	nodes    map[*ASTNode]*nodeCounters
+}
+
+type nodeCounters struct {
+	evaluations atomic.Int64
+	matches     atomic.Int64
+}
+
+// Span is the rune range of a node in the rule source, as in ASTNode.Start and ASTNode.End.
+type Span struct {
+	Start int
+	End   int
+}
+
+// NodeProfile reports the counters of one sub-expression.
+type NodeProfile struct {
+	Span Span
+	// Expression is the source text of the sub-expression.
+	Expression  string
+	Evaluations int64
+	Matches     int64
+}
+
+// Rate returns the fraction of evaluations in which the sub-expression was true. Operands skipped
+// by short-circuiting are not evaluated, so the rate of a later operand is conditional on the
+// operands before it.
+func (p NodeProfile) Rate() float64 {
+	if p.Evaluations == 0 {
+		return 0
+	}
+
+	return float64(p.Matches) / float64(p.Evaluations)
+}
+
+// RuleProfile reports the sub-expressions of one rule, keyed by their source span.
+type RuleProfile struct {
+	Rule  string
+	Nodes map[Span]NodeProfile
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{rules: xsync.NewMap[*CompiledRule, *ruleProfile]()}
+}
+
+// SetProfiler makes the engine record every evaluation in profiler. Passing nil disables
+// profiling. Profiling does not change compiled rules, so the rule cache is kept.
+func (e *Engine) SetProfiler(profiler *Profiler) {
+	e.profiler.Store(profiler)
+}
+
+// profile returns the counters evaluations of compiled record into, or nil when profiling is off.
+func (e *Engine) profile(compiled *CompiledRule) *ruleProfile {
+	profiler := e.profiler.Load()
+	if profiler == nil {
+		return nil
+	}
+
+	return profiler.rule(compiled)
+}
+
+// Report returns the counters of every profiled rule, sorted by rule. Rules compiled more than
+// once, for example after the cache was cleared, are merged. Nodes without a source span, such as
+// the chains an Optimizer rebuilds, are left out.
+func (p *Profiler) Report() []RuleProfile {
+	byRule := make(map[string]RuleProfile)
+
+	p.rules.Range(func(_ *CompiledRule, profile *ruleProfile) bool {
+		source := profile.compiled.source
+		if source == "" {
+			source = Format(profile.compiled.AST)
+		}
+
+		report, found := byRule[source]
+		if !found {
+			report = RuleProfile{Rule: source, Nodes: make(map[Span]NodeProfile)}
+			byRule[source] = report
+		}
+
+		for node, counters := range profile.nodes {
+			if !node.HasSpan() {
+				continue
+			}
+
+			span := Span{Start: node.Start, End: node.End}
+
+			entry, found := report.Nodes[span]
+			if !found {
+				entry = NodeProfile{Span: span, Expression: profile.expression(node)}
+			}
+
+			entry.Evaluations += counters.evaluations.Load()
+			entry.Matches += counters.matches.Load()
+			report.Nodes[span] = entry
+		}
+
+		return true
+	})
+
+	reports := make([]RuleProfile, 0, len(byRule))
+	for _, report := range byRule {
+		reports = append(reports, report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Rule < reports[j].Rule
+	})
+
+	return reports
+}
+
+// Selectivity converts the profiled predicates into statistics an Optimizer can order operands by.
+// Identical predicates in different rules are combined.
+func (p *Profiler) Selectivity() *Selectivity {
+	selectivity := NewSelectivity()
+
+	p.rules.Range(func(_ *CompiledRule, profile *ruleProfile) bool {
+		for node, counters := range profile.nodes {
+			if isConnective(node) {
+				continue
+			}
+
+			selectivity.record(node, counters.evaluations.Load(), counters.matches.Load())
+		}
+
+		return true
+	})
+
+	return selectivity
+}
+
+// Reset discards every counter and forgets the profiled rules.
+func (p *Profiler) Reset() {
+	p.rules.Clear()
+}
+
+func (p *Profiler) rule(compiled *CompiledRule) *ruleProfile {
+	profile, _ := p.rules.LoadOrCompute(compiled, func() (*ruleProfile, bool) {
+		profile := &ruleProfile{compiled: compiled, nodes: make(map[*ASTNode]*nodeCounters)}
+		profile.add(compiled.AST)
+
+		return profile, false
+	})
+
+	return profile
+}
+
+// add creates counters for a node in boolean position and the boolean operands below it.
+func (r *ruleProfile) add(node *ASTNode) {
+	if node == nil {
+		return
+	}
+
+	r.nodes[node] = &nodeCounters{}
+
+	if isConnective(node) {
+		r.add(node.Left)
+		r.add(node.Right)
+	}
+}
+
+func (r *ruleProfile) record(node *ASTNode, matched bool) {
+	counters, found := r.nodes[node]
+	if !found {
+		return
+	}
+
+	counters.evaluations.Add(1)
+
+	if matched {
+		counters.matches.Add(1)
+	}
+}
+
+// expression returns the source text of a node, or its canonical form when the rule was not
+// compiled from source.
+func (r *ruleProfile) expression(node *ASTNode) string {
+	source := []rune(r.compiled.source)
+	if node.End > len(source) {
+		return Format(node)
+	}
+
+	return string(source[node.Start:node.End])
+}
+
+// isConnective reports whether a node combines boolean operands: and, or and not.
+func isConnective(node *ASTNode) bool {
+	switch node.Type {
+	case NodeBinaryOp:
+		return node.Operator == AND || node.Operator == OR
+	case NodeUnaryOp:
+		return node.Operator == NOT
+	case NodeConditional, NodeLet, NodeIdentifier, NodeLiteral, NodeArray, NodeProperty, NodeParameter, NodeReference,
+		NodeVariable, NodeCall, NodeError:
+		return false
+	}
+
+	return false
+}
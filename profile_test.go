@@ -0,0 +1,195 @@
+package rule
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiler(t *testing.T) {
+	t.Run("Report", testProfilerReport)
+	t.Run("Concurrent", testProfilerConcurrent)
+	t.Run("EvaluationPaths", testProfilerEvaluationPaths)
+	t.Run("Selectivity", testProfilerSelectivity)
+	t.Run("Disabled", testProfilerDisabled)
+	t.Run("Definitions", testProfilerDefinitions)
+}
+
+func testProfilerReport(t *testing.T) {
+	engine := NewEngine()
+	profiler := NewProfiler()
+	engine.SetProfiler(profiler)
+
+	rule := `age ge 18 and not (country eq "US" or vip)`
+
+	for _, context := range []D{
+		{"age": 30, "country": "BR"},
+		{"age": 30, "country": "US"},
+		{"age": 30, "country": "BR", "vip": true},
+		{"age": 10, "country": "BR"},
+	} {
+		_, err := engine.Evaluate(rule, context)
+		require.NoError(t, err)
+	}
+
+	report := profiler.Report()
+	require.Len(t, report, 1)
+	require.Equal(t, rule, report[0].Rule)
+
+	expected := map[string][2]int64{
+		rule:                           {4, 1},
+		`age ge 18`:                    {4, 3},
+		`not (country eq "US" or vip)`: {3, 1},
+		`country eq "US" or vip`:       {3, 2},
+		`country eq "US"`:              {3, 1},
+		`vip`:                          {2, 1},
+	}
+
+	require.Len(t, report[0].Nodes, len(expected))
+
+	for span, node := range report[0].Nodes {
+		require.Equal(t, span, node.Span)
+		require.Equal(t, node.Expression, string([]rune(rule)[span.Start:span.End]))
+
+		counts, found := expected[node.Expression]
+		require.True(t, found, node.Expression)
+		require.Equal(t, counts, [2]int64{node.Evaluations, node.Matches}, node.Expression)
+	}
+
+	profiler.Reset()
+	require.Empty(t, profiler.Report())
+}
+
+func testProfilerConcurrent(t *testing.T) {
+	engine := NewEngine()
+	profiler := NewProfiler()
+	engine.SetProfiler(profiler)
+
+	const workers, iterations = 8, 500
+
+	var wg sync.WaitGroup
+
+	for worker := range workers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for range iterations {
+				if _, err := engine.Evaluate(`x eq 1 or y eq 1`, D{"x": worker % 2, "y": 1}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	nodes := profiler.Report()[0].Nodes
+	require.Equal(t, int64(workers*iterations), nodes[Span{0, 6}].Evaluations)
+	require.Equal(t, int64(workers*iterations/2), nodes[Span{0, 6}].Matches)
+	require.Equal(t, int64(workers*iterations/2), nodes[Span{10, 16}].Evaluations)
+	require.InDelta(t, 1.0, nodes[Span{10, 16}].Rate(), 1e-9)
+}
+
+func testProfilerEvaluationPaths(t *testing.T) {
+	engine := NewEngine()
+	profiler := NewProfiler()
+	engine.SetProfiler(profiler)
+
+	compiled, err := engine.CompileRule(`a eq 1`)
+	require.NoError(t, err)
+
+	_, err = engine.EvaluateCompiled(compiled, D{"a": 1})
+	require.NoError(t, err)
+
+	_, err = engine.EvaluateCompiledContext(t.Context(), compiled, D{"a": 2})
+	require.NoError(t, err)
+
+	_, err = engine.EvaluateCompiledResolver(compiled, ResolverFunc(func([]string) (any, bool) {
+		return 1, true
+	}))
+	require.NoError(t, err)
+
+	// Recompiling after the cache is cleared merges into the same report entry.
+	engine.ClearCache()
+
+	_, err = engine.Evaluate(`a eq 1`, D{"a": 1})
+	require.NoError(t, err)
+
+	value, err := engine.EvaluateValue(`a eq 1`, D{"a": 3})
+	require.NoError(t, err)
+	require.Equal(t, Value{Type: ValueBoolean, BoolValue: false}, value)
+
+	node := profiler.Report()[0].Nodes[Span{0, 6}]
+	require.Equal(t, NodeProfile{Span: Span{0, 6}, Expression: "a eq 1", Evaluations: 5, Matches: 3}, node)
+}
+
+func testProfilerSelectivity(t *testing.T) {
+	engine := NewEngine()
+	profiler := NewProfiler()
+	engine.SetProfiler(profiler)
+
+	for i := range 100 {
+		_, err := engine.Evaluate(`common eq 1 and rare eq 1`, D{"common": 1, "rare": i % 10})
+		require.NoError(t, err)
+	}
+
+	selectivity := profiler.Selectivity()
+
+	rate, observations := selectivity.Rate(`rare eq 1`)
+	require.InDelta(t, 0.1, rate, 1e-9)
+	require.Equal(t, int64(100), observations)
+
+	_, observations = selectivity.Rate(`common eq 1 and rare eq 1`)
+	require.Zero(t, observations, "only predicates are converted")
+
+	engine.SetOptimizer(&Optimizer{Selectivity: selectivity})
+
+	compiled, err := engine.CompileRule(`common eq 1 and rare eq 1`)
+	require.NoError(t, err)
+	require.Equal(t, `rare eq 1 and common eq 1`, Format(compiled.AST))
+}
+
+func testProfilerDisabled(t *testing.T) {
+	engine := NewEngine()
+	profiler := NewProfiler()
+
+	engine.SetProfiler(profiler)
+	engine.SetProfiler(nil)
+
+	result, err := engine.Evaluate(`a eq 1`, D{"a": 1})
+	require.NoError(t, err)
+	require.True(t, result)
+	require.Empty(t, profiler.Report())
+}
+
+func testProfilerDefinitions(t *testing.T) {
+	engine := NewEngine()
+	require.NoError(t, engine.Define("adult", `age ge 18 and kyc eq "ok"`))
+
+	profiler := NewProfiler()
+	engine.SetProfiler(profiler)
+
+	rule := `@adult and x eq 1`
+
+	_, err := engine.Evaluate(rule, D{"age": 30, "kyc": "ok", "x": 1})
+	require.NoError(t, err)
+
+	report := profiler.Report()
+	require.Len(t, report, 1)
+
+	expected := map[string]NodeProfile{
+		rule:     {Span: Span{0, 17}, Expression: rule, Evaluations: 1, Matches: 1},
+		`@adult`: {Span: Span{0, 6}, Expression: `@adult`, Evaluations: 1, Matches: 1},
+		`x eq 1`: {Span: Span{11, 17}, Expression: `x eq 1`, Evaluations: 1, Matches: 1},
+	}
+
+	require.Len(t, report[0].Nodes, len(expected), "nodes inside the definition are not reported")
+
+	for _, node := range report[0].Nodes {
+		require.Equal(t, expected[node.Expression], node)
+	}
+}
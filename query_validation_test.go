@@ -279,7 +279,7 @@ func testEdgeCaseValidation(t *testing.T) {
 			errorType:   ErrTrailingTokens,
 		},
 		{
-			name:        "Field with hyphen (tokenized as separate)",
+			name:        "Field with hyphen (parsed as subtraction)",
 			query:       `field-name eq "test"`,
 			expectError: true,
 		},
@@ -0,0 +1,113 @@
+package rule
+
+import (
+	"context"
+	"strings"
+)
+
+// Resolver supplies attribute values on demand, for contexts that are expensive to materialise.
+// Resolve receives the segments of an attribute path (user.age is ["user", "age"]) and reports
+// whether the attribute exists. It is only called for attributes the evaluation actually reaches,
+// at most once per path and evaluation.
+type Resolver interface {
+	Resolve(path []string) (any, bool)
+}
+
+// ResolverFunc adapts a function to the Resolver interface.
+type ResolverFunc func(path []string) (any, bool)
+
+func (f ResolverFunc) Resolve(path []string) (any, bool) {
+	return f(path)
+}
+
+// MapResolver adapts a context map to the Resolver interface. The evaluator reads it as a plain
+// context, so evaluating with a MapResolver is as fast as Evaluate.
+type MapResolver D
+
+func (m MapResolver) Resolve(path []string) (any, bool) {
+	current := D(m)
+
+	for i, key := range path {
+		value, found := current[key]
+		if !found {
+			return nil, false
+		}
+
+		if i == len(path)-1 {
+			return value, true
+		}
+
+		nested, isMap := value.(map[string]any)
+		if !isMap {
+			return nil, false
+		}
+
+		current = nested
+	}
+
+	return nil, false
+}
+
+// EvaluateResolver evaluates a rule like Evaluate, reading attributes from a resolver instead of
+// a context map.
+func (e *Engine) EvaluateResolver(rule string, resolver Resolver) (bool, error) {
+	compiled, err := e.CompileRule(rule)
+	if err != nil {
+		return false, err
+	}
+
+	return e.EvaluateCompiledResolver(compiled, resolver)
+}
+
+// EvaluateCompiledResolver is EvaluateResolver for a pre-compiled rule.
+func (e *Engine) EvaluateCompiledResolver(compiled *CompiledRule, resolver Resolver) (bool, error) {
+	if m, ok := resolver.(MapResolver); ok {
+		return e.EvaluateCompiled(compiled, D(m))
+	}
+
+	evaluator := Evaluator{resolution: &resolution{resolver: resolver}, profile: e.profile(compiled)}
+
+	if limits := e.limits.Load(); limits != nil && limits.MaxSteps > 0 {
+		evaluator.meter = &meter{ctx: context.Background(), maxSteps: limits.MaxSteps}
+	}
+
+	return evaluator.Evaluate(compiled.AST, nil)
+}
+
+// resolution memoises the attributes resolved during a single evaluation.
+type resolution struct {
+	resolver Resolver
+	memo     map[string]resolved
+}
+
+type resolved struct {
+	value any
+	found bool
+}
+
+// resolve returns the value of an identifier or property node, asking the resolver only the first
+// time a path is reached.
+func (r *resolution) resolve(node *ASTNode) (any, bool) {
+	path, ok := attributePath(node)
+	if !ok {
+		return nil, false
+	}
+
+	key := node.Value.StrValue
+	if node.Type == NodeProperty {
+		key = strings.Join(path, ".")
+	}
+
+	if entry, cached := r.memo[key]; cached {
+		return entry.value, entry.found
+	}
+
+	if r.memo == nil {
+		r.memo = make(map[string]resolved)
+	}
+
+	value, found := r.resolver.Resolve(path)
+	r.memo[key] = resolved{value: value, found: found}
+
+	return value, found
+}
@@ -0,0 +1,135 @@
+package rule
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingResolver serves attributes from a map and records every path it is asked for.
+type countingResolver struct {
+	values D
+	calls  []string
+}
+
+func (r *countingResolver) Resolve(path []string) (any, bool) {
+	r.calls = append(r.calls, strings.Join(path, "."))
+	return MapResolver(r.values).Resolve(path)
+}
+
+func TestResolver(t *testing.T) {
+	t.Run("Lazy", testResolverLazy)
+	t.Run("Memoised", testResolverMemoised)
+	t.Run("Presence", testResolverPresence)
+	t.Run("MatchesEvaluate", testResolverMatchesEvaluate)
+	t.Run("MapResolverFastPath", testMapResolverFastPath)
+	t.Run("StepBudget", testResolverStepBudget)
+}
+
+func testResolverLazy(t *testing.T) {
+	engine := NewEngine()
+	resolver := &countingResolver{values: D{"user": D{"vip": true}, "risk": D{"score": 90}}}
+
+	result, err := engine.EvaluateResolver(`user.vip eq true or risk.score lt 50`, resolver)
+	require.NoError(t, err)
+	require.True(t, result)
+	require.Equal(t, []string{"user.vip"}, resolver.calls, "short-circuited attributes are never resolved")
+
+	resolver.calls = nil
+
+	result, err = engine.EvaluateResolver(`user.vip eq false and risk.score lt 50`, resolver)
+	require.NoError(t, err)
+	require.False(t, result)
+	require.Equal(t, []string{"user.vip"}, resolver.calls)
+}
+
+func testResolverMemoised(t *testing.T) {
+	engine := NewEngine()
+	resolver := &countingResolver{values: D{"order": D{"total": 120}}}
+
+	rule := `order.total gt 100 and order.total lt 500 and not (order.total eq 300) and order.missing ne 1`
+
+	result, err := engine.EvaluateResolver(rule, resolver)
+	require.NoError(t, err)
+	require.False(t, result)
+	require.Equal(t, []string{"order.total", "order.missing"}, resolver.calls,
+		"each path is resolved once per evaluation, including missing ones")
+
+	_, err = engine.EvaluateResolver(rule, resolver)
+	require.NoError(t, err)
+	require.Len(t, resolver.calls, 4, "memoisation does not outlive the evaluation")
+}
+
+func testResolverPresence(t *testing.T) {
+	engine := NewEngine()
+	resolver := ResolverFunc(func(path []string) (any, bool) {
+		return nil, path[0] == "present"
+	})
+
+	result, err := engine.EvaluateResolver(`present pr and present.nested pr and not (absent pr)`, resolver)
+	require.NoError(t, err)
+	require.True(t, result)
+
+	result, err = engine.EvaluateResolver(`present eq 1`, resolver)
+	require.NoError(t, err)
+	require.False(t, result, "a nil value is not equal to 1")
+}
+
+func testResolverMatchesEvaluate(t *testing.T) {
+	context := D{
+		"age":    30,
+		"name":   "Ann",
+		"tags":   []any{"a", "b"},
+		"user":   D{"country": "BR", "limits": D{"daily": 500.5}},
+		"flag":   true,
+		"scalar": "not a map",
+	}
+
+	rules := []string{
+		`age ge 18 and name sw "A"`,
+		`"a" in tags and user.country in ["BR", "PT"]`,
+		`user.limits.daily gt 500 or missing pr`,
+		`flag and not (user.country eq "US")`,
+		`scalar.nested pr or scalar.nested eq 1`,
+		`user.limits pr and user.limits.monthly pr`,
+	}
+
+	engine := NewEngine()
+
+	for _, rule := range rules {
+		expected, err := engine.Evaluate(rule, context)
+		require.NoError(t, err)
+
+		actual, err := engine.EvaluateResolver(rule, &countingResolver{values: context})
+		require.NoError(t, err)
+		require.Equal(t, expected, actual, rule)
+
+		actual, err = engine.EvaluateResolver(rule, MapResolver(context))
+		require.NoError(t, err)
+		require.Equal(t, expected, actual, rule)
+	}
+}
+
+func testMapResolverFastPath(t *testing.T) {
+	engine := NewEngine()
+
+	compiled, err := engine.CompileRule(`user.age ge 18 and user.country eq "BR"`)
+	require.NoError(t, err)
+
+	resolver := MapResolver(D{"user": D{"age": 30, "country": "BR"}})
+
+	allocs := testing.AllocsPerRun(100, func() {
+		result, _ := engine.EvaluateCompiledResolver(compiled, resolver)
+		require.True(t, result)
+	})
+	require.Zero(t, allocs)
+}
+
+func testResolverStepBudget(t *testing.T) {
+	engine := NewEngine()
+	engine.SetLimits(Limits{MaxSteps: 3})
+
+	_, err := engine.EvaluateResolver(`a eq 1 and b eq 2`, &countingResolver{values: D{"a": 1}})
+	require.ErrorIs(t, err, ErrStepBudgetExceeded)
+}
@@ -0,0 +1,102 @@
+package rule
+
+// RuleSet is a named collection of rules compiled by one engine. Rules keep the order in which
+// they were first added. A RuleSet is not safe for concurrent modification, but once built it may
+// be evaluated from many goroutines.
+type RuleSet struct {
+	engine *Engine
+	names  []string
+	rules  map[string]*CompiledRule
+}
+
+// NewRuleSet returns an empty rule set whose rules are compiled by the engine.
+func (e *Engine) NewRuleSet() *RuleSet {
+	return &RuleSet{
+		engine: e,
+		rules:  make(map[string]*CompiledRule),
+	}
+}
+
+// Add compiles a rule and stores it under name, replacing any rule with the same name.
+func (s *RuleSet) Add(name, rule string) error {
+	compiled, err := s.engine.CompileRule(rule)
+	if err != nil {
+		return err
+	}
+
+	s.AddCompiled(name, compiled)
+
+	return nil
+}
+
+// AddCompiled stores a compiled rule under name, replacing any rule with the same name.
+func (s *RuleSet) AddCompiled(name string, compiled *CompiledRule) {
+	if _, exists := s.rules[name]; !exists {
+		s.names = append(s.names, name)
+	}
+
+	s.rules[name] = compiled
+}
+
+// Remove deletes the rule stored under name, if any.
+func (s *RuleSet) Remove(name string) {
+	if _, exists := s.rules[name]; !exists {
+		return
+	}
+
+	delete(s.rules, name)
+
+	for i, existing := range s.names {
+		if existing == name {
+			s.names = append(s.names[:i], s.names[i+1:]...)
+			break
+		}
+	}
+}
+
+// Rule returns the rule stored under name.
+func (s *RuleSet) Rule(name string) (*CompiledRule, bool) {
+	compiled, exists := s.rules[name]
+	return compiled, exists
+}
+
+// Names returns the names of the rules in the order they were added.
+func (s *RuleSet) Names() []string {
+	return append([]string(nil), s.names...)
+}
+
+// Len returns the number of rules in the set.
+func (s *RuleSet) Len() int {
+	return len(s.names)
+}
+
+// Evaluate returns the names of the rules that match the context, in the order they were added.
+func (s *RuleSet) Evaluate(context D) ([]string, error) {
+	var matches []string
+
+	for _, name := range s.names {
+		matched, err := s.engine.EvaluateCompiled(s.rules[name], context)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			matches = append(matches, name)
+		}
+	}
+
+	return matches, nil
+}
+
+// Attributes returns the union of the attributes read by every rule in the set, sorted by path,
+// with the operators and literal types used by all rules. It tells which context paths must be
+// provided to evaluate the whole set.
+func (s *RuleSet) Attributes() []Attribute {
+	set := attributeSet{}
+
+	for _, name := range s.names {
+		set.merge(s.rules[name].Attributes())
+	}
+
+	return set.sorted()
+}
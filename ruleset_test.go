@@ -0,0 +1,71 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleSet(t *testing.T) {
+	t.Run("Evaluate", testRuleSetEvaluate)
+	t.Run("Replace", testRuleSetReplace)
+	t.Run("Remove", testRuleSetRemove)
+	t.Run("CompileError", testRuleSetCompileError)
+}
+
+func testRuleSetEvaluate(t *testing.T) {
+	set := NewEngine().NewRuleSet()
+
+	require.NoError(t, set.Add("vip", `tier eq "vip"`))
+	require.NoError(t, set.Add("adult", `age ge 18`))
+	require.NoError(t, set.Add("teen", `age lt 18`))
+
+	matches, err := set.Evaluate(D{"tier": "vip", "age": 30})
+	require.NoError(t, err)
+	require.Equal(t, []string{"vip", "adult"}, matches)
+
+	matches, err = set.Evaluate(D{})
+	require.NoError(t, err)
+	require.Empty(t, matches)
+}
+
+func testRuleSetReplace(t *testing.T) {
+	set := NewEngine().NewRuleSet()
+
+	require.NoError(t, set.Add("a", `x eq 1`))
+	require.NoError(t, set.Add("b", `x eq 2`))
+	require.NoError(t, set.Add("a", `x eq 2`))
+
+	require.Equal(t, []string{"a", "b"}, set.Names(), "replacing a rule keeps its position")
+	require.Equal(t, 2, set.Len())
+
+	matches, err := set.Evaluate(D{"x": 2})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, matches)
+}
+
+func testRuleSetRemove(t *testing.T) {
+	set := NewEngine().NewRuleSet()
+
+	require.NoError(t, set.Add("a", `x pr`))
+	require.NoError(t, set.Add("b", `y pr`))
+
+	set.Remove("a")
+	set.Remove("missing")
+
+	require.Equal(t, []string{"b"}, set.Names())
+
+	_, exists := set.Rule("a")
+	require.False(t, exists)
+
+	compiled, exists := set.Rule("b")
+	require.True(t, exists)
+	require.Equal(t, "y pr", Format(compiled.AST))
+}
+
+func testRuleSetCompileError(t *testing.T) {
+	set := NewEngine().NewRuleSet()
+
+	require.Error(t, set.Add("broken", `x eq`))
+	require.Zero(t, set.Len())
+}
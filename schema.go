@@ -0,0 +1,93 @@
+package rule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema is the set of attribute paths rules may reference. It is used to catch misspelled
+// attributes, which would otherwise silently evaluate as missing, and to suggest the intended path.
+type Schema struct {
+	paths map[string]struct{}
+	// sorted lists the paths alphabetically for deterministic suggestions.
+	sorted []string
+}
+
+// NewSchema creates a schema from dotted attribute paths. The parents of every path are known
+// too, so NewSchema("user.address.city") also accepts user and user.address.
+func NewSchema(paths ...string) *Schema {
+	schema := &Schema{paths: map[string]struct{}{}}
+
+	for _, path := range paths {
+		segments := strings.Split(path, ".")
+		for i := range segments {
+			schema.paths[strings.Join(segments[:i+1], ".")] = struct{}{}
+		}
+	}
+
+	schema.sorted = make([]string, 0, len(schema.paths))
+	for path := range schema.paths {
+		schema.sorted = append(schema.sorted, path)
+	}
+
+	sort.Strings(schema.sorted)
+
+	return schema
+}
+
+// SchemaFromContext creates a schema from the attributes of a sample context.
+func SchemaFromContext(sample D) *Schema {
+	return NewSchema(ContextPaths(sample)...)
+}
+
+// Paths returns the known attribute paths in alphabetical order.
+func (s *Schema) Paths() []string {
+	return append([]string(nil), s.sorted...)
+}
+
+// Has reports whether path is a known attribute.
+func (s *Schema) Has(path string) bool {
+	_, ok := s.paths[path]
+	return ok
+}
+
+// Check reports every identifier or property of an AST that is not part of the schema, wrapping
+// ErrUnknownAttribute and suggesting the closest known paths.
+func (s *Schema) Check(node *ASTNode) []*SyntaxError {
+	var errs []*SyntaxError
+
+	s.check(node, &errs)
+
+	return errs
+}
+
+func (s *Schema) check(node *ASTNode, errs *[]*SyntaxError) {
+	if node == nil {
+		return
+	}
+
+	path, isAttribute := attributePath(node)
+	if !isAttribute {
+		s.check(node.Left, errs)
+		s.check(node.Right, errs)
+
+		for _, child := range node.Children {
+			s.check(child, errs)
+		}
+
+		return
+	}
+
+	name := strings.Join(path, ".")
+	if s.Has(name) {
+		return
+	}
+
+	*errs = append(*errs, &SyntaxError{
+		Err:         fmt.Errorf("%w %s", ErrUnknownAttribute, name),
+		Start:       node.Start,
+		End:         node.End,
+		Suggestions: suggest(name, s.sorted),
+	})
+}
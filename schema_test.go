@@ -0,0 +1,79 @@
+package rule
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	t.Run("Paths", testSchemaPaths)
+	t.Run("Check", testSchemaCheck)
+	t.Run("FromContext", testSchemaFromContext)
+	t.Run("Engine", testSchemaEngine)
+}
+
+func testSchemaPaths(t *testing.T) {
+	schema := NewSchema("user.address.city", "age", "age")
+
+	require.Equal(t, []string{"age", "user", "user.address", "user.address.city"}, schema.Paths())
+	require.True(t, schema.Has("user.address"))
+	require.False(t, schema.Has("user.name"))
+}
+
+func testSchemaCheck(t *testing.T) {
+	schema := NewSchema("user.age", "user.name", "tags")
+
+	ast, err := ParseRule(`user.agee gt 18 and user.name eq "x" and "a" in tagz and unrelated pr`)
+	require.NoError(t, err)
+
+	errs := schema.Check(ast)
+	require.Len(t, errs, 3)
+
+	require.ErrorIs(t, errs[0], ErrUnknownAttribute)
+	require.Equal(t, "Unknown attribute user.agee at position 0, did you mean \"user.age\"?", errs[0].Error())
+	require.Equal(t, 9, errs[0].End)
+
+	require.Equal(t, []string{"tags"}, errs[1].Suggestions)
+	require.Equal(t, 48, errs[1].Start)
+
+	require.Empty(t, errs[2].Suggestions)
+	require.Equal(t, "Unknown attribute unrelated at position 57", errs[2].Error())
+
+	ast, err = ParseRule(`user.age gt 18 and user pr`)
+	require.NoError(t, err)
+	require.Empty(t, schema.Check(ast))
+}
+
+func testSchemaFromContext(t *testing.T) {
+	schema := SchemaFromContext(D{"user": D{"email": "a@b.c"}, "score": 1})
+	require.Equal(t, []string{"score", "user", "user.email"}, schema.Paths())
+}
+
+func testSchemaEngine(t *testing.T) {
+	engine := NewEngine()
+
+	_, err := engine.Evaluate(`user.emial co "@"`, D{})
+	require.NoError(t, err, "without a schema unknown attributes evaluate as missing")
+
+	engine.SetSchema(SchemaFromContext(D{"user": D{"email": "a@b.c"}}))
+
+	_, err = engine.Evaluate(`user.emial co "@"`, D{})
+	require.ErrorIs(t, err, ErrUnknownAttribute)
+
+	var syntaxErr *SyntaxError
+	require.True(t, errors.As(err, &syntaxErr))
+	require.Equal(t, []string{"user.email"}, syntaxErr.Suggestions)
+
+	require.ErrorIs(t, engine.AddQuery(`user.emial pr`), ErrUnknownAttribute)
+
+	result, err := engine.Evaluate(`user.email co "@"`, D{"user": D{"email": "a@b.c"}})
+	require.NoError(t, err)
+	require.True(t, result)
+
+	engine.SetSchema(nil)
+
+	_, err = engine.CompileRule(`user.emial pr`)
+	require.NoError(t, err)
+}
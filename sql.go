@@ -0,0 +1,517 @@
+package rule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLDialect selects the placeholder, quoting and pattern-matching conventions used by SQLTranslator.
+type SQLDialect uint8
+
+const (
+	// DialectPostgres emits $1-style placeholders, double-quoted identifiers and ILIKE.
+	DialectPostgres SQLDialect = iota
+	// DialectSQLite emits ?-style placeholders, double-quoted identifiers and LOWER(...) LIKE.
+	DialectSQLite
+	// DialectMySQL emits ?-style placeholders, backtick-quoted identifiers and LOWER(...) LIKE.
+	DialectMySQL
+)
+
+// SQLTranslator converts a parsed rule into a parameterised SQL predicate suitable for a WHERE clause.
+//
+// String equality is case-insensitive in the engine, so equality comparisons against string
+// literals lower-case both sides; ordering comparisons are case-sensitive and left as they are.
+// Equality between two attributes is not translated, as its case sensitivity depends on the
+// column types.
+// Missing attributes map to NULL, and NOT is wrapped in COALESCE so that `not (x eq 1)` matches
+// rows where x is NULL, just like the engine does for missing attributes.
+type SQLTranslator struct {
+	Dialect SQLDialect
+	// Columns maps attribute paths such as "user.age" to column expressions.
+	// Mapped expressions are emitted verbatim and must be trusted.
+	Columns map[string]string
+	// StrictColumns rejects attributes that are not present in Columns instead of
+	// deriving a quoted column reference from the attribute path.
+	StrictColumns bool
+	// Now returns the reference time used by dl/dg. Defaults to time.Now.
+	Now func() time.Time
+}
+
+func NewSQLTranslator(dialect SQLDialect) *SQLTranslator {
+	return &SQLTranslator{
+		Dialect: dialect,
+		Columns: make(map[string]string),
+	}
+}
+
+// sqlBuilder accumulates the SQL text and positional arguments for one translation.
+type sqlBuilder struct {
+	translator *SQLTranslator
+	sql        strings.Builder
+	args       []any
+}
+
+// sqlOperand is a comparison operand resolved to either a column expression or a literal.
+type sqlOperand struct {
+	column  string
+	literal *Value
+}
+
+// Translate returns the SQL predicate for the rule and the arguments bound to its placeholders.
+func (t *SQLTranslator) Translate(node *ASTNode) (string, []any, error) {
+	if node == nil {
+		return "", nil, ErrInvalidNode
+	}
+
+	builder := &sqlBuilder{translator: t}
+
+	if err := builder.writeNode(node, false); err != nil {
+		return "", nil, err
+	}
+
+	return builder.sql.String(), builder.args, nil
+}
+
+// TranslateRule parses the rule and translates it into a SQL predicate.
+func (t *SQLTranslator) TranslateRule(rule string) (string, []any, error) {
+	ast, err := ParseRule(rule)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return t.Translate(ast)
+}
+
+func (b *sqlBuilder) writeNode(node *ASTNode, nested bool) error {
+	switch node.Type {
+	case NodeBinaryOp:
+		if node.Operator == AND || node.Operator == OR {
+			return b.writeLogical(node, nested)
+		}
+
+		return b.writeComparison(node)
+	case NodeUnaryOp:
+		return b.writeUnary(node)
+	case NodeLiteral:
+		if node.Value.Type != ValueBoolean {
+			return unsupportedTranslation("non-boolean literal used as a condition")
+		}
+
+		b.sql.WriteString(sqlBool(node.Value.BoolValue))
+
+		return nil
+	case NodeIdentifier, NodeProperty:
+		column, err := b.column(node)
+		if err != nil {
+			return err
+		}
+
+		b.sql.WriteString(column + " IS TRUE")
+
+		return nil
+	case NodeArray:
+		return unsupportedTranslation("array used as a condition")
+	case NodeConditional:
+		return unsupportedTranslation("conditional expression")
+	case NodeParameter:
+		return unsupportedTranslation("parameter")
+	case NodeReference:
+		return unsupportedTranslation("reference to a definition")
+	case NodeLet, NodeVariable:
+		return unsupportedTranslation("let expression")
+	case NodeCall:
+		return unsupportedTranslation("function " + node.Value.StrValue)
+	case NodeError:
+		return ErrInvalidSyntax
+	default:
+		return ErrInvalidNode
+	}
+}
+
+func (b *sqlBuilder) writeLogical(node *ASTNode, nested bool) error {
+	keyword := " AND "
+	if node.Operator == OR {
+		keyword = " OR "
+	}
+
+	if nested {
+		b.sql.WriteByte('(')
+	}
+
+	if err := b.writeNode(node.Left, true); err != nil {
+		return err
+	}
+
+	b.sql.WriteString(keyword)
+
+	if err := b.writeNode(node.Right, true); err != nil {
+		return err
+	}
+
+	if nested {
+		b.sql.WriteByte(')')
+	}
+
+	return nil
+}
+
+func (b *sqlBuilder) writeUnary(node *ASTNode) error {
+	switch node.Operator { //nolint:exhaustive // only NOT and PR are unary operators
+	case NOT:
+		// Missing attributes evaluate to false in the engine, so NULL must collapse to FALSE before negation
+		b.sql.WriteString("NOT COALESCE(")
+
+		if err := b.writeNode(node.Left, false); err != nil {
+			return err
+		}
+
+		b.sql.WriteString(", FALSE)")
+
+		return nil
+	case PR:
+		column, err := b.column(node.Left)
+		if err != nil {
+			return err
+		}
+
+		b.sql.WriteString(column + " IS NOT NULL")
+
+		return nil
+	default:
+		return unsupportedTranslation("unary operator " + node.Operator.String())
+	}
+}
+
+func (b *sqlBuilder) writeComparison(node *ASTNode) error {
+	left, err := b.operand(node.Left)
+	if err != nil {
+		return err
+	}
+
+	right, err := b.operand(node.Right)
+	if err != nil {
+		return err
+	}
+
+	switch node.Operator { //nolint:exhaustive // logical operators are handled by writeLogical
+	case EQ, EQUALS, NE, NOT_EQUALS, LT, GT, LE, GE:
+		return b.writeRelational(node.Operator, left, right)
+	case CO, SW, EW:
+		return b.writeLike(node.Operator, left, right)
+	case IN, NOT_IN:
+		return b.writeMembership(node.Operator, left, right)
+	case DQ, DN, BE, BQ, AF, AQ:
+		return b.writeDateTime(node.Operator, left, right)
+	case DL, DG:
+		return b.writeDays(node.Operator, left, right)
+	default:
+		return unsupportedTranslation("binary operator " + node.Operator.String())
+	}
+}
+
+func (b *sqlBuilder) writeRelational(op TokenType, left, right sqlOperand) error {
+	// Keep the column on the left so LOWER() and placeholders read naturally
+	if left.literal != nil && right.literal == nil {
+		left, right = right, left
+		op = mirrorOperator(op)
+	}
+
+	symbol := sqlComparisonSymbol(op)
+
+	// The engine orders strings case-sensitively, so only equality lower-cases them
+	equality := op == EQ || op == EQUALS || op == NE || op == NOT_EQUALS
+
+	if equality && left.literal == nil && right.literal != nil && right.literal.Type == ValueString {
+		b.sql.WriteString("LOWER(" + left.column + ") " + symbol + " ")
+		b.writeArg(asciiLower(right.literal.StrValue))
+
+		return nil
+	}
+
+	if equality && left.literal == nil && right.literal == nil {
+		// LOWER() would break non-text columns, and a plain = is case-sensitive for text ones
+		return unsupportedTranslation(op.String() + " between two attributes")
+	}
+
+	b.writeOperand(left)
+	b.sql.WriteString(" " + symbol + " ")
+	b.writeOperand(right)
+
+	return nil
+}
+
+func (b *sqlBuilder) writeLike(op TokenType, left, right sqlOperand) error {
+	if left.literal != nil || right.literal == nil || right.literal.Type != ValueString {
+		return unsupportedTranslation(
+			op.String() + " requires an attribute on the left and a string literal on the right",
+		)
+	}
+
+	pattern := escapeLikePattern(right.literal.StrValue)
+
+	switch op { //nolint:exhaustive // only called for pattern operators
+	case CO:
+		pattern = "%" + pattern + "%"
+	case SW:
+		pattern += "%"
+	case EW:
+		pattern = "%" + pattern
+	default:
+		return unsupportedTranslation("pattern operator " + op.String())
+	}
+
+	if b.translator.Dialect == DialectPostgres {
+		b.sql.WriteString(left.column + " ILIKE ")
+		b.writeArg(pattern)
+	} else {
+		b.sql.WriteString("LOWER(" + left.column + ") LIKE ")
+		b.writeArg(asciiLower(pattern))
+	}
+
+	b.sql.WriteString(b.likeEscapeClause())
+
+	return nil
+}
+
+func (b *sqlBuilder) writeMembership(op TokenType, left, right sqlOperand) error {
+	if right.literal == nil {
+		return b.writeArrayColumnMembership(op, left, right)
+	}
+
+	if left.literal != nil {
+		return unsupportedTranslation(op.String() + " with a literal on both sides")
+	}
+
+	elements := right.literal.ArrValue
+	if len(elements) == 0 {
+		// Empty arrays never match, but "not in" still requires the attribute to be present
+		if op == IN {
+			b.sql.WriteString("FALSE")
+		} else {
+			b.sql.WriteString(left.column + " IS NOT NULL")
+		}
+
+		return nil
+	}
+
+	elementType := elements[0].Type
+	for i := range elements {
+		if elements[i].Type != elementType {
+			return unsupportedTranslation(op.String() + " with mixed-type array")
+		}
+	}
+
+	column := left.column
+	if elementType == ValueString {
+		column = "LOWER(" + column + ")"
+	}
+
+	keyword := " IN ("
+	if op == NOT_IN {
+		keyword = " NOT IN ("
+	}
+
+	b.sql.WriteString(column + keyword)
+
+	for i := range elements {
+		if i > 0 {
+			b.sql.WriteString(", ")
+		}
+
+		value := literalToAny(&elements[i])
+		if elementType == ValueString {
+			value = asciiLower(elements[i].StrValue)
+		}
+
+		b.writeArg(value)
+	}
+
+	b.sql.WriteByte(')')
+
+	return nil
+}
+
+// writeArrayColumnMembership handles `"value" in tags`, which only PostgreSQL arrays can express.
+func (b *sqlBuilder) writeArrayColumnMembership(op TokenType, left, right sqlOperand) error {
+	if b.translator.Dialect != DialectPostgres || left.literal == nil {
+		return unsupportedTranslation(op.String() + " against an attribute requires PostgreSQL and a literal needle")
+	}
+
+	if op == NOT_IN {
+		b.sql.WriteString("NOT (")
+	}
+
+	b.writeArg(literalToAny(left.literal))
+	b.sql.WriteString(" = ANY(" + right.column + ")")
+
+	if op == NOT_IN {
+		b.sql.WriteByte(')')
+	}
+
+	return nil
+}
+
+func (b *sqlBuilder) writeDateTime(op TokenType, left, right sqlOperand) error {
+	if left.literal != nil && right.literal == nil {
+		left, right = right, left
+		op = mirrorOperator(op)
+	}
+
+	if left.literal != nil {
+		return unsupportedTranslation(op.String() + " with a literal on both sides")
+	}
+
+	b.sql.WriteString(left.column + " " + sqlComparisonSymbol(op) + " ")
+
+	if right.literal == nil {
+		b.sql.WriteString(right.column)
+		return nil
+	}
+
+	timestamp, ok := literalDateTime(right.literal)
+	if !ok {
+		return unsupportedTranslation(op.String() + " with a literal that is not a datetime")
+	}
+
+	b.writeArg(timestamp)
+
+	return nil
+}
+
+func (b *sqlBuilder) writeDays(op TokenType, left, right sqlOperand) error {
+	if left.literal != nil || right.literal == nil {
+		return unsupportedTranslation(
+			op.String() + " requires an attribute on the left and a numeric literal on the right",
+		)
+	}
+
+	days, ok := literalDays(right.literal)
+	if !ok {
+		return unsupportedTranslation(op.String() + " with a non-numeric threshold")
+	}
+
+	// "fewer than N days ago" means the timestamp is after now minus N days, and vice versa
+	symbol := " > "
+	if op == DG {
+		symbol = " < "
+	}
+
+	b.sql.WriteString(left.column + symbol)
+	b.writeArg(b.translator.now().Add(-daysToDuration(days)))
+
+	return nil
+}
+
+func (b *sqlBuilder) operand(node *ASTNode) (sqlOperand, error) {
+	if node.Type == NodeLiteral {
+		return sqlOperand{literal: &node.Value}, checkLiteral(&node.Value)
+	}
+
+	column, err := b.column(node)
+	if err != nil {
+		return sqlOperand{}, err
+	}
+
+	return sqlOperand{column: column}, nil
+}
+
+func (b *sqlBuilder) column(node *ASTNode) (string, error) {
+	path, ok := attributePath(node)
+	if !ok {
+		return "", unsupportedTranslation("expression used where an attribute is required")
+	}
+
+	key := strings.Join(path, ".")
+	if column, mapped := b.translator.Columns[key]; mapped {
+		return column, nil
+	}
+
+	if b.translator.StrictColumns {
+		return "", unsupportedTranslation("attribute " + key + " has no column mapping")
+	}
+
+	quoted := make([]string, len(path))
+	for i, segment := range path {
+		quoted[i] = b.quoteIdentifier(segment)
+	}
+
+	return strings.Join(quoted, "."), nil
+}
+
+func (b *sqlBuilder) writeOperand(operand sqlOperand) {
+	if operand.literal == nil {
+		b.sql.WriteString(operand.column)
+		return
+	}
+
+	b.writeArg(literalToAny(operand.literal))
+}
+
+func (b *sqlBuilder) writeArg(value any) {
+	b.args = append(b.args, value)
+
+	if b.translator.Dialect == DialectPostgres {
+		b.sql.WriteString("$" + strconv.Itoa(len(b.args)))
+	} else {
+		b.sql.WriteByte('?')
+	}
+}
+
+func (b *sqlBuilder) quoteIdentifier(name string) string {
+	if b.translator.Dialect == DialectMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (b *sqlBuilder) likeEscapeClause() string {
+	// MySQL treats backslash as an escape character inside string literals
+	if b.translator.Dialect == DialectMySQL {
+		return ` ESCAPE '\\'`
+	}
+
+	return ` ESCAPE '\'`
+}
+
+func (t *SQLTranslator) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+
+	return time.Now()
+}
+
+// escapeLikePattern escapes LIKE wildcards so the literal is matched verbatim.
+func escapeLikePattern(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
+
+func sqlComparisonSymbol(op TokenType) string {
+	switch op { //nolint:exhaustive // only called for comparison operators
+	case EQ, EQUALS, DQ:
+		return "="
+	case NE, NOT_EQUALS, DN:
+		return "<>"
+	case LT, BE:
+		return "<"
+	case GT, AF:
+		return ">"
+	case LE, BQ:
+		return "<="
+	case GE, AQ:
+		return ">="
+	default:
+		return ""
+	}
+}
+
+func sqlBool(value bool) string {
+	if value {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
@@ -0,0 +1,145 @@
+package rule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLTranslator(t *testing.T) {
+	t.Run("Postgres", testSQLPostgres)
+	t.Run("SQLite", testSQLSQLite)
+	t.Run("MySQL", testSQLMySQL)
+	t.Run("ColumnMapping", testSQLColumnMapping)
+	t.Run("DateTime", testSQLDateTime)
+	t.Run("Unsupported", testSQLUnsupported)
+}
+
+func testSQLPostgres(t *testing.T) {
+	translator := NewSQLTranslator(DialectPostgres)
+
+	tests := []struct {
+		rule string
+		sql  string
+		args []any
+	}{
+		{`age gt 18`, `"age" > $1`, []any{float64(18)}},
+		{`18 lt age`, `"age" > $1`, []any{float64(18)}},
+		{`user.status eq "Active"`, `LOWER("user"."status") = $1`, []any{"active"}},
+		{`status != "x"`, `LOWER("status") <> $1`, []any{"x"}},
+		{`name gt "B"`, `"name" > $1`, []any{"B"}},
+		{`"B" le name`, `"name" >= $1`, []any{"B"}},
+		{`age ge limits.min`, `"age" >= "limits"."min"`, nil},
+		{`flag eq true`, `"flag" = $1`, []any{true}},
+		{`name co "50%_off"`, `"name" ILIKE $1 ESCAPE '\'`, []any{`%50\%\_off%`}},
+		{`name sw "Jo"`, `"name" ILIKE $1 ESCAPE '\'`, []any{`Jo%`}},
+		{`name ew "e"`, `"name" ILIKE $1 ESCAPE '\'`, []any{`%e`}},
+		{`country in ["BR", "pt"]`, `LOWER("country") IN ($1, $2)`, []any{"br", "pt"}},
+		{`id not in [1, 2]`, `"id" NOT IN ($1, $2)`, []any{float64(1), float64(2)}},
+		{`id in []`, `FALSE`, nil},
+		{`id not in []`, `"id" IS NOT NULL`, nil},
+		{`"admin" in roles`, `$1 = ANY("roles")`, []any{"admin"}},
+		{`email pr`, `"email" IS NOT NULL`, nil},
+		{`active`, `"active" IS TRUE`, nil},
+		{`not (x eq 1)`, `NOT COALESCE("x" = $1, FALSE)`, []any{float64(1)}},
+		{
+			`a eq 1 and (b eq 2 or c eq 3)`,
+			`"a" = $1 AND ("b" = $2 OR "c" = $3)`,
+			[]any{float64(1), float64(2), float64(3)},
+		},
+		{`big eq 9007199254740993`, `"big" = $1`, []any{int64(9007199254740993)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			sql, args, err := translator.TranslateRule(tt.rule)
+			require.NoError(t, err)
+			require.Equal(t, tt.sql, sql)
+			require.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func testSQLSQLite(t *testing.T) {
+	translator := NewSQLTranslator(DialectSQLite)
+
+	sql, args, err := translator.TranslateRule(`name co "Jo" and age ge 18`)
+	require.NoError(t, err)
+	require.Equal(t, `LOWER("name") LIKE ? ESCAPE '\' AND "age" >= ?`, sql)
+	require.Equal(t, []any{"%jo%", float64(18)}, args)
+}
+
+func testSQLMySQL(t *testing.T) {
+	translator := NewSQLTranslator(DialectMySQL)
+
+	sql, args, err := translator.TranslateRule(`user.name sw "A" or user.tier in ["gold"]`)
+	require.NoError(t, err)
+	require.Equal(t, "LOWER(`user`.`name`) LIKE ? ESCAPE '\\\\' OR LOWER(`user`.`tier`) IN (?)", sql)
+	require.Equal(t, []any{"a%", "gold"}, args)
+}
+
+func testSQLColumnMapping(t *testing.T) {
+	translator := NewSQLTranslator(DialectPostgres)
+	translator.Columns["user.age"] = "u.age_years"
+
+	sql, _, err := translator.TranslateRule(`user.age ge 18`)
+	require.NoError(t, err)
+	require.Equal(t, `u.age_years >= $1`, sql)
+
+	translator.StrictColumns = true
+
+	_, _, err = translator.TranslateRule(`user.age ge 18 and user.country eq "BR"`)
+	require.ErrorIs(t, err, ErrUnsupportedTranslation)
+	require.Contains(t, err.Error(), "user.country")
+}
+
+func testSQLDateTime(t *testing.T) {
+	now := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	translator := NewSQLTranslator(DialectPostgres)
+	translator.Now = func() time.Time { return now }
+
+	sql, args, err := translator.TranslateRule(`created_at af "2024-01-01T00:00:00Z"`)
+	require.NoError(t, err)
+	require.Equal(t, `"created_at" > $1`, sql)
+	require.Equal(t, []any{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, args)
+
+	sql, args, err = translator.TranslateRule(`1704067200 bq created_at`)
+	require.NoError(t, err)
+	require.Equal(t, `"created_at" >= $1`, sql)
+	require.Equal(t, []any{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, args)
+
+	sql, args, err = translator.TranslateRule(`last_login dl 7`)
+	require.NoError(t, err)
+	require.Equal(t, `"last_login" > $1`, sql)
+	require.Equal(t, []any{now.Add(-7 * 24 * time.Hour)}, args)
+
+	sql, args, err = translator.TranslateRule(`last_login dg 1.5`)
+	require.NoError(t, err)
+	require.Equal(t, `"last_login" < $1`, sql)
+	require.Equal(t, []any{now.Add(-36 * time.Hour)}, args)
+}
+
+func testSQLUnsupported(t *testing.T) {
+	tests := []struct {
+		dialect SQLDialect
+		rule    string
+	}{
+		{DialectPostgres, `name co other`},
+		{DialectPostgres, `id in [1, "a"]`},
+		{DialectSQLite, `"admin" in roles`},
+		{DialectPostgres, `created_at af "yesterday"`},
+		{DialectPostgres, `"2024-01-01T00:00:00Z" dq "2024-01-02T00:00:00Z"`},
+		{DialectPostgres, `x dl y`},
+		{DialectPostgres, `"text"`},
+		{DialectPostgres, `age eq limits.min`},
+		{DialectMySQL, `name != other.name`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, _, err := NewSQLTranslator(tt.dialect).TranslateRule(tt.rule)
+			require.ErrorIs(t, err, ErrUnsupportedTranslation)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package rule
+
+import "sort"
+
+// maxSuggestions caps the number of "did you mean" candidates attached to an error.
+const maxSuggestions = 3
+
+// suggest returns the candidates closest to word, best first. Candidates are ranked by edit
+// distance, then by how much their length differs from word, and only the best-ranked ones are
+// kept. Candidates more than a third of the word's length away are ignored so unrelated words
+// produce no noise; words shorter than three runes are too ambiguous to get any suggestion.
+func suggest(word string, candidates []string) []string {
+	limit := len([]rune(word)) / 3
+	if limit == 0 {
+		return nil
+	}
+
+	type match struct {
+		candidate string
+		distance  int
+		lengthGap int
+	}
+
+	var best []match
+
+	for _, candidate := range candidates {
+		if candidate == word {
+			continue
+		}
+
+		distance := editDistance(word, candidate)
+		if distance > limit {
+			continue
+		}
+
+		current := match{
+			candidate: candidate,
+			distance:  distance,
+			lengthGap: abs(len([]rune(candidate)) - len([]rune(word))),
+		}
+
+		switch {
+		case len(best) == 0 || current.distance < best[0].distance ||
+			(current.distance == best[0].distance && current.lengthGap < best[0].lengthGap):
+			best = []match{current}
+		case current.distance == best[0].distance && current.lengthGap == best[0].lengthGap:
+			best = append(best, current)
+		}
+	}
+
+	if len(best) == 0 {
+		return nil
+	}
+
+	suggestions := make([]string, len(best))
+	for i, m := range best {
+		suggestions[i] = m.candidate
+	}
+
+	sort.Strings(suggestions)
+
+	return suggestions[:min(len(suggestions), maxSuggestions)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// editDistance is the optimal string alignment distance between a and b: the number of rune
+// insertions, deletions, substitutions and adjacent transpositions turning one into the other.
+func editDistance(a, b string) int {
+	source, target := []rune(a), []rune(b)
+
+	// Three rolling rows: two rows back (for transpositions), the previous row and the current row.
+	prevPrev := make([]int, len(target)+1)
+	prev := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(prev[j]+1, current[j-1]+1, prev[j-1]+cost)
+
+			if i > 1 && j > 1 && source[i-1] == target[j-2] && source[i-2] == target[j-1] {
+				current[j] = min(current[j], prevPrev[j-2]+1)
+			}
+		}
+
+		prevPrev, prev, current = prev, current, prevPrev
+	}
+
+	return prev[len(target)]
+}
+
+// suggestKeywords returns the keywords closest to a misspelled operator or logical keyword.
+func suggestKeywords(word string) []string {
+	return suggest(word, Keywords())
+}
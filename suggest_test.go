@@ -0,0 +1,79 @@
+package rule
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"gt", "gt", 0},
+		{"gte", "gt", 1},
+		{"adn", "and", 1},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"naïve", "naive", 1},
+		{"ca", "abc", 3},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.distance, editDistance(tt.a, tt.b), "%q -> %q", tt.a, tt.b)
+		require.Equal(t, tt.distance, editDistance(tt.b, tt.a), "%q -> %q", tt.b, tt.a)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	require.Equal(t, []string{"ge", "gt"}, suggest("gte", Keywords()))
+	require.Equal(t, []string{"and"}, suggest("adn", Keywords()))
+	require.Equal(t, []string{"user.age"}, suggest("user.agee", []string{"user.age", "user.name", "account"}))
+	require.Empty(t, suggest("completely", Keywords()))
+	require.Empty(t, suggest("eq", []string{"eq"}))
+	require.Empty(t, suggest("b", Keywords()), "short words are too ambiguous")
+	require.Len(t, suggest("abcd", []string{"abce", "abcf", "abcg", "abch"}), maxSuggestions)
+}
+
+func TestParseErrorSuggestions(t *testing.T) {
+	tests := []struct {
+		rule        string
+		suggestions []string
+		message     string
+	}{
+		{
+			`age gte 18`, []string{"ge", "gt"},
+			`Missing operator between operands at position 4, did you mean "ge" or "gt"?`,
+		},
+		{
+			`a eq 1 adn b eq 2`, []string{"and"},
+			`Unexpected tokens after complete expression at position 7, did you mean "and"?`,
+		},
+		{`(a eq 1 nto b eq 2)`, []string{"not"}, `expected ), got IDENTIFIER at position 8, did you mean "not"?`},
+		{
+			`flag in [tru]`, []string{"true"},
+			`unexpected token in array: IDENTIFIER at position 9, did you mean "true"?`,
+		},
+		{`a eq 1 xyzzy`, nil, `Unexpected tokens after complete expression at position 7`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := ParseRule(tt.rule)
+
+			var syntaxErr *SyntaxError
+			require.True(t, errors.As(err, &syntaxErr))
+			require.Equal(t, tt.suggestions, syntaxErr.Suggestions)
+			require.Equal(t, tt.message, err.Error())
+		})
+	}
+}
+
+func TestRecoveryKeepsSuggestions(t *testing.T) {
+	_, diagnostics := ParseRuleWithRecovery(`age gte 18 adn name eq "x"`)
+	require.Len(t, diagnostics, 1)
+	require.Equal(t, []string{"ge", "gt"}, diagnostics[0].Suggestions)
+}
@@ -0,0 +1,367 @@
+package rule
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// anyCell is the cell that matches every value, like an empty cell.
+const anyCell = "-"
+
+// outputPrefix marks the header of an output column in a CSV decision table.
+const outputPrefix = "="
+
+// DecisionTable is a DMN-style decision table. Each row is a rule: it matches when every input
+// cell matches, and then produces the values of its output cells. Input cells are unary tests on
+// the attribute of their column, written in the rule language without the attribute: `gt 100`,
+// `in ["BR", "PT"]` or `pr`. A bare value such as `"BR"` or `10` tests for equality, and `-` or an
+// empty cell matches anything. Output cells are literals such as `0.15`, `"gold"` or `[1, 2]`; any
+// other text is taken as a string, and `-` or an empty cell produces no value.
+type DecisionTable struct {
+	// Policy is the hit policy of the table. DMN's unique, first and collect policies are
+	// HitUnique, HitFirst and HitAll.
+	Policy HitPolicy
+	// Inputs holds the attribute path of each input column.
+	Inputs []string
+	// Outputs holds the name of each output column.
+	Outputs []string
+	Rows    []TableRow
+}
+
+// TableRow is a row of a decision table.
+type TableRow struct {
+	// Name identifies the row in outcomes; it defaults to the row number, starting from 1.
+	Name    string
+	Inputs  []string
+	Outputs []string
+}
+
+// TableError locates an error in a decision table. Rows and columns are numbered from 1. For
+// tables loaded from CSV they are the line and field of the file, so the header is row 1 and the
+// row names are column 1; for a DecisionTable, row 0 is the header and columns count the inputs
+// followed by the outputs. Column 0 refers to the whole row. Errors in a cell unwrap to a
+// SyntaxError whose positions are relative to the cell.
+type TableError struct {
+	Row    int
+	Column int
+	Err    error
+}
+
+func (e *TableError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+
+	return fmt.Sprintf("row %d, column %d: %v", e.Row, e.Column, e.Err)
+}
+
+func (e *TableError) Unwrap() error {
+	return e.Err
+}
+
+// tableLayout maps the rows and columns of a DecisionTable to the ones reported in errors.
+type tableLayout struct {
+	headerRow int
+	// rows holds the number of each row; without it rows are numbered from 1.
+	rows        []int
+	firstColumn int
+}
+
+func (l tableLayout) row(index int) int {
+	if index < len(l.rows) {
+		return l.rows[index]
+	}
+
+	return index + 1
+}
+
+// CompileTable compiles every row of a decision table into a rule and returns a decision that
+// produces, for each matching row, an Outcome named after the row whose Payload maps the output
+// column names to the row's output values.
+func (e *Engine) CompileTable(table DecisionTable) (*Decision, error) {
+	return e.compileTable(table, tableLayout{headerRow: 0, firstColumn: 1})
+}
+
+// LoadTableCSV reads a decision table from CSV and compiles it. The first row is the header: its
+// first cell is the hit policy (U, F, C or unique, first, collect), followed by the attribute
+// paths of the input columns and then the names of the output columns, each prefixed with "=".
+// Every other row starts with an optional row name, followed by its input and output cells:
+//
+//	F,country,amount,=discount
+//	domestic,"""BR""",gt 100,0.1
+//	,-,gt 500,0.05
+func (e *Engine) LoadTableCSV(r io.Reader) (*Decision, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	var (
+		records [][]string
+		lines   []int
+	)
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &TableError{Row: parseErr.Line, Column: parseErr.Column, Err: parseErr.Err}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	if len(records) == 0 {
+		return nil, &TableError{Row: 1, Err: fmt.Errorf("%w: missing header", ErrInvalidTable)}
+	}
+
+	table, err := parseTableHeader(records[0], lines[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records[1:] {
+		inputs := len(table.Inputs)
+		table.Rows = append(table.Rows, TableRow{
+			Name:    strings.TrimSpace(record[0]),
+			Inputs:  record[1 : 1+inputs],
+			Outputs: record[1+inputs:],
+		})
+	}
+
+	return e.compileTable(table, tableLayout{headerRow: lines[0], rows: lines[1:], firstColumn: 2})
+}
+
+func parseTableHeader(header []string, line int) (DecisionTable, error) {
+	var table DecisionTable
+
+	switch strings.ToLower(strings.TrimSpace(header[0])) {
+	case "u", "unique":
+		table.Policy = HitUnique
+	case "f", "first":
+		table.Policy = HitFirst
+	case "c", "collect":
+		table.Policy = HitAll
+	default:
+		return table, &TableError{
+			Row:    line,
+			Column: 1,
+			Err:    fmt.Errorf("%w: unknown hit policy %q", ErrInvalidTable, header[0]),
+		}
+	}
+
+	for i, cell := range header[1:] {
+		cell = strings.TrimSpace(cell)
+
+		if name, isOutput := strings.CutPrefix(cell, outputPrefix); isOutput {
+			table.Outputs = append(table.Outputs, strings.TrimSpace(name))
+			continue
+		}
+
+		if len(table.Outputs) > 0 {
+			return table, &TableError{
+				Row:    line,
+				Column: i + 2,
+				Err:    fmt.Errorf("%w: input column %q after an output column", ErrInvalidTable, cell),
+			}
+		}
+
+		table.Inputs = append(table.Inputs, cell)
+	}
+
+	return table, nil
+}
+
+func (e *Engine) compileTable(table DecisionTable, layout tableLayout) (*Decision, error) {
+	if err := checkTableHeader(table, layout); err != nil {
+		return nil, err
+	}
+
+	decision := e.NewDecision(table.Policy)
+
+	for i, row := range table.Rows {
+		position := layout.row(i)
+
+		if len(row.Inputs) != len(table.Inputs) || len(row.Outputs) != len(table.Outputs) {
+			return nil, &TableError{Row: position, Err: fmt.Errorf(
+				"%w: expected %d inputs and %d outputs, got %d and %d",
+				ErrInvalidTable, len(table.Inputs), len(table.Outputs), len(row.Inputs), len(row.Outputs),
+			)}
+		}
+
+		compiled, err := e.compileTableRow(table.Inputs, row.Inputs, position, layout)
+		if err != nil {
+			return nil, err
+		}
+
+		name := row.Name
+		if name == "" {
+			name = strconv.Itoa(i + 1)
+		}
+
+		decision.AddCompiled(compiled, Outcome{Name: name, Payload: tableOutputs(table.Outputs, row.Outputs)})
+	}
+
+	return decision, nil
+}
+
+func checkTableHeader(table DecisionTable, layout tableLayout) error {
+	for i, input := range table.Inputs {
+		ast, err := ParseRule(input)
+		if err != nil || !ast.IsIdentifier() {
+			return &TableError{
+				Row:    layout.headerRow,
+				Column: layout.firstColumn + i,
+				Err:    fmt.Errorf("%w: %q is not an attribute path", ErrInvalidTable, input),
+			}
+		}
+	}
+
+	for i, output := range table.Outputs {
+		if output == "" {
+			return &TableError{
+				Row:    layout.headerRow,
+				Column: layout.firstColumn + len(table.Inputs) + i,
+				Err:    fmt.Errorf("%w: output column without a name", ErrInvalidTable),
+			}
+		}
+	}
+
+	return nil
+}
+
+// tableCell records where the test of a cell sits in the rule compiled for its row.
+type tableCell struct {
+	column int
+	// start and end delimit the whole parenthesised test; text is where the cell text begins.
+	start, text, end int
+}
+
+// compileTableRow joins the input cells of a row into one rule and maps compile errors back to
+// the cell they come from.
+func (e *Engine) compileTableRow(paths, cells []string, row int, layout tableLayout) (*CompiledRule, error) {
+	var (
+		builder strings.Builder
+		spans   []tableCell
+		length  int
+	)
+
+	write := func(s string) {
+		builder.WriteString(s)
+		length += len([]rune(s))
+	}
+
+	for i, cell := range cells {
+		cell = strings.TrimSpace(cell)
+		if cell == "" || cell == anyCell {
+			continue
+		}
+
+		if builder.Len() > 0 {
+			write(" and ")
+		}
+
+		column := layout.firstColumn + i
+
+		prefix := paths[i] + " "
+		if !isUnaryTest(cell) {
+			prefix += "eq "
+		}
+
+		// Parse the test on its own so syntax errors read as if the cell held the whole rule.
+		if _, err := ParseRule(prefix + cell); err != nil {
+			return nil, &TableError{Row: row, Column: column, Err: shiftSyntaxError(err, len([]rune(prefix)))}
+		}
+
+		span := tableCell{column: column, start: length}
+
+		write("(" + prefix)
+		span.text = length
+		write(cell + ")")
+		span.end = length
+
+		spans = append(spans, span)
+	}
+
+	if builder.Len() == 0 {
+		write("true")
+	}
+
+	compiled, err := e.CompileRule(builder.String())
+	if err == nil {
+		return compiled, nil
+	}
+
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return nil, &TableError{Row: row, Err: err}
+	}
+
+	for _, span := range spans {
+		if syntaxErr.Start < span.start || syntaxErr.Start >= span.end {
+			continue
+		}
+
+		if syntaxErr.Start < span.text {
+			// The error lies in the attribute path, which comes from the header.
+			return nil, &TableError{Row: layout.headerRow, Column: span.column, Err: err}
+		}
+
+		return nil, &TableError{Row: row, Column: span.column, Err: shiftSyntaxError(syntaxErr, span.text)}
+	}
+
+	return nil, &TableError{Row: row, Err: err}
+}
+
+// shiftSyntaxError makes the positions of a syntax error relative to a cell starting at offset.
+func shiftSyntaxError(err error, offset int) error {
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+
+	return &SyntaxError{
+		Err:         syntaxErr.Err,
+		Start:       max(syntaxErr.Start-offset, 0),
+		End:         max(syntaxErr.End-offset, 0),
+		Suggestions: syntaxErr.Suggestions,
+	}
+}
+
+// isUnaryTest reports whether a cell starts with an operator, so it only lacks its attribute.
+func isUnaryTest(cell string) bool {
+	tokens := NewLexer(cell).Tokenize()
+	return (&Parser{}).isComparisonOperator(tokens[0].Type)
+}
+
+// tableOutputs maps the output columns of a row to their values.
+func tableOutputs(names, cells []string) map[string]any {
+	outputs := make(map[string]any, len(names))
+
+	for i, cell := range cells {
+		cell = strings.TrimSpace(cell)
+		if cell == "" || cell == anyCell {
+			continue
+		}
+
+		outputs[names[i]] = cell
+
+		if ast, err := ParseRule(cell); err == nil && ast.Type == NodeLiteral {
+			outputs[names[i]] = literalToAny(&ast.Value)
+		}
+	}
+
+	return outputs
+}
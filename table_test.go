@@ -0,0 +1,196 @@
+package rule
+
+import (
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const discountTable = `F, country, order.amount, tier, =discount, =reason
+domestic-vip, """BR""", -, "in [""vip"", ""gold""]", 0.2, "loyal"
+big, -, ge 500, , 0.1, big order
+
+nordic, "in [""NO"", ""SE""]", gt 100, -, 0.05, -
+fallback, -, -, -, 0, none
+`
+
+func TestDecisionTable(t *testing.T) {
+	t.Run("LoadCSV", testDecisionTableLoadCSV)
+	t.Run("Policies", testDecisionTablePolicies)
+	t.Run("Compile", testDecisionTableCompile)
+	t.Run("CSVErrors", testDecisionTableCSVErrors)
+	t.Run("CompileErrors", testDecisionTableCompileErrors)
+}
+
+func testDecisionTableLoadCSV(t *testing.T) {
+	decision, err := NewEngine().LoadTableCSV(strings.NewReader(discountTable))
+	require.NoError(t, err)
+	require.Equal(t, HitFirst, decision.Policy())
+	require.Equal(t, 4, decision.Len())
+
+	tests := []struct {
+		context D
+		outcome Outcome
+	}{
+		{
+			D{"country": "br", "tier": "gold", "order": D{"amount": 50}},
+			Outcome{Name: "domestic-vip", Payload: map[string]any{"discount": 0.2, "reason": "loyal"}},
+		},
+		{
+			D{"country": "BR", "tier": "basic", "order": D{"amount": 800}},
+			Outcome{Name: "big", Payload: map[string]any{"discount": 0.1, "reason": "big order"}},
+		},
+		{
+			D{"country": "SE", "order": D{"amount": 150.5}},
+			Outcome{Name: "nordic", Payload: map[string]any{"discount": 0.05}},
+		},
+		{
+			D{},
+			Outcome{Name: "fallback", Payload: map[string]any{"discount": 0.0, "reason": "none"}},
+		},
+	}
+
+	for _, tt := range tests {
+		outcomes, err := decision.Decide(tt.context)
+		require.NoError(t, err)
+		require.Equal(t, []Outcome{tt.outcome}, outcomes, "context=%v", tt.context)
+	}
+}
+
+func testDecisionTablePolicies(t *testing.T) {
+	table := "%s,age,=band\n,lt 18,minor\n,ge 18,adult\n,ge 65,senior\n"
+
+	collect, err := NewEngine().LoadTableCSV(strings.NewReader(strings.Replace(table, "%s", "C", 1)))
+	require.NoError(t, err)
+	require.Equal(t, HitAll, collect.Policy())
+
+	outcomes, err := collect.Decide(D{"age": 70})
+	require.NoError(t, err)
+	require.Equal(t, []string{"2", "3"}, outcomeNames(outcomes))
+
+	unique, err := NewEngine().LoadTableCSV(strings.NewReader(strings.Replace(table, "%s", "unique", 1)))
+	require.NoError(t, err)
+
+	outcomes, err = unique.Decide(D{"age": 30})
+	require.NoError(t, err)
+	require.Equal(t, []Outcome{{Name: "2", Payload: map[string]any{"band": "adult"}}}, outcomes)
+
+	_, err = unique.Decide(D{"age": 70})
+	require.ErrorIs(t, err, ErrAmbiguousDecision)
+	require.ErrorContains(t, err, `"2" and "3"`)
+}
+
+func testDecisionTableCompile(t *testing.T) {
+	decision, err := NewEngine().CompileTable(DecisionTable{
+		Policy:  HitFirst,
+		Inputs:  []string{"user.country", "amount"},
+		Outputs: []string{"fee"},
+		Rows: []TableRow{
+			{Name: "exempt", Inputs: []string{"pr", "== 0"}, Outputs: []string{"[]"}},
+			{Inputs: []string{`not in ["US"]`, "-5"}, Outputs: []string{`[1, "a"]`}},
+			{Inputs: []string{"", ""}, Outputs: []string{"true"}},
+		},
+	})
+	require.NoError(t, err)
+
+	outcomes, err := decision.Decide(D{"user": D{"country": "BR"}, "amount": 0})
+	require.NoError(t, err)
+	require.Equal(t, []Outcome{{Name: "exempt", Payload: map[string]any{"fee": []any{}}}}, outcomes)
+
+	outcomes, err = decision.Decide(D{"user": D{"country": "BR"}, "amount": -5})
+	require.NoError(t, err)
+	require.Equal(t, []Outcome{{Name: "2", Payload: map[string]any{"fee": []any{1.0, "a"}}}}, outcomes)
+
+	outcomes, err = decision.Decide(D{"user": D{"country": "US"}, "amount": -5})
+	require.NoError(t, err)
+	require.Equal(t, []Outcome{{Name: "3", Payload: map[string]any{"fee": true}}}, outcomes)
+}
+
+func testDecisionTableCSVErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		csv    string
+		row    int
+		column int
+		err    error
+		// start is the position of the syntax error within the cell, if any.
+		start int
+	}{
+		{"Empty", "", 1, 0, ErrInvalidTable, -1},
+		{"Policy", "X,a,=b\n", 1, 1, ErrInvalidTable, -1},
+		{"InputAfterOutput", "F,a,=b,c\n", 1, 4, ErrInvalidTable, -1},
+		{"Path", "F,a,b eq 1,=c\n", 1, 3, ErrInvalidTable, -1},
+		{"UnnamedOutput", "F,a,=\n", 1, 3, ErrInvalidTable, -1},
+		{"Cell", "F,a,b,=c\nr,-,gt 1 adn 2,x\n", 2, 3, ErrTrailingTokens, 5},
+		{"CellOperand", "F,a,b,=c\n\nr,gt,-,x\n", 3, 2, nil, 2},
+		{"FieldCount", "F,a,=c\nr,1,2,3\n", 2, 1, csv.ErrFieldCount, -1},
+		{"Quote", "F,a,=c\nr,\"1,2\n", 2, 0, nil, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEngine().LoadTableCSV(strings.NewReader(tt.csv))
+
+			var tableErr *TableError
+			require.ErrorAs(t, err, &tableErr)
+			require.Equal(t, tt.row, tableErr.Row, err.Error())
+
+			if tt.column > 0 {
+				require.Equal(t, tt.column, tableErr.Column, err.Error())
+			}
+
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			}
+
+			if tt.start >= 0 {
+				var syntaxErr *SyntaxError
+				require.ErrorAs(t, err, &syntaxErr)
+				require.Equal(t, tt.start, syntaxErr.Start, err.Error())
+			}
+		})
+	}
+}
+
+func testDecisionTableCompileErrors(t *testing.T) {
+	engine := NewEngine()
+
+	_, err := engine.CompileTable(DecisionTable{
+		Inputs:  []string{"a"},
+		Outputs: []string{"b"},
+		Rows:    []TableRow{{Inputs: []string{"1"}, Outputs: []string{"x"}}, {Inputs: []string{"1"}}},
+	})
+	require.ErrorIs(t, err, ErrInvalidTable)
+	require.EqualError(t, err, "row 2: Invalid decision table: expected 1 inputs and 1 outputs, got 1 and 0")
+
+	_, err = engine.CompileTable(DecisionTable{
+		Inputs: []string{"a", "b"},
+		Rows:   []TableRow{{Inputs: []string{"-", `in ["x"`}}},
+	})
+	require.EqualError(t, err, "row 1, column 2: expected ], got EOF at position 7")
+
+	engine.SetSchema(NewSchema("country", "amount"))
+
+	_, err = engine.CompileTable(DecisionTable{
+		Inputs: []string{"amount", "contry"},
+		Rows:   []TableRow{{Inputs: []string{"gt 1", `"BR"`}}},
+	})
+
+	var tableErr *TableError
+	require.ErrorAs(t, err, &tableErr)
+	require.Equal(t, TableError{Row: 0, Column: 2, Err: tableErr.Err}, *tableErr,
+		"unknown attributes point at the header")
+	require.ErrorIs(t, err, ErrUnknownAttribute)
+
+	engine.SetLimits(Limits{MaxRuleLength: 10})
+
+	_, err = engine.CompileTable(DecisionTable{
+		Inputs: []string{"amount"},
+		Rows:   []TableRow{{Inputs: []string{"gt 1000000"}}},
+	})
+	require.True(t, errors.As(err, &tableErr) && tableErr.Column == 0)
+	require.ErrorIs(t, err, ErrRuleTooLong)
+}
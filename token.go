@@ -1,5 +1,7 @@
 package rule
 
+import "sort"
+
 type TokenType uint8
 
 const (
@@ -47,6 +49,33 @@ const (
 	// EQUALS is an alias for the equality operator.
 	EQUALS     // ==
 	NOT_EQUALS //nolint:revive,staticcheck // Token constants use ALL_CAPS convention
+
+	// PLUS represents the addition operator.
+	PLUS
+	MINUS
+	MULTIPLY
+	DIVIDE
+	MODULO
+
+	// IF starts a conditional expression.
+	IF
+	THEN
+	ELSE
+	CASE
+	WHEN
+	END
+
+	// PARAMETER is a $name placeholder bound when the rule is evaluated.
+	PARAMETER
+	// REFERENCE is an @name reference to a rule defined on the engine.
+	REFERENCE
+
+	// LET starts a local variable binding.
+	LET
+	ASSIGN // =
+
+	// DURATION is a duration literal such as 30d or P1M.
+	DURATION
 )
 
 type Token struct {
@@ -82,10 +111,29 @@ var keywordMap = map[string]TokenType{
 	"and":      AND,
 	"or":       OR,
 	"not":      NOT,
+	"if":       IF,
+	"then":     THEN,
+	"else":     ELSE,
+	"case":     CASE,
+	"when":     WHEN,
+	"end":      END,
+	"let":      LET,
 	trueString: BOOLEAN,
 	"false":    BOOLEAN,
 }
 
+// Keywords returns the reserved words of the rule language in alphabetical order.
+func Keywords() []string {
+	keywords := make([]string, 0, len(keywordMap))
+	for keyword := range keywordMap {
+		keywords = append(keywords, keyword)
+	}
+
+	sort.Strings(keywords)
+
+	return keywords
+}
+
 //nolint:gochecknoglobals // Static token string lookup table
 var tokenStringMap = map[TokenType]string{
 	EOF:         "EOF",
@@ -124,6 +172,22 @@ var tokenStringMap = map[TokenType]string{
 	NOT:         "not",
 	EQUALS:      "==",
 	NOT_EQUALS:  "!=",
+	PLUS:        "+",
+	MINUS:       "-",
+	MULTIPLY:    "*",
+	DIVIDE:      "/",
+	MODULO:      "%",
+	IF:          "if",
+	THEN:        "then",
+	ELSE:        "else",
+	CASE:        "case",
+	WHEN:        "when",
+	END:         "end",
+	PARAMETER:   "$",
+	REFERENCE:   "@",
+	LET:         "let",
+	ASSIGN:      "=",
+	DURATION:    "DURATION",
 }
 
 func (t TokenType) String() string {
@@ -141,3 +205,8 @@ func (t Token) String() string {
 
 	return t.Type.String()
 }
+
+// isArithmeticOperator reports whether the operator computes a number from two numbers.
+func isArithmeticOperator(op TokenType) bool {
+	return op == PLUS || op == MINUS || op == MULTIPLY || op == DIVIDE || op == MODULO
+}
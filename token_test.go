@@ -130,3 +130,17 @@ func TestTokenPosition(t *testing.T) {
 		t.Errorf("Expected end position 9, got %d", token.End)
 	}
 }
+
+// Test Keywords lists every reserved word in order.
+func TestKeywords(t *testing.T) {
+	keywords := Keywords()
+	if len(keywords) != len(keywordMap) {
+		t.Fatalf("Expected %d keywords, got %d", len(keywordMap), len(keywords))
+	}
+
+	for i := 1; i < len(keywords); i++ {
+		if keywords[i-1] >= keywords[i] {
+			t.Errorf("Keywords not sorted: %q before %q", keywords[i-1], keywords[i])
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package rule
+
+import (
+	"sort"
+	"time"
+)
+
+// TraceNode records the value one sub-expression produced while a rule was evaluated.
+type TraceNode struct {
+	Node *ASTNode
+	// Value is the result of the sub-expression: a bool for operators, the context or literal value
+	// for operands. It is nil when the node was skipped or refers to a missing attribute.
+	Value any
+	// Missing is set for identifiers and properties that are not present in the context.
+	Missing bool
+	// Skipped is set when a logical operator short-circuited before reaching the node.
+	Skipped  bool
+	Children []*TraceNode
+}
+
+// Trace evaluates a rule like Evaluate and returns the value of every sub-expression. It is meant
+// for debugging and authoring tools: each node is evaluated separately, so it is slower than Evaluate.
+func (e *Engine) Trace(rule string, context D) (*TraceNode, error) {
+	compiled, err := e.CompileRule(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.TraceCompiled(compiled, context)
+}
+
+// TraceCompiled is Trace for a pre-compiled rule.
+func (e *Engine) TraceCompiled(compiled *CompiledRule, context D) (*TraceNode, error) {
+	return e.evaluator.trace(compiled.AST, context)
+}
+
+func (e *Evaluator) trace(node *ASTNode, context D) (*TraceNode, error) {
+	var result EvalResult
+
+	if err := e.evaluateNode(node, context, &result); err != nil {
+		return nil, err
+	}
+
+	traced := &TraceNode{
+		Node:    node,
+		Value:   e.resultValue(&result),
+		Missing: !result.IsValid && (node.Type == NodeIdentifier || node.Type == NodeProperty),
+	}
+
+	switch node.Type {
+	case NodeBinaryOp:
+		left, err := e.trace(node.Left, context)
+		if err != nil {
+			return nil, err
+		}
+
+		var right *TraceNode
+
+		leftValue, _ := left.Value.(bool)
+		if (node.Operator == AND && !leftValue) || (node.Operator == OR && leftValue) {
+			right = skippedTrace(node.Right)
+		} else if right, err = e.trace(node.Right, context); err != nil {
+			return nil, err
+		}
+
+		traced.Children = []*TraceNode{left, right}
+
+	case NodeUnaryOp:
+		operand, err := e.trace(node.Left, context)
+		if err != nil {
+			return nil, err
+		}
+
+		traced.Children = []*TraceNode{operand}
+
+	case NodeConditional:
+		children, err := e.traceConditional(node, context)
+		if err != nil {
+			return nil, err
+		}
+
+		traced.Children = children
+
+	case NodeLet:
+		value, err := e.trace(node.Left, context)
+		if err != nil {
+			return nil, err
+		}
+
+		scoped, err := e.bind(node, context)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := scoped.trace(node.Right, context)
+		if err != nil {
+			return nil, err
+		}
+
+		traced.Children = []*TraceNode{value, body}
+
+	case NodeIdentifier, NodeLiteral, NodeArray, NodeProperty, NodeParameter, NodeReference, NodeVariable, NodeCall,
+		NodeError:
+	}
+
+	return traced, nil
+}
+
+// traceConditional traces the conditions of a conditional up to the first one that holds and the
+// value it selects. Every other branch is marked skipped.
+func (e *Evaluator) traceConditional(node *ASTNode, context D) ([]*TraceNode, error) {
+	children := make([]*TraceNode, 0, len(node.Children))
+	branches := node.Children
+
+	for len(branches) >= 2 {
+		condition, err := e.trace(branches[0], context)
+		if err != nil {
+			return nil, err
+		}
+
+		var result EvalResult
+		if err := e.evaluateNode(branches[0], context, &result); err != nil {
+			return nil, err
+		}
+
+		if !e.toBool(&result) {
+			children = append(children, condition, skippedTrace(branches[1]))
+			branches = branches[2:]
+
+			continue
+		}
+
+		value, err := e.trace(branches[1], context)
+		if err != nil {
+			return nil, err
+		}
+
+		children = append(children, condition, value)
+
+		for _, skipped := range branches[2:] {
+			children = append(children, skippedTrace(skipped))
+		}
+
+		return children, nil
+	}
+
+	if len(branches) == 1 {
+		otherwise, err := e.trace(branches[0], context)
+		if err != nil {
+			return nil, err
+		}
+
+		children = append(children, otherwise)
+	}
+
+	return children, nil
+}
+
+// skippedTrace marks a subtree that short-circuiting kept from being evaluated.
+func skippedTrace(node *ASTNode) *TraceNode {
+	traced := &TraceNode{Node: node, Skipped: true}
+
+	switch node.Type {
+	case NodeBinaryOp:
+		traced.Children = []*TraceNode{skippedTrace(node.Left), skippedTrace(node.Right)}
+	case NodeUnaryOp:
+		traced.Children = []*TraceNode{skippedTrace(node.Left)}
+	case NodeLet:
+		traced.Children = []*TraceNode{skippedTrace(node.Left), skippedTrace(node.Right)}
+	case NodeConditional:
+		traced.Children = make([]*TraceNode, len(node.Children))
+		for i, child := range node.Children {
+			traced.Children[i] = skippedTrace(child)
+		}
+	case NodeIdentifier, NodeLiteral, NodeArray, NodeProperty, NodeParameter, NodeReference, NodeVariable, NodeCall,
+		NodeError:
+	}
+
+	return traced
+}
+
+// resultValue converts an evaluation result back to a plain Go value.
+func (e *Evaluator) resultValue(result *EvalResult) any {
+	if !result.IsValid {
+		return nil
+	}
+
+	switch result.Type {
+	case ValueBoolean:
+		return result.Bool
+	case ValueNumber:
+		if result.IsInt {
+			return result.IntValue
+		}
+
+		return result.Num
+	case ValueString:
+		if t, ok := result.OriginalValue.(time.Time); ok {
+			return t
+		}
+
+		return result.Str
+	case ValueArray:
+		if result.OriginalValue != nil {
+			return result.OriginalValue
+		}
+
+		return literalToAny(&Value{Type: ValueArray, ArrValue: result.Arr})
+	case ValueDuration:
+		return result.Dur
+	case ValueIdentifier:
+		return nil
+	}
+
+	return nil
+}
+
+// ContextPaths returns the dotted path of every attribute in a context, including intermediate
+// objects, sorted alphabetically. Array elements are not descended into.
+func ContextPaths(context D) []string {
+	var paths []string
+
+	collectContextPaths(context, "", &paths)
+	sort.Strings(paths)
+
+	return paths
+}
+
+func collectContextPaths(context D, prefix string, paths *[]string) {
+	for key, value := range context {
+		path := prefix + key
+		*paths = append(*paths, path)
+
+		if nested, ok := value.(map[string]any); ok {
+			collectContextPaths(nested, path+".", paths)
+		}
+	}
+}
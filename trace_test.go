@@ -0,0 +1,124 @@
+package rule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	t.Run("ComparisonOperands", testTraceComparisonOperands)
+	t.Run("ShortCircuit", testTraceShortCircuit)
+	t.Run("MissingAttribute", testTraceMissingAttribute)
+	t.Run("MatchesEvaluate", testTraceMatchesEvaluate)
+	t.Run("Conditional", testTraceConditional)
+	t.Run("Let", testTraceLet)
+	t.Run("ParseError", testTraceParseError)
+}
+
+func testTraceComparisonOperands(t *testing.T) {
+	trace, err := NewEngine().Trace(`user.age gt 18`, D{"user": D{"age": 21}})
+	require.NoError(t, err)
+
+	require.Equal(t, true, trace.Value)
+	require.Len(t, trace.Children, 2)
+	require.Equal(t, int64(21), trace.Children[0].Value)
+	require.Equal(t, float64(18), trace.Children[1].Value)
+}
+
+func testTraceShortCircuit(t *testing.T) {
+	trace, err := NewEngine().Trace(`a eq 1 and (b eq 2 or c eq 3)`, D{"a": 2})
+	require.NoError(t, err)
+
+	require.Equal(t, false, trace.Value)
+	require.Equal(t, false, trace.Children[0].Value)
+	require.False(t, trace.Children[0].Skipped)
+
+	skipped := trace.Children[1]
+	require.True(t, skipped.Skipped)
+	require.Nil(t, skipped.Value)
+	require.True(t, skipped.Children[0].Skipped)
+	require.True(t, skipped.Children[1].Children[0].Skipped)
+
+	trace, err = NewEngine().Trace(`a eq 2 or b eq 2`, D{"a": 2})
+	require.NoError(t, err)
+	require.True(t, trace.Children[1].Skipped)
+}
+
+func testTraceMissingAttribute(t *testing.T) {
+	trace, err := NewEngine().Trace(`not (name eq "x") and tags in ["a"]`, D{"tags": "a"})
+	require.NoError(t, err)
+
+	not := trace.Children[0]
+	require.Equal(t, true, not.Value)
+	require.True(t, not.Children[0].Children[0].Missing)
+	require.Equal(t, "x", not.Children[0].Children[1].Value)
+	require.Equal(t, []any{"a"}, trace.Children[1].Children[1].Value)
+}
+
+func testTraceConditional(t *testing.T) {
+	trace, err := NewEngine().Trace(`case when a then 1 when b then 2 else 3 end eq 2`, D{"b": true})
+	require.NoError(t, err)
+	require.Equal(t, true, trace.Value)
+
+	conditional := trace.Children[0]
+	require.Equal(t, float64(2), conditional.Value)
+	require.Len(t, conditional.Children, 5)
+	require.Nil(t, conditional.Children[0].Value)
+	require.True(t, conditional.Children[1].Skipped)
+	require.Equal(t, true, conditional.Children[2].Value)
+	require.Equal(t, float64(2), conditional.Children[3].Value)
+	require.True(t, conditional.Children[4].Skipped)
+}
+
+func testTraceLet(t *testing.T) {
+	trace, err := NewEngine().Trace(`let t = a + b in t gt 2`, D{"a": 1, "b": 2})
+	require.NoError(t, err)
+	require.Equal(t, true, trace.Value)
+	require.Len(t, trace.Children, 2)
+	require.Equal(t, int64(3), trace.Children[0].Value)
+	require.Equal(t, true, trace.Children[1].Value)
+	require.Equal(t, int64(3), trace.Children[1].Children[0].Value)
+}
+
+func testTraceMatchesEvaluate(t *testing.T) {
+	engine := NewEngine()
+	context := D{"x": 5, "y": "hello", "z": D{"w": true}}
+
+	for _, rule := range []string{
+		`x gt 1 and y co "ell"`,
+		`x lt 1 or z.w`,
+		`not z.w or missing pr`,
+		`y in ["a", "b"]`,
+	} {
+		expected, err := engine.Evaluate(rule, context)
+		require.NoError(t, err)
+
+		trace, err := engine.Trace(rule, context)
+		require.NoError(t, err)
+		require.Equal(t, expected, trace.Value, rule)
+	}
+}
+
+func testTraceParseError(t *testing.T) {
+	_, err := NewEngine().Trace(`x eq`, D{})
+	require.Error(t, err)
+}
+
+func TestContextPaths(t *testing.T) {
+	paths := ContextPaths(D{
+		"user":  D{"name": "ann", "address": D{"city": "Rio"}},
+		"tags":  []any{D{"ignored": true}},
+		"count": 1,
+	})
+
+	require.Equal(t, []string{
+		"count",
+		"tags",
+		"user",
+		"user.address",
+		"user.address.city",
+		"user.name",
+	}, paths)
+	require.Empty(t, ContextPaths(nil))
+}
@@ -0,0 +1,215 @@
+package rule
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Shared helpers for the translators that push rules down to external query languages.
+
+// attributePath returns the path segments referenced by an identifier or property node.
+func attributePath(node *ASTNode) ([]string, bool) {
+	switch node.Type {
+	case NodeIdentifier:
+		return []string{node.Value.StrValue}, true
+	case NodeProperty:
+		path := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			path[i] = child.Value.StrValue
+		}
+
+		return path, true
+	case NodeBinaryOp, NodeUnaryOp, NodeLiteral, NodeArray, NodeError, NodeConditional, NodeParameter, NodeReference,
+		NodeLet, NodeVariable, NodeCall:
+		return nil, false
+	default:
+		return nil, false
+	}
+}
+
+// literalToAny converts a literal value into the Go value used as a query parameter.
+func literalToAny(value *Value) any {
+	switch value.Type {
+	case ValueString:
+		return value.StrValue
+	case ValueNumber:
+		if value.IsInt {
+			return value.IntValue
+		}
+
+		return value.NumValue
+	case ValueBoolean:
+		return value.BoolValue
+	case ValueArray:
+		items := make([]any, len(value.ArrValue))
+		for i := range value.ArrValue {
+			items[i] = literalToAny(&value.ArrValue[i])
+		}
+
+		return items
+	case ValueIdentifier, ValueDuration:
+		return nil
+	default:
+		return nil
+	}
+}
+
+// checkLiteral rejects literals that have no equivalent in the translated query: durations only
+// exist in date arithmetic, which no translator supports.
+func checkLiteral(value *Value) error {
+	isDurationValue := func(v Value) bool { return v.Type == ValueDuration }
+
+	if isDurationValue(*value) || (value.Type == ValueArray && slices.ContainsFunc(value.ArrValue, isDurationValue)) {
+		return unsupportedTranslation("duration literal")
+	}
+
+	return nil
+}
+
+// literalDateTime parses a literal the same way the evaluator parses datetime operands.
+func literalDateTime(value *Value) (time.Time, bool) {
+	var (
+		evaluator Evaluator
+		result    EvalResult
+	)
+
+	evaluator.setResultFromValue(&result, value)
+
+	return evaluator.parseDateTime(&result)
+}
+
+// literalDays parses a literal the same way the evaluator parses dl/dg thresholds.
+func literalDays(value *Value) (float64, bool) {
+	var (
+		evaluator Evaluator
+		result    EvalResult
+	)
+
+	evaluator.setResultFromValue(&result, value)
+
+	return evaluator.parseDaysThreshold(&result)
+}
+
+// daysToDuration converts a fractional day count into a duration.
+func daysToDuration(days float64) time.Duration {
+	return time.Duration(days * hoursPerDay * secondsPerHour * float64(time.Second))
+}
+
+// isDateTimeOperator reports whether the operator compares datetimes.
+func isDateTimeOperator(op TokenType) bool {
+	return op == DQ || op == DN || op == BE || op == BQ || op == AF || op == AQ || op == DL || op == DG
+}
+
+// asciiLower lowercases ASCII letters only, mirroring the evaluator's case folding.
+func asciiLower(s string) string {
+	var builder strings.Builder
+
+	builder.Grow(len(s))
+
+	for i := range len(s) {
+		builder.WriteByte(toLowerByte(s[i]))
+	}
+
+	return builder.String()
+}
+
+func unsupportedTranslation(detail string) error {
+	return fmt.Errorf("%w: %s", ErrUnsupportedTranslation, detail)
+}
+
+// mirrorOperator returns the operator that keeps the comparison equivalent when its operands are swapped.
+func mirrorOperator(op TokenType) TokenType {
+	switch op { //nolint:exhaustive // operators without a mirror are symmetric
+	case LT:
+		return GT
+	case GT:
+		return LT
+	case LE:
+		return GE
+	case GE:
+		return LE
+	case BE:
+		return AF
+	case AF:
+		return BE
+	case BQ:
+		return AQ
+	case AQ:
+		return BQ
+	default:
+		return op
+	}
+}
+
+// comparison is a binary comparison normalised for document-store translators: whenever an
+// attribute is compared with a literal and the operator can be mirrored, the attribute is on the left.
+type comparison struct {
+	op TokenType
+	// field is the attribute on the left, or nil when the left operand is a literal.
+	field []string
+	// other is the attribute on the right when two attributes are compared.
+	other []string
+	// left and right hold literal operands.
+	left  *Value
+	right *Value
+}
+
+func newComparison(node *ASTNode) (comparison, error) {
+	field, left, err := comparisonOperand(node.Left)
+	if err != nil {
+		return comparison{}, err
+	}
+
+	other, right, err := comparisonOperand(node.Right)
+	if err != nil {
+		return comparison{}, err
+	}
+
+	cmp := comparison{op: node.Operator, field: field, other: other, left: left, right: right}
+
+	isRelational := cmp.op != CO && cmp.op != SW && cmp.op != EW && cmp.op != IN && cmp.op != NOT_IN &&
+		cmp.op != DL && cmp.op != DG
+	if isRelational && cmp.field == nil && cmp.other != nil {
+		cmp.field, cmp.other = cmp.other, nil
+		cmp.left, cmp.right = nil, cmp.left
+		cmp.op = mirrorOperator(cmp.op)
+	}
+
+	return cmp, nil
+}
+
+// comparisonOperand splits an operand into an attribute path or a literal.
+func comparisonOperand(node *ASTNode) ([]string, *Value, error) {
+	if node.Type == NodeLiteral {
+		return nil, &node.Value, checkLiteral(&node.Value)
+	}
+
+	path, ok := attributePath(node)
+	if !ok {
+		return nil, nil, unsupportedTranslation("expression used as a comparison operand")
+	}
+
+	return path, nil, nil
+}
+
+// constant reports whether both operands are literals, in which case the comparison can be folded.
+func (c *comparison) constant() bool {
+	return c.field == nil && c.other == nil
+}
+
+// foldConstant evaluates a literal-only expression at translation time.
+func foldConstant(node *ASTNode) (bool, error) {
+	return NewEvaluator().Evaluate(node, nil)
+}
+
+// flattenLogical collects the operands of a chain of the same logical operator.
+func flattenLogical(node *ASTNode, op TokenType, operands []*ASTNode) []*ASTNode {
+	if node.Type == NodeBinaryOp && node.Operator == op {
+		operands = flattenLogical(node.Left, op, operands)
+		return flattenLogical(node.Right, op, operands)
+	}
+
+	return append(operands, node)
+}
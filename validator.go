@@ -1,9 +1,57 @@
 package rule
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
 
-// ValidateAST performs semantic validation on the parsed AST.
+// DefaultMaxDepth is the nesting depth ParseRule and ValidateAST accept. It is far beyond what
+// hand-written rules need while keeping recursion over the AST within a modest stack.
+const DefaultMaxDepth = 10000
+
+// ValidateAST performs semantic validation on the parsed AST. ASTs nested deeper than
+// DefaultMaxDepth are rejected with ErrMaxDepthExceeded.
 func ValidateAST(node *ASTNode) error {
+	return validateAST(node, DefaultMaxDepth)
+}
+
+// validateAST implements ValidateAST with a configurable maximum depth; zero disables the check.
+func validateAST(node *ASTNode, maxDepth int) error {
+	if maxDepth > 0 {
+		if deep := nodeAtDepth(node, maxDepth+1); deep != nil {
+			return spanError(deep, &LimitError{Err: ErrMaxDepthExceeded, Limit: maxDepth})
+		}
+	}
+
+	return validateNode(node)
+}
+
+// nodeAtDepth returns a node at the given depth below node, which is at depth 1, or nil when the
+// AST is shallower. It never descends further, so it is safe on arbitrarily deep ASTs.
+func nodeAtDepth(node *ASTNode, depth int) *ASTNode {
+	if node == nil || depth == 1 {
+		return node
+	}
+
+	if deep := nodeAtDepth(node.Left, depth-1); deep != nil {
+		return deep
+	}
+
+	if deep := nodeAtDepth(node.Right, depth-1); deep != nil {
+		return deep
+	}
+
+	for _, child := range node.Children {
+		if deep := nodeAtDepth(child, depth-1); deep != nil {
+			return deep
+		}
+	}
+
+	return nil
+}
+
+func validateNode(node *ASTNode) error {
 	if node == nil {
 		return nil
 	}
@@ -11,31 +59,57 @@ func ValidateAST(node *ASTNode) error {
 	switch node.Type {
 	case NodeBinaryOp:
 		if err := validateBinaryOperation(node); err != nil {
-			return err
+			return spanError(node, err)
 		}
 
 		// Recursively validate children
-		if err := ValidateAST(node.Left); err != nil {
+		if err := validateNode(node.Left); err != nil {
 			return err
 		}
 
-		if err := ValidateAST(node.Right); err != nil {
+		if err := validateNode(node.Right); err != nil {
 			return err
 		}
 
 	case NodeUnaryOp:
 		if err := validateUnaryOperation(node); err != nil {
-			return err
+			return spanError(node, err)
 		}
 
 		// Recursively validate the operand
-		if err := ValidateAST(node.Left); err != nil {
+		if err := validateNode(node.Left); err != nil {
+			return err
+		}
+
+	case NodeConditional:
+		if err := validateConditional(node); err != nil {
 			return err
 		}
 
-	case NodeLiteral, NodeIdentifier, NodeProperty, NodeArray:
+		for _, child := range node.Children {
+			if err := validateNode(child); err != nil {
+				return err
+			}
+		}
+
+	case NodeLet:
+		if err := validateLet(node); err != nil {
+			return err
+		}
+
+		if err := validateNode(node.Left); err != nil {
+			return err
+		}
+
+		return validateNode(node.Right)
+
+	case NodeLiteral, NodeIdentifier, NodeProperty, NodeArray, NodeParameter, NodeReference, NodeVariable, NodeCall:
 		// These are terminal nodes, no further validation needed
 		return nil
+
+	case NodeError:
+		// Partial ASTs from a recovering parse are never valid
+		return spanError(node, ErrInvalidSyntax)
 	}
 
 	return nil
@@ -51,9 +125,14 @@ func validateBinaryOperation(node *ASTNode) error {
 		return validateInOperation(node)
 	case CO, SW, EW:
 		return validateStringOperation(node)
+	case PLUS, MINUS, MULTIPLY, DIVIDE, MODULO:
+		return validateArithmeticOperation(node)
+	case EQ, NE, LT, GT, LE, GE, EQUALS, NOT_EQUALS:
+		return validateComparisonOperation(node)
 	case EOF, IDENTIFIER, STRING, NUMBER, BOOLEAN, ARRAY_START, ARRAY_END,
-		PAREN_OPEN, PAREN_CLOSE, DOT, COMMA, EQ, NE, LT, GT, LE, GE, PR,
-		DQ, DN, BE, BQ, AF, AQ, DL, DG, AND, OR, NOT, EQUALS, NOT_EQUALS:
+		PAREN_OPEN, PAREN_CLOSE, DOT, COMMA, PR,
+		DQ, DN, BE, BQ, AF, AQ, DL, DG, AND, OR, NOT, IF, THEN, ELSE, CASE, WHEN, END,
+		PARAMETER, REFERENCE, LET, ASSIGN, DURATION:
 		// Other operators don't need special validation
 		return nil
 	}
@@ -71,7 +150,9 @@ func validateUnaryOperation(node *ASTNode) error {
 		return validatePresenceOperation(node)
 	case EOF, IDENTIFIER, STRING, NUMBER, BOOLEAN, ARRAY_START, ARRAY_END,
 		PAREN_OPEN, PAREN_CLOSE, DOT, COMMA, EQ, NE, LT, GT, LE, GE,
-		CO, SW, EW, IN, NOT_IN, DQ, DN, BE, BQ, AF, AQ, DL, DG, AND, OR, NOT, EQUALS, NOT_EQUALS:
+		CO, SW, EW, IN, NOT_IN, DQ, DN, BE, BQ, AF, AQ, DL, DG, AND, OR, NOT, EQUALS, NOT_EQUALS,
+		PLUS, MINUS, MULTIPLY, DIVIDE, MODULO, IF, THEN, ELSE, CASE, WHEN, END,
+		PARAMETER, REFERENCE, LET, ASSIGN, DURATION:
 		// Other operators don't apply to unary operations
 		return nil
 	}
@@ -88,9 +169,14 @@ func validateInOperation(node *ASTNode) error {
 			if node.Right.Value.Type != ValueArray {
 				return ErrInvalidInOperand
 			}
-		case NodeIdentifier, NodeProperty:
-			// Allow identifiers/properties as they might evaluate to arrays at runtime
+		case NodeIdentifier, NodeProperty, NodeParameter, NodeReference, NodeVariable, NodeError:
+			// Allow identifiers/properties and parameters as they might evaluate to arrays at
+			// runtime; skipped input was already reported by the parser
 			return nil
+		case NodeConditional, NodeLet, NodeCall:
+			if valueType, known := staticType(node.Right); known && valueType != ValueArray {
+				return ErrInvalidInOperand
+			}
 		case NodeBinaryOp, NodeUnaryOp, NodeArray:
 			return ErrInvalidInOperand
 		}
@@ -118,9 +204,211 @@ func validateStringOperation(node *ASTNode) error {
 		}
 	}
 
+	if isArithmetic(left) || isArithmetic(right) {
+		return ErrInvalidStringOp
+	}
+
+	for _, operand := range []*ASTNode{left, right} {
+		if operand.Type != NodeConditional {
+			continue
+		}
+
+		if valueType, known := staticType(operand); known && valueType != ValueString {
+			return ErrInvalidStringOp
+		}
+	}
+
+	return nil
+}
+
+func validateComparisonOperation(node *ASTNode) error {
+	// A computed number never equals or orders against a string, boolean or array
+	for _, pair := range [][2]*ASTNode{{node.Left, node.Right}, {node.Right, node.Left}} {
+		if valueType, _ := staticType(pair[0]); !isArithmetic(pair[0]) || valueType != ValueNumber {
+			continue
+		}
+
+		if valueType, known := staticType(pair[1]); known && valueType != ValueNumber {
+			return ErrIncompatibleTypes
+		}
+	}
+
+	return nil
+}
+
+func isArithmetic(node *ASTNode) bool {
+	return node.Type == NodeBinaryOp && isArithmeticOperator(node.Operator)
+}
+
+func validateArithmeticOperation(node *ASTNode) error {
+	if isDuration(node.Left) || isDuration(node.Right) {
+		return validateDateTimeShift(node)
+	}
+
+	// Attributes may hold numbers at runtime, but literals and conditions never do
+	for _, operand := range []*ASTNode{node.Left, node.Right} {
+		if valueType, known := staticType(operand); known && valueType != ValueNumber {
+			return ErrInvalidArithmeticOp
+		}
+	}
+
+	return nil
+}
+
+// validateDateTimeShift checks an arithmetic operation on a duration, which may only be added to
+// or subtracted from a datetime. Datetimes are strings or Unix timestamps, or attributes holding them.
+func validateDateTimeShift(node *ASTNode) error {
+	dateTime := node.Left
+
+	switch {
+	case isDuration(node.Right) && (node.Operator == PLUS || node.Operator == MINUS):
+	case isDuration(node.Left) && node.Operator == PLUS:
+		dateTime = node.Right
+	default:
+		return ErrInvalidArithmeticOp
+	}
+
+	if valueType, known := staticType(dateTime); known && valueType != ValueString && valueType != ValueNumber {
+		return ErrInvalidArithmeticOp
+	}
+
+	return nil
+}
+
+func isDuration(node *ASTNode) bool {
+	valueType, known := staticType(node)
+	return known && valueType == ValueDuration
+}
+
+// staticType returns the type a node always evaluates to, if it can be told without a context.
+func staticType(node *ASTNode) (ValueType, bool) {
+	switch node.Type {
+	case NodeLiteral:
+		return node.Value.Type, true
+	case NodeBinaryOp:
+		if !isArithmeticOperator(node.Operator) {
+			return ValueBoolean, true
+		}
+
+		// Shifting a datetime by a duration yields a datetime, which reads as a string
+		if (node.Operator == PLUS || node.Operator == MINUS) && (isDuration(node.Left) || isDuration(node.Right)) {
+			return ValueString, true
+		}
+
+		return ValueNumber, true
+	case NodeUnaryOp:
+		return ValueBoolean, true
+	case NodeConditional:
+		var (
+			valueType ValueType
+			known     bool
+		)
+
+		for _, value := range conditionalValues(node) {
+			if branchType, branchKnown := staticType(value); branchKnown {
+				valueType, known = branchType, true
+			}
+		}
+
+		return valueType, known
+	case NodeLet:
+		return staticType(node.Right)
+	case NodeCall:
+		// now() is the only function; it returns a datetime, which reads as a string
+		return ValueString, true
+	case NodeIdentifier, NodeProperty, NodeArray, NodeParameter, NodeReference, NodeVariable, NodeError:
+		return 0, false
+	}
+
+	return 0, false
+}
+
+// validateConditional checks that every branch of a conditional that has a type known without a
+// context has the same type, so the conditional always yields values of one type.
+func validateConditional(node *ASTNode) error {
+	if len(node.Children) < 2 { //nolint:mnd // a condition and its value
+		return spanError(node, errors.New("conditional missing branches"))
+	}
+
+	var (
+		first     *ASTNode
+		firstType ValueType
+	)
+
+	for _, value := range conditionalValues(node) {
+		valueType, known := staticType(value)
+		if !known {
+			continue
+		}
+
+		if first == nil {
+			first, firstType = value, valueType
+			continue
+		}
+
+		if valueType != firstType {
+			return spanError(value, fmt.Errorf("%w: %s and %s", ErrIncompatibleBranches, firstType, valueType))
+		}
+	}
+
+	return nil
+}
+
+// validateLet rejects a let whose variable is never read, and lets in its body that bind the same
+// name again, hiding the outer variable.
+func validateLet(node *ASTNode) error {
+	name := node.Value.StrValue
+
+	shadowing := findNode(node.Right, func(n *ASTNode) bool { return n.Type == NodeLet && n.Value.StrValue == name })
+	if shadowing != nil {
+		return spanError(shadowing, fmt.Errorf("%w: %s", ErrShadowedVariable, name))
+	}
+
+	used := findNode(node.Right, func(n *ASTNode) bool {
+		return n.Type == NodeVariable && n.Value.StrValue == name
+	})
+	if used == nil {
+		return spanError(node, fmt.Errorf("%w: %s", ErrUnusedVariable, name))
+	}
+
+	return nil
+}
+
+// findNode returns the first node of the tree, in depth-first order, that matches.
+func findNode(node *ASTNode, match func(*ASTNode) bool) *ASTNode {
+	if node == nil {
+		return nil
+	}
+
+	if match(node) {
+		return node
+	}
+
+	for _, child := range append([]*ASTNode{node.Left, node.Right}, node.Children...) {
+		if found := findNode(child, match); found != nil {
+			return found
+		}
+	}
+
 	return nil
 }
 
+// conditionalValues returns the values a conditional may yield: the value of each branch and of
+// the else branch.
+func conditionalValues(node *ASTNode) []*ASTNode {
+	values := make([]*ASTNode, 0, len(node.Children)/2+1)
+
+	for i := 1; i < len(node.Children); i += 2 {
+		values = append(values, node.Children[i])
+	}
+
+	if otherwise := node.otherwise(); otherwise != nil {
+		values = append(values, otherwise)
+	}
+
+	return values
+}
+
 func validatePresenceOperation(node *ASTNode) error {
 	// Presence operator should only work on identifiers or properties
 	operand := node.Left
@@ -130,3 +418,60 @@ func validatePresenceOperation(node *ASTNode) error {
 
 	return nil
 }
+
+// collectValidationErrors validates every operation of a possibly partial AST, collecting all
+// errors instead of stopping at the first one. Operations on skipped input are not checked.
+func collectValidationErrors(node *ASTNode, errs *[]*SyntaxError) {
+	if node == nil || node.Type == NodeError {
+		return
+	}
+
+	var err error
+
+	switch node.Type {
+	case NodeBinaryOp:
+		if !hasErrorOperand(node) {
+			err = validateBinaryOperation(node)
+		}
+	case NodeUnaryOp:
+		if !hasErrorOperand(node) {
+			err = validateUnaryOperation(node)
+		}
+	case NodeConditional:
+		if !slices.ContainsFunc(node.Children, func(child *ASTNode) bool { return child.Type == NodeError }) {
+			err = validateConditional(node)
+		}
+
+		for _, child := range node.Children {
+			collectValidationErrors(child, errs)
+		}
+	case NodeLet:
+		err = validateLet(node)
+	case NodeLiteral, NodeIdentifier, NodeProperty, NodeArray, NodeParameter, NodeReference, NodeVariable, NodeCall,
+		NodeError:
+		return
+	}
+
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		*errs = append(*errs, syntaxErr)
+	} else if err != nil {
+		*errs = append(*errs, &SyntaxError{Err: err, Start: node.Start, End: node.End})
+	}
+
+	collectValidationErrors(node.Left, errs)
+	collectValidationErrors(node.Right, errs)
+}
+
+func hasErrorOperand(node *ASTNode) bool {
+	return (node.Left != nil && node.Left.Type == NodeError) || (node.Right != nil && node.Right.Type == NodeError)
+}
+
+// spanError attaches the source span of node to a validation error when the node came from the parser.
+func spanError(node *ASTNode, err error) error {
+	if !node.HasSpan() {
+		return err
+	}
+
+	return &SyntaxError{Err: err, Start: node.Start, End: node.End}
+}
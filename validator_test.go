@@ -11,6 +11,7 @@ func TestValidateAST(t *testing.T) {
 	t.Run("NilAST", testNilASTValidation)
 	t.Run("ValidQueries", testValidASTQueries)
 	t.Run("InvalidQueries", testInvalidASTQueries)
+	t.Run("MaxDepth", testValidateASTMaxDepth)
 }
 
 func testNilASTValidation(t *testing.T) {
@@ -24,6 +25,8 @@ func testValidASTQueries(t *testing.T) {
 		`field in [1, 2, 3]`,
 		`"hello" co "ell"`,
 		`field pr`,
+		`(if a then 1 else b) gt 0`,
+		`case when a then "x" when b then c end eq "x"`,
 	}
 
 	for _, query := range tests {
@@ -45,6 +48,17 @@ func testInvalidASTQueries(t *testing.T) {
 		{`field in "string"`, ErrInvalidInOperand},
 		{`123 co "test"`, ErrInvalidStringOp},
 		{`"string" pr`, ErrInvalidPresenceOp},
+		{`price + "10" gt 1`, ErrInvalidArithmeticOp},
+		{`(a gt 1) * 2 gt 1`, ErrInvalidArithmeticOp},
+		{`field-name eq "test"`, ErrIncompatibleTypes},
+		{`true ne a % 2`, ErrIncompatibleTypes},
+		{`a + 1 co "1"`, ErrInvalidStringOp},
+		{`(if a then 1 else "x") eq b`, ErrIncompatibleBranches},
+		{`case when a then true when b then c else [1] end`, ErrIncompatibleBranches},
+		{`(if a then 1 else 2) co "1"`, ErrInvalidStringOp},
+		{`let t = a in b gt 1`, ErrUnusedVariable},
+		{`let t = a in let t = b in t gt 1`, ErrShadowedVariable},
+		{`let t = a, t = b in t gt 1`, ErrShadowedVariable},
 	}
 
 	for _, tt := range tests {
@@ -225,3 +239,20 @@ func TestValidatePresenceOperation(t *testing.T) {
 		})
 	}
 }
+
+func testValidateASTMaxDepth(t *testing.T) {
+	// Long and chains are built iteratively by the parser but still nest one level per operand.
+	chain := NewBinaryOpNode(EQ, NewIdentifierNode("a"), NewNumberLiteralNode(1))
+	for range DefaultMaxDepth {
+		chain = NewBinaryOpNode(AND, chain, NewUnaryOpNode(PR, NewIdentifierNode("b")))
+	}
+
+	err := ValidateAST(chain)
+	require.ErrorIs(t, err, ErrMaxDepthExceeded)
+	require.Equal(t, "Rule exceeds the maximum nesting depth: the limit is 10000", err.Error())
+
+	require.NoError(t, ValidateAST(chain.Left.Left), "an AST exactly DefaultMaxDepth deep is valid")
+
+	_, err = ParseRule(strings.Repeat("a pr and ", DefaultMaxDepth) + "a pr")
+	require.ErrorIs(t, err, ErrMaxDepthExceeded)
+}